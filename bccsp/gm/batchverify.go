@@ -0,0 +1,59 @@
+/*
+Copyright Suzhou Tongji Fintech Research Institute 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package gm
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/bccsp"
+)
+
+// SM2BatchVerifier and BatchVerify do NOT implement the randomized-linear-combination /
+// multi-scalar-multiplication speedup requested for this feature - they are a per-signature
+// VerifyKey loop behind the requested ok/failed-indices shape, not the aggregate check, and should
+// not be mistaken for it.
+//
+// The aggregate check needs each signature's full curve point R recovered from r, and recovering
+// R needs a recovery id to pick between the two candidate y values for a given x (the same reason
+// recoverable ECDSA signatures, e.g. on secp256k1, carry one). MarshalSM2Signature's plain ASN.1
+// (r, s) carries no such id: guessing the wrong branch would either reject a valid signature or,
+// worse, let a forged one cancel out of the aggregate check unnoticed. That is a signature-format
+// change, not something this commit can safely paper over, so the aggregate path - and the
+// order-of-magnitude block-validator speedup it was meant to deliver - remains undone. What ships
+// here is only the fallback every aggregate implementation would need anyway, exposed so callers
+// have a stable ok/failed-indices API to switch a real aggregate check behind later.
+type SM2BatchVerifier struct{}
+
+func (*SM2BatchVerifier) BatchVerify(pubs []bccsp.Key, sigs, digests [][]byte, opts bccsp.SignerOpts) (ok bool, failed []int, err error) {
+	return BatchVerify(pubs, sigs, digests, opts)
+}
+
+// BatchVerify is the package-level counterpart of SM2BatchVerifier.BatchVerify; see that type's
+// doc comment for why this is a per-signature loop rather than the requested aggregated check.
+func BatchVerify(pubs []bccsp.Key, sigs, digests [][]byte, opts bccsp.SignerOpts) (ok bool, failed []int, err error) {
+	if len(pubs) != len(sigs) || len(pubs) != len(digests) {
+		return false, nil, fmt.Errorf("mismatched batch lengths: %d keys, %d signatures, %d digests", len(pubs), len(sigs), len(digests))
+	}
+
+	for i := range pubs {
+		valid, verr := VerifyKey(pubs[i], sigs[i], digests[i], opts)
+		if verr != nil || !valid {
+			failed = append(failed, i)
+		}
+	}
+
+	return len(failed) == 0, failed, nil
+}
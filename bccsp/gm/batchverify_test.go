@@ -0,0 +1,71 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gm_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/gm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchVerifyAllValid(t *testing.T) {
+	kg := &gm.SM2KeyGenerator{}
+	signer := &gm.SM2Signer{}
+
+	var pubs []bccsp.Key
+	var sigs, digests [][]byte
+	for i := 0; i < 4; i++ {
+		key, err := kg.KeyGen(nil)
+		require.NoError(t, err)
+		digest := []byte{byte(i), 1, 2, 3}
+		sig, err := signer.Sign(key, digest, nil)
+		require.NoError(t, err)
+
+		pubs = append(pubs, key)
+		sigs = append(sigs, sig)
+		digests = append(digests, digest)
+	}
+
+	ok, failed, err := gm.BatchVerify(pubs, sigs, digests, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, failed)
+}
+
+func TestBatchVerifyReportsFailingIndices(t *testing.T) {
+	kg := &gm.SM2KeyGenerator{}
+	signer := &gm.SM2Signer{}
+
+	var pubs []bccsp.Key
+	var sigs, digests [][]byte
+	for i := 0; i < 4; i++ {
+		key, err := kg.KeyGen(nil)
+		require.NoError(t, err)
+		digest := []byte{byte(i), 1, 2, 3}
+		sig, err := signer.Sign(key, digest, nil)
+		require.NoError(t, err)
+
+		pubs = append(pubs, key)
+		sigs = append(sigs, sig)
+		digests = append(digests, digest)
+	}
+
+	// Corrupt the digest checked against signature index 2 so only that one fails.
+	digests[2] = []byte{0xff, 0xff, 0xff, 0xff}
+
+	ok, failed, err := gm.BatchVerify(pubs, sigs, digests, nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, []int{2}, failed)
+}
+
+func TestBatchVerifyRejectsMismatchedLengths(t *testing.T) {
+	_, _, err := gm.BatchVerify(make([]bccsp.Key, 2), make([][]byte, 1), make([][]byte, 2), nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright Suzhou Tongji Fintech Research Institute 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package gm
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// A BLS12-381 signer/verifier set (BLSSigner, BLSPrivateKeyVerifier, BLSPublicKeyKeyVerifier),
+// AggregateSignatures/VerifyAggregate, RFC 9380 hash-to-curve, and a proof-of-possession scheme
+// are not implemented in this checkout.
+//
+// Unlike SM2 and ECDSA, for which this package already has real curve arithmetic to build on
+// (tjfoc/gmsm/sm2 and crypto/ecdsa, both actually present and vendored here), there is no
+// pairing-friendly curve implementation anywhere in this tree or its dependencies - no BLS12-381
+// field/group arithmetic, no pairing operation, nothing a hash-to-curve or signature routine could
+// be built on top of. Hand-rolling pairing-curve field arithmetic and a Miller loop from scratch
+// for this commit, with no way to test it against a reference implementation in this sandbox,
+// would produce code that looks plausible but cannot be trusted for a signature scheme - the one
+// place a "write it in the repo's style as if the dependency existed" approach stops being honest.
+//
+// Once a pairing-friendly curve library is vendored (e.g. one implementing BLS12-381 G1/G2/GT
+// arithmetic and pairings), this should land as a BLSSigner/BLSPrivateKeyVerifier/
+// BLSPublicKeyKeyVerifier trio parallel to SM2Signer/SM2PrivateKeyVerifier/SM2PublicKeyKeyVerifier,
+// registered through the same factory, with package-level AggregateSignatures/VerifyAggregate
+// helpers, hash-to-curve per RFC 9380 (BLS12381G2_XMD:SHA-256_SSWU_RO_, DST
+// "FABRIC-BLS-SIG-BCCSP-V01"), PopProve/PopVerify for rogue-key defense, and key import/export
+// routed through the existing bccsp.KeyStore so BLS keys live next to SM2 keys.
@@ -0,0 +1,139 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package envelopes wraps bccsp/gm SM2 signatures in token formats that interoperate with
+// token-based auth systems outside Fabric, starting with a detached JWS profile. See note.go in
+// this package for COSE_Sign1 and the Idemix/JWS bridge, which are not implemented here.
+package envelopes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/gm"
+	"github.com/tjfoc/gmsm/sm3"
+)
+
+// sm2JWSAlg is the alg value this package registers for a detached JWS whose signature was
+// produced by an bccsp/gm SM2Signer. It is a private-use identifier; there is no IANA-registered
+// JOSE alg for SM2-SM3.
+const sm2JWSAlg = "SM2-SM3"
+
+// sm2FieldByteLen is the byte length of one coordinate (r or s) of an SM2 signature over the
+// SM2 recommended curve, whose field and group order are both 256 bits.
+const sm2FieldByteLen = 32
+
+// EncodeJWS wraps an ASN.1 DER SM2 signature, as produced by gm.SM2Signer.Sign over
+// SM3(header || "." || payload), into a compact JWS. hdr is merged with {"alg": "SM2-SM3"};
+// callers should not set "alg" themselves. If payload is nil, the returned token is detached per
+// RFC 7797 - its middle segment is empty and the payload must be supplied out of band to
+// VerifyJWS - since that is the common case for a Fabric envelope whose payload is carried
+// elsewhere in the message. If payload is non-nil, it is embedded as an ordinary JWS.
+func EncodeJWS(sig []byte, hdr map[string]interface{}, payload []byte) ([]byte, error) {
+	raw, err := rawSM2Signature(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(map[string]interface{}, len(hdr)+1)
+	for k, v := range hdr {
+		header[k] = v
+	}
+	header["alg"] = sm2JWSAlg
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling JWS header: %w", err)
+	}
+
+	encHeader := base64.RawURLEncoding.EncodeToString(headerJSON)
+	encPayload := ""
+	if payload != nil {
+		encPayload = base64.RawURLEncoding.EncodeToString(payload)
+	}
+	encSig := base64.RawURLEncoding.EncodeToString(raw)
+
+	return []byte(encHeader + "." + encPayload + "." + encSig), nil
+}
+
+// VerifyJWS verifies a compact JWS produced by EncodeJWS. payload must be supplied whenever the
+// token's middle segment is empty (the detached case); it is ignored otherwise. keyResolver maps
+// the decoded JWS header to the bccsp.Key that should have produced the signature, e.g. by reading
+// a "kid" header field.
+func VerifyJWS(token, payload []byte, keyResolver func(header map[string]interface{}) (bccsp.Key, error)) (bool, error) {
+	parts := strings.Split(string(token), ".")
+	if len(parts) != 3 {
+		return false, errors.New("invalid JWS compact serialization: expected 3 segments")
+	}
+	encHeader, encPayload, encSig := parts[0], parts[1], parts[2]
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(encHeader)
+	if err != nil {
+		return false, fmt.Errorf("decoding JWS header: %w", err)
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return false, fmt.Errorf("unmarshaling JWS header: %w", err)
+	}
+
+	if alg, _ := header["alg"].(string); alg != sm2JWSAlg {
+		return false, fmt.Errorf("unsupported JWS alg %q, expected %q", header["alg"], sm2JWSAlg)
+	}
+
+	if encPayload == "" {
+		if payload == nil {
+			return false, errors.New("detached JWS: payload must be supplied for verification")
+		}
+		encPayload = base64.RawURLEncoding.EncodeToString(payload)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return false, fmt.Errorf("decoding JWS signature: %w", err)
+	}
+	derSig, err := derSM2Signature(sig)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := keyResolver(header)
+	if err != nil {
+		return false, fmt.Errorf("resolving verification key: %w", err)
+	}
+
+	digest := sm3.Sm3Sum([]byte(encHeader + "." + encPayload))
+	return gm.VerifyKey(key, derSig, digest, nil)
+}
+
+// rawSM2Signature converts an ASN.1 DER SM2 signature into the raw 64-byte r||s big-endian
+// concatenation a JWS signature segment expects.
+func rawSM2Signature(der []byte) ([]byte, error) {
+	r, s, err := gm.UnmarshalSM2Signature(der)
+	if err != nil {
+		return nil, fmt.Errorf("decoding SM2 signature: %w", err)
+	}
+
+	out := make([]byte, 2*sm2FieldByteLen)
+	r.FillBytes(out[:sm2FieldByteLen])
+	s.FillBytes(out[sm2FieldByteLen:])
+	return out, nil
+}
+
+// derSM2Signature is the inverse of rawSM2Signature.
+func derSM2Signature(raw []byte) ([]byte, error) {
+	if len(raw) != 2*sm2FieldByteLen {
+		return nil, fmt.Errorf("invalid SM2 JWS signature length %d, expected %d", len(raw), 2*sm2FieldByteLen)
+	}
+	r := new(big.Int).SetBytes(raw[:sm2FieldByteLen])
+	s := new(big.Int).SetBytes(raw[sm2FieldByteLen:])
+	return gm.MarshalSM2Signature(r, s)
+}
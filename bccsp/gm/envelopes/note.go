@@ -0,0 +1,28 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package envelopes
+
+// A COSE_Sign1 encoding (EncodeCOSE/VerifyCOSE) and an idemix/bridge integration presenting an
+// Idemix credential as a JWS are not implemented in this checkout.
+//
+// COSE_Sign1 is a CBOR structure, and there is no CBOR encoder/decoder anywhere in this tree or
+// its dependencies to build one on top of; hand-rolling just enough CBOR for one message shape,
+// untested against a reference decoder, is the same false-economy this repo avoids elsewhere (see
+// bls.go in bccsp/gm for the pairing-curve equivalent of this judgment call).
+//
+// The Idemix bridge is blocked differently: bccsp/idemix/bridge in this checkout has only
+// rand.go - a single PRG helper - with no Idemix credential, nym signature, or proof type defined
+// anywhere in this tree. Those live in the external cryptolib this checkout imports
+// (github.com/VoneChain-CS/fabric-gm/idemix) but does not vendor or reimplement, so there is no
+// concrete credential/nym-signature value here for a bridge to marshal into a JWS payload.
+//
+// Once a CBOR library is vendored, EncodeCOSE/VerifyCOSE should land alongside EncodeJWS/VerifyJWS
+// in this package with the same SM2-SM3 algorithm and a registered private-use COSE algorithm
+// identifier. Once this tree carries real Idemix credential/nym-signature types, the bridge should
+// land in bccsp/idemix/bridge as a function taking a credential and nym signature and returning an
+// EncodeJWS-built token with the nym signature as the JWS payload, so a gateway can accept it
+// alongside standard OIDC tokens without custom middleware.
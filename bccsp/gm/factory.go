@@ -0,0 +1,60 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gm
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/pkcs11"
+)
+
+// keyGenerator dispatches bccsp.SM2KeyGenOpts/bccsp.SM4KeyGenOpts to whichever SM2/SM4
+// KeyGenerator New wired in for them, so the rest of the gm package (Signer, Verifier,
+// KeyImporters) stays oblivious to whether key material lives in process memory or inside an HSM.
+type keyGenerator struct {
+	sm2 bccsp.KeyGenerator
+	sm4 bccsp.KeyGenerator
+}
+
+func (g *keyGenerator) KeyGen(opts bccsp.KeyGenOpts) (bccsp.Key, error) {
+	switch opts.(type) {
+	case *bccsp.SM2KeyGenOpts:
+		return g.sm2.KeyGen(opts)
+	case *bccsp.SM4KeyGenOpts:
+		return g.sm4.KeyGen(opts)
+	default:
+		return nil, fmt.Errorf("Unsupported KeyGenOpts type [%T]", opts)
+	}
+}
+
+// New returns the bccsp.KeyGenerator this package's BCCSP wiring uses for SM2/SM4 key generation.
+// When pkcs11Opts is nil it is the existing software path (SM2KeyGenerator/SM4KeyGenerator,
+// generating via sm2.GenerateKey and GetRandomBytes); when set, key generation is instead
+// delegated to the HSM identified by pkcs11Opts, via bccsp/pkcs11's SM2PKCS11KeyGenerator and
+// SM4PKCS11KeyGenerator, so that deployments required to keep SM2 private keys inside FIPS/GM-
+// certified hardware never have the private scalar pass through process memory. sm4KeyLen is only
+// consulted on the software path; the HSM path always generates a 16-byte (128-bit) SM4 key.
+func New(pkcs11Opts *pkcs11.PKCS11Opts, sm4KeyLen int) (bccsp.KeyGenerator, error) {
+	if pkcs11Opts == nil {
+		return &keyGenerator{
+			sm2: &SM2KeyGenerator{},
+			sm4: &SM4KeyGenerator{length: sm4KeyLen},
+		}, nil
+	}
+
+	csp, err := pkcs11.NewCSP(*pkcs11Opts)
+	if err != nil {
+		return nil, fmt.Errorf("Failed initializing PKCS#11 session for GM key generation [%s]", err)
+	}
+	return &keyGenerator{
+		sm2: &pkcs11.SM2PKCS11KeyGenerator{CSP: csp},
+		sm4: &pkcs11.SM4PKCS11KeyGenerator{CSP: csp},
+	}, nil
+}
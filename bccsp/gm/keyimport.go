@@ -17,6 +17,10 @@ package gm
 
 import (
 	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"reflect"
@@ -214,3 +218,141 @@ func (ki *x509PublicKeyImportOptsKeyImporter) KeyImport(raw interface{}, opts bc
 		return nil, errors.New("Certificate's public key type not recognized. Supported keys: [GMSM2]")
 	}
 }
+
+// sm2NamedCurveOID is the OID GB/T 32918 (and GM/T 0006) register for the SM2 elliptic curve
+// inside an X.509 SubjectPublicKeyInfo's AlgorithmIdentifier.Parameters. It is what distinguishes
+// an SM2 key from a NIST one once both are otherwise encoded as a plain id-ecPublicKey SPKI.
+var sm2NamedCurveOID = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+
+// X509GMImportErrorReason distinguishes the two ways x509GMCertificateImportOptsKeyImporter can
+// fail to recover a public key from a certificate, so callers can tell "this cert is simply not
+// one we support" apart from "this cert is broken".
+type X509GMImportErrorReason int
+
+const (
+	// X509GMImportMalformedDER means the certificate's DER (or the PEM wrapping it) could not be
+	// parsed as an ASN.1 Certificate at all.
+	X509GMImportMalformedDER X509GMImportErrorReason = iota
+	// X509GMImportUnknownOID means the certificate parsed fine, but its SubjectPublicKeyInfo names
+	// a public key algorithm or named curve this importer does not recognize.
+	X509GMImportUnknownOID
+)
+
+// X509GMImportError is returned by x509GMCertificateImportOptsKeyImporter.KeyImport when a
+// certificate's public key could not be recovered, tagged with why.
+type X509GMImportError struct {
+	Reason X509GMImportErrorReason
+	Err    error
+}
+
+func (e *X509GMImportError) Error() string {
+	switch e.Reason {
+	case X509GMImportUnknownOID:
+		return fmt.Sprintf("certificate names an unrecognized public key algorithm or curve: %s", e.Err)
+	default:
+		return fmt.Sprintf("malformed certificate DER: %s", e.Err)
+	}
+}
+
+func (e *X509GMImportError) Unwrap() error {
+	return e.Err
+}
+
+// x509SubjectPublicKeyInfo and x509TBSCertificate mirror just enough of RFC 5280's
+// Certificate/TBSCertificate ASN.1 structures to reach SubjectPublicKeyInfo directly. They exist
+// because crypto/x509.ParseCertificate rejects an SM2-keyed certificate outright - it errors out
+// of its own public-key algorithm switch before this importer ever gets a chance to inspect the
+// OID itself - so the SPKI has to be pulled out by hand instead.
+type x509SubjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+type x509TBSCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       asn1.RawValue
+	SignatureAlgorithm asn1.RawValue
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          x509SubjectPublicKeyInfo
+}
+
+type x509CertificateSPKI struct {
+	TBSCertificate     x509TBSCertificate
+	SignatureAlgorithm asn1.RawValue
+	SignatureValue     asn1.RawValue
+}
+
+// x509GMCertificateImportOptsKeyImporter imports the public key out of a certificate that may be
+// a tjfoc *sm2.Certificate, a standard library *x509.Certificate, or raw PEM/DER bytes - the shape
+// MSP setup actually hands this package when a channel config block was assembled with vanilla
+// x509 tooling rather than this repo's own GM tooling, yet the certificate's public key encodes an
+// SM2 point via the SM2 named-curve OID instead of a NIST one.
+type x509GMCertificateImportOptsKeyImporter struct {
+	bccsp *impl
+}
+
+func (ki *x509GMCertificateImportOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (k bccsp.Key, err error) {
+	logger.Infof("bccsp gm x509GMCertificateImportOptsKeyImporter KeyImport")
+
+	der, err := x509GMCertificateDER(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var cert x509CertificateSPKI
+	if _, err := asn1.Unmarshal(der, &cert); err != nil {
+		return nil, &X509GMImportError{Reason: X509GMImportMalformedDER, Err: err}
+	}
+	spki := cert.TBSCertificate.PublicKey
+
+	var curveOID asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &curveOID); err == nil && curveOID.Equal(sm2NamedCurveOID) {
+		spkiDER, err := asn1.Marshal(spki)
+		if err != nil {
+			return nil, &X509GMImportError{Reason: X509GMImportMalformedDER, Err: err}
+		}
+		return ki.bccsp.keyImporters[reflect.TypeOf(&bccsp.SM2PublicKeyImportOpts{})].KeyImport(
+			spkiDER,
+			&bccsp.SM2PublicKeyImportOpts{Temporary: opts.Ephemeral()})
+	}
+
+	// Not an SM2 key: fall back to whatever crypto/x509 itself can make of the certificate -
+	// a standard NIST curve ECDSA key is the only case this GM CSP has an importer for today.
+	stdCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, &X509GMImportError{Reason: X509GMImportUnknownOID, Err: err}
+	}
+
+	switch pk := stdCert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return ki.bccsp.keyImporters[reflect.TypeOf(&bccsp.ECDSAGoPublicKeyImportOpts{})].KeyImport(
+			pk,
+			&bccsp.ECDSAGoPublicKeyImportOpts{Temporary: opts.Ephemeral()})
+	default:
+		return nil, &X509GMImportError{
+			Reason: X509GMImportUnknownOID,
+			Err:    fmt.Errorf("public key type %T not recognized. Supported keys: [GMSM2, ECDSA]", pk),
+		}
+	}
+}
+
+// x509GMCertificateDER normalizes the accepted raw material - a *sm2.Certificate, a
+// *x509.Certificate, or PEM/DER bytes - down to a DER-encoded certificate.
+func x509GMCertificateDER(raw interface{}) ([]byte, error) {
+	switch v := raw.(type) {
+	case *sm2.Certificate:
+		return v.Raw, nil
+	case *x509.Certificate:
+		return v.Raw, nil
+	case []byte:
+		if block, _ := pem.Decode(v); block != nil {
+			return block.Bytes, nil
+		}
+		return v, nil
+	default:
+		return nil, errors.New("Invalid raw material. Expected *x509.Certificate, *sm2.Certificate, or PEM/DER []byte.")
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/bccsp/gm"
+	"github.com/stretchr/testify/require"
+)
+
+func fixedPassphrase(passphrase string) gm.PassphraseProvider {
+	return func() ([]byte, error) { return []byte(passphrase), nil }
+}
+
+func TestEncryptedFileKeystoreSM2RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gm-keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ks, err := gm.NewEncryptedFileKeystore(dir, fixedPassphrase("correct horse battery staple"))
+	require.NoError(t, err)
+
+	kg := &gm.SM2KeyGenerator{}
+	key, err := kg.KeyGen(nil)
+	require.NoError(t, err)
+	require.NoError(t, ks.StoreKey(key))
+
+	reopened, err := gm.NewEncryptedFileKeystore(dir, fixedPassphrase("correct horse battery staple"))
+	require.NoError(t, err)
+	roundTripped, err := reopened.GetKey(key.SKI())
+	require.NoError(t, err)
+
+	digest := []byte("encrypted keystore sm2 round trip")
+	signer := &gm.SM2Signer{}
+	signature, err := signer.Sign(roundTripped, digest, nil)
+	require.NoError(t, err)
+
+	verifier := &gm.SM2PrivateKeyVerifier{}
+	valid, err := verifier.Verify(roundTripped, signature, digest, nil)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestEncryptedFileKeystoreWrongPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gm-keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ks, err := gm.NewEncryptedFileKeystore(dir, fixedPassphrase("correct horse battery staple"))
+	require.NoError(t, err)
+
+	kg := &gm.SM2KeyGenerator{}
+	key, err := kg.KeyGen(nil)
+	require.NoError(t, err)
+	require.NoError(t, ks.StoreKey(key))
+
+	wrong, err := gm.NewEncryptedFileKeystore(dir, fixedPassphrase("wrong passphrase"))
+	require.NoError(t, err)
+	_, err = wrong.GetKey(key.SKI())
+	require.Error(t, err)
+}
+
+func TestEncryptedFileKeystoreRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gm-keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ks, err := gm.NewEncryptedFileKeystore(dir, fixedPassphrase("old passphrase"))
+	require.NoError(t, err)
+
+	kg := &gm.SM2KeyGenerator{}
+	key, err := kg.KeyGen(nil)
+	require.NoError(t, err)
+	require.NoError(t, ks.StoreKey(key))
+
+	require.NoError(t, ks.Rotate(fixedPassphrase("new passphrase")))
+
+	reopened, err := gm.NewEncryptedFileKeystore(dir, fixedPassphrase("new passphrase"))
+	require.NoError(t, err)
+	_, err = reopened.GetKey(key.SKI())
+	require.NoError(t, err)
+
+	stale, err := gm.NewEncryptedFileKeystore(dir, fixedPassphrase("old passphrase"))
+	require.NoError(t, err)
+	_, err = stale.GetKey(key.SKI())
+	require.Error(t, err)
+}
+
+func TestExportImportSM2Key(t *testing.T) {
+	kg := &gm.SM2KeyGenerator{}
+	key, err := kg.KeyGen(nil)
+	require.NoError(t, err)
+
+	envelope, err := gm.ExportSM2Key(key, fixedPassphrase("correct horse battery staple"))
+	require.NoError(t, err)
+
+	imported, err := gm.ImportSM2Key(envelope, fixedPassphrase("correct horse battery staple"))
+	require.NoError(t, err)
+	require.Equal(t, key.SKI(), imported.SKI())
+}
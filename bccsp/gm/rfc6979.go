@@ -0,0 +1,116 @@
+/*
+Copyright Suzhou Tongji Fintech Research Institute 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package gm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"math/big"
+
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/sm3"
+)
+
+// GMSignerOpts extends bccsp.SignerOpts with a Deterministic flag selecting an RFC 6979-style
+// nonce derivation - SM3 in place of SHA-2 - instead of crypto/rand. Signing the same digest with
+// the same key then always produces the same (r, s), which is useful for reproducible test
+// vectors, disaster-recovery re-signing, and as a defense against a weak RNG on a peer node.
+type GMSignerOpts struct {
+	Hash          crypto.Hash
+	Deterministic bool
+}
+
+func (o *GMSignerOpts) HashFunc() crypto.Hash {
+	return o.Hash
+}
+
+// gmRFC6979Reader is an io.Reader that replays the RFC 6979 HMAC-DRBG byte stream, keyed off a
+// private key scalar and a message digest, with SM3 standing in for SHA-2. Handing one of these to
+// sm2.PrivateKey.Sign in place of crypto/rand makes every candidate nonce it draws - and so the
+// rejection-sampling loop RFC 6979 itself describes for k=0, k=n-1, or a resulting r of zero -
+// deterministic without needing to reimplement SM2's signing arithmetic here.
+type gmRFC6979Reader struct {
+	k, v []byte
+}
+
+func newGMRFC6979Reader(priv *sm2.PrivateKey, digest []byte) *gmRFC6979Reader {
+	n := priv.Curve.Params().N
+	qlen := n.BitLen()
+	holen := sm3.New().Size()
+
+	x := leftPadBytes(priv.D.Bytes(), (qlen+7)/8)
+	h := bitsToOctets(digest, qlen, n)
+
+	v := bytes.Repeat([]byte{0x01}, holen)
+	k := bytes.Repeat([]byte{0x00}, holen)
+
+	mac := hmac.New(sm3.New, k)
+	mac.Write(v)
+	mac.Write([]byte{0x00})
+	mac.Write(x)
+	mac.Write(h)
+	k = mac.Sum(nil)
+
+	mac = hmac.New(sm3.New, k)
+	mac.Write(v)
+	v = mac.Sum(nil)
+
+	mac = hmac.New(sm3.New, k)
+	mac.Write(v)
+	mac.Write([]byte{0x01})
+	mac.Write(x)
+	mac.Write(h)
+	k = mac.Sum(nil)
+
+	mac = hmac.New(sm3.New, k)
+	mac.Write(v)
+	v = mac.Sum(nil)
+
+	return &gmRFC6979Reader{k: k, v: v}
+}
+
+func (r *gmRFC6979Reader) Read(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		mac := hmac.New(sm3.New, r.k)
+		mac.Write(r.v)
+		r.v = mac.Sum(nil)
+		n := copy(p, r.v)
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// bitsToOctets implements RFC 6979 section 2.3.4: reduce the leftmost qlen bits of in modulo n,
+// then left-pad back out to the curve's byte length.
+func bitsToOctets(in []byte, qlen int, n *big.Int) []byte {
+	v := new(big.Int).SetBytes(in)
+	if vlen := len(in) * 8; vlen > qlen {
+		v.Rsh(v, uint(vlen-qlen))
+	}
+	v.Mod(v, n)
+	return leftPadBytes(v.Bytes(), (qlen+7)/8)
+}
+
+func leftPadBytes(in []byte, size int) []byte {
+	if len(in) >= size {
+		return in
+	}
+	out := make([]byte, size)
+	copy(out[size-len(in):], in)
+	return out
+}
@@ -77,9 +77,23 @@ func UnmarshalSM2Signature(raw []byte) (*big.Int, *big.Int, error) {
 	return sig.R, sig.S, nil
 }
 
+// SM2Sign does not normalize s the way ToLowS does for ECDSA: unlike ECDSA's
+// u1*G + u2*Q verification (which is insensitive to negating both u1 and u2, so (r, n-s) always
+// verifies alongside (r, s)), SM2 verification computes t = (r+s) mod n and checks
+// s*G + t*P against r, an equation that is not symmetric under s -> n-s. Replacing s with n-s
+// changes t and produces a different, generally invalid, curve point, so SM2 signatures must be
+// left exactly as SM2.Sign produces them.
+//
+// chunk14-1 asked for low-S normalization here; closed won't-fix for the reason above, since
+// adding it would silently invalidate roughly half of all valid SM2 signatures. There are no
+// SM2SignerOpts/RejectHighS/IsLowSM2S/ToLowSM2S references left anywhere in the tree.
 func SM2Sign(k *sm2.PrivateKey, digest []byte, opts bccsp.SignerOpts) (signature []byte, err error) {
-	signature, err = k.Sign(rand.Reader, digest, opts)
-	return
+	reader := rand.Reader
+	if o, ok := opts.(*GMSignerOpts); ok && o.Deterministic {
+		reader = newGMRFC6979Reader(k, digest)
+	}
+
+	return k.Sign(reader, digest, opts)
 }
 
 func SM2Verify(k *sm2.PublicKey, signature, digest []byte, opts bccsp.SignerOpts) (valid bool, err error) {
@@ -156,6 +170,21 @@ func (v *ecdsaPublicKeyKeyVerifier) Verify(k bccsp.Key, signature, digest []byte
 	return SM2Verify(&sm2pk, signature, digest, opts)
 }
 
+// VerifyKey verifies signature over digest using k, accepting either an SM2 public key or an SM2
+// private key (whose embedded public half is used then). It exists so a package outside bccsp/gm
+// that has already resolved a bccsp.Key by some other means - a JWS/COSE envelope's key ID lookup,
+// say - can verify against it without needing a full bccsp.BCCSP dispatch.
+func VerifyKey(k bccsp.Key, signature, digest []byte, opts bccsp.SignerOpts) (bool, error) {
+	switch pk := k.(type) {
+	case *SM2PublicKey:
+		return SM2Verify(pk.pubKey, signature, digest, opts)
+	case *SM2PrivateKey:
+		return SM2Verify(&pk.privKey.PublicKey, signature, digest, opts)
+	default:
+		return false, fmt.Errorf("unsupported key type %T for SM2 verification", k)
+	}
+}
+
 func SignatureToLowS(k *ecdsa.PublicKey, signature []byte) ([]byte, error) {
 	r, s, err := UnmarshalSM2Signature(signature)
 	if err != nil {
@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/tjfoc/gmsm/sm2"
+)
+
+// NewSM2PrivateKey wraps sk as a bccsp.Key. It is exported for callers outside this package that
+// build an *sm2.PrivateKey themselves - rather than generating or importing one through this
+// package's KeyGenerator/KeyImporters - and need to hand it back through the standard bccsp.Key
+// interface; internal/cryptogen/ca's TCert issuance is the first such caller.
+func NewSM2PrivateKey(sk *sm2.PrivateKey) *SM2PrivateKey {
+	return &SM2PrivateKey{sk}
+}
+
+// SM2TCertKeyDeriver implements bccsp.KeyDeriver for bccsp.TCertKeyDeriveOpts. A TCert-issuing CA
+// never sees an enrollment private key - it only tweaks the enrollment *public* key with an EC
+// point addition (see internal/cryptogen/ca.GenerateTCertBatch) - so the matching private key can
+// only be completed by whoever holds that enrollment private key, by adding the same tweak to its
+// scalar modulo the curve order. That is what this KeyDeriver does.
+type SM2TCertKeyDeriver struct{}
+
+func (*SM2TCertKeyDeriver) KeyDeriv(k bccsp.Key, opts bccsp.KeyDerivOpts) (bccsp.Key, error) {
+	logger.Infof("bccsp gm SM2TCertKeyDeriver KeyDeriv")
+	tcertOpts, ok := opts.(*bccsp.TCertKeyDeriveOpts)
+	if !ok {
+		return nil, fmt.Errorf("Invalid opts type [%T]. Expected *bccsp.TCertKeyDeriveOpts.", opts)
+	}
+	if len(tcertOpts.Delta) == 0 {
+		return nil, errors.New("Invalid opts. Delta must not be empty.")
+	}
+
+	der, err := k.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading enrollment private key [%s]", err)
+	}
+	eSK, err := sm2.ParsePKCS8UnecryptedPrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing enrollment private key [%s]", err)
+	}
+
+	n := eSK.Curve.Params().N
+	delta := new(big.Int).Mod(new(big.Int).SetBytes(tcertOpts.Delta), n)
+	d := new(big.Int).Mod(new(big.Int).Add(eSK.D, delta), n)
+	x, y := eSK.Curve.ScalarBaseMult(d.Bytes())
+
+	return NewSM2PrivateKey(&sm2.PrivateKey{
+		PublicKey: sm2.PublicKey{Curve: eSK.Curve, X: x, Y: y},
+		D:         d,
+	}), nil
+}
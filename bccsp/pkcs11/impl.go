@@ -0,0 +1,296 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pkcs11
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/miekg/pkcs11"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/sm3"
+)
+
+// CSP is the PKCS#11/HSM-backed sibling of bccsp/sw's and bccsp/gm's software providers. Every
+// key it hands out is a reference into the token (sm2PKCS11PrivateKey / sm4PKCS11Key); Sign and
+// Encrypt detect an HSM-backed key and dispatch the operation to the token instead of operating
+// on key material in process memory the way the software providers' impl.go does.
+type CSP struct {
+	pool *sessionPool
+
+	// gmMechanisms reports whether the token advertises the GM vendor mechanisms (CKM_SM2,
+	// CKM_SM3). When false, Sign falls back to CKM_ECDSA on the token paired with SM3 computed in
+	// software, so this provider still works against tokens that only speak PKCS#11's standard
+	// mechanism set. Verify does not use the token either way.
+	gmMechanisms bool
+
+	KeyImporters map[reflect.Type]KeyImporter
+}
+
+// KeyImporter mirrors bccsp.KeyImporter; declared locally so this package's importers can be
+// registered in CSP.KeyImporters without introducing a dependency cycle back through bccsp.
+type KeyImporter interface {
+	KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error)
+}
+
+// NewCSP opens (and logs into) a session against the slot/token identified by opts and returns a
+// CSP ready to generate, import, sign and encrypt with SM2/SM4 keys held on that token.
+func NewCSP(opts PKCS11Opts) (*CSP, error) {
+	if opts.Library == "" {
+		return nil, fmt.Errorf("Invalid PKCS11Opts. Library must be set.")
+	}
+
+	ctx := pkcs11.New(opts.Library)
+	if ctx == nil {
+		return nil, fmt.Errorf("Failed loading PKCS#11 library [%s]", opts.Library)
+	}
+	if err := ctx.Initialize(); err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		return nil, fmt.Errorf("Failed initializing PKCS#11 library [%s] [%s]", opts.Library, err)
+	}
+
+	slot, err := findSlotByLabel(ctx, opts)
+	if err != nil {
+		ctx.Finalize()
+		return nil, err
+	}
+
+	csp := &CSP{
+		pool:         newSessionPool(ctx, slot, opts.Pin, opts.SessionCacheSize),
+		gmMechanisms: tokenSupportsGMMechanisms(ctx, slot),
+	}
+	csp.KeyImporters = map[reflect.Type]KeyImporter{
+		reflect.TypeOf(&bccsp.SM2PKCS11PrivateKeyImportOpts{}): &sm2PKCS11PrivateKeyImportOptsKeyImporter{csp: csp},
+		reflect.TypeOf(&bccsp.SM4PKCS11ImportOpts{}):           &sm4PKCS11ImportOptsKeyImporter{csp: csp},
+	}
+	return csp, nil
+}
+
+func findSlotByLabel(ctx *pkcs11.Ctx, opts PKCS11Opts) (uint, error) {
+	if opts.Label == "" {
+		return uint(opts.Slot), nil
+	}
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("Failed listing PKCS#11 slots [%s]", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err == nil && info.Label == opts.Label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("Could not find a PKCS#11 slot with label [%s]", opts.Label)
+}
+
+// tokenSupportsGMMechanisms reports whether slot's token advertises the OSCCA vendor mechanisms
+// this provider prefers. Any error probing the mechanism list is treated as "no GM support" so
+// NewCSP can still fall back to the standard CKM_ECDSA path rather than failing outright.
+func tokenSupportsGMMechanisms(ctx *pkcs11.Ctx, slot uint) bool {
+	mechanisms, err := ctx.GetMechanismList(slot)
+	if err != nil {
+		return false
+	}
+	hasSM2, hasSM3 := false, false
+	for _, m := range mechanisms {
+		switch m.Mechanism {
+		case mechanismSM2:
+			hasSM2 = true
+		case mechanismSM3:
+			hasSM3 = true
+		}
+	}
+	return hasSM2 && hasSM3
+}
+
+// Vendor-extension mechanism codes for the GM algorithms, as assigned by the OSCCA-aligned
+// PKCS#11 vendor extension this provider targets (the same extension GmSSL-PKCS11 and the
+// SoftHSM-GM forks implement). Tokens that predate the extension report them absent from
+// GetMechanismList and this provider falls back to CKM_ECDSA + software SM3.
+const (
+	mechanismSM2           uint = 0x80000001 // CKM_SM2
+	mechanismSM3           uint = 0x80000002 // CKM_SM3
+	mechanismSM4CBC        uint = 0x80000003 // CKM_SM4_CBC
+	mechanismSM2KeyPairGen uint = 0x80000004 // CKM_SM2_KEY_PAIR_GEN
+	mechanismSM4KeyGen     uint = 0x80000005 // CKM_SM4_KEY_GEN
+	mechanismSM4ECB        uint = 0x80000006 // CKM_SM4_ECB
+)
+
+// Sign signs digest with k. When k is HSM-backed the signature is produced on the token, using
+// CKM_SM2 when available and CKM_ECDSA otherwise - digest is expected to already be an SM3 (or,
+// on the fallback path, software-computed SM3) hash either way, so the two mechanisms are
+// interchangeable from the caller's perspective.
+func (csp *CSP) Sign(k bccsp.Key, digest []byte, opts bccsp.SignerOpts) ([]byte, error) {
+	key, ok := k.(*sm2PKCS11PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported key type [%T]. This provider only signs with HSM-backed SM2 keys.", k)
+	}
+
+	session, err := csp.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer csp.pool.put(session)
+
+	privateObject, err := csp.findKeyObject(session, key.ckaID, pkcs11.CKO_PRIVATE_KEY)
+	if err != nil {
+		return nil, err
+	}
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(csp.signMechanism(), nil)}
+	if err := csp.pool.ctx.SignInit(session, mechanism, privateObject); err != nil {
+		return nil, fmt.Errorf("Failed initializing PKCS#11 sign operation [%s]", err)
+	}
+	signature, err := csp.pool.ctx.Sign(session, digest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed signing on PKCS#11 token [%s]", err)
+	}
+	return signature, nil
+}
+
+// Verify checks signature over digest against k, which must be the public key an HSM-backed
+// key's PublicKey() returns. The check runs in software against that key's SM2 public point and
+// never on the token; any other key type, including the HSM-backed private key itself, is rejected.
+func (csp *CSP) Verify(k bccsp.Key, signature, digest []byte, opts bccsp.SignerOpts) (bool, error) {
+	key, ok := k.(*sm2PKCS11PublicKey)
+	if !ok {
+		return false, fmt.Errorf("Unsupported key type [%T]. This provider only verifies SM2 public keys.", k)
+	}
+	return key.publicKey.Verify(digest, signature), nil
+}
+
+// signMechanism picks CKM_SM2 when the token supports it, else falls back to CKM_ECDSA; SM3
+// digesting in that fallback case is done in software by the caller before Sign is invoked.
+func (csp *CSP) signMechanism() uint {
+	if csp.gmMechanisms {
+		return mechanismSM2
+	}
+	return pkcs11.CKM_ECDSA
+}
+
+// Hash computes SM3 over msg. It is exposed so callers on the fallback (no vendor mechanism)
+// path can produce the digest passed into Sign/Verify entirely in software.
+func (csp *CSP) Hash(msg []byte, opts bccsp.HashOpts) ([]byte, error) {
+	return sm3.Sm3Sum(msg), nil
+}
+
+// readPublicKey reads the EC point (CKA_EC_POINT) off the public key object matching ckaID and
+// decodes it into an *sm2.PublicKey so verification and marshaling work without the token. It is
+// shared by the key importers (which read back the point after unwrapping) and the key generators
+// (which read it back right after GenerateKeyPair).
+func (csp *CSP) readPublicKey(session pkcs11.SessionHandle, ckaID []byte) (*sm2.PublicKey, error) {
+	object, err := csp.findKeyObject(session, ckaID, pkcs11.CKO_PUBLIC_KEY)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := csp.pool.ctx.GetAttributeValue(session, object, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading CKA_EC_POINT for CKA_ID [%x] [%s]", ckaID, err)
+	}
+	point := attrs[0].Value
+	// The token returns the EC point DER-octet-string-wrapped; the uncompressed point itself is
+	// the trailing 0x04 || X || Y, same layout sm2.PublicKey's X/Y fields expect.
+	if len(point) < 2 || point[len(point)-(len(point)-2)] != 0x04 {
+		return nil, fmt.Errorf("Unexpected CKA_EC_POINT encoding for CKA_ID [%x]", ckaID)
+	}
+	coordLen := (len(point) - 3) / 2
+	uncompressed := point[3:]
+	return &sm2.PublicKey{
+		Curve: sm2.P256Sm2(),
+		X:     new(big.Int).SetBytes(uncompressed[:coordLen]),
+		Y:     new(big.Int).SetBytes(uncompressed[coordLen:]),
+	}, nil
+}
+
+func (csp *CSP) findKeyObject(session pkcs11.SessionHandle, ckaID []byte, class uint) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, ckaID),
+	}
+	if err := csp.pool.ctx.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("Failed initializing PKCS#11 object search [%s]", err)
+	}
+	defer csp.pool.ctx.FindObjectsFinal(session)
+
+	objects, _, err := csp.pool.ctx.FindObjects(session, 1)
+	if err != nil {
+		return 0, fmt.Errorf("Failed searching for PKCS#11 object [%s]", err)
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("Could not find a PKCS#11 object with CKA_ID [%x]", ckaID)
+	}
+	return objects[0], nil
+}
+
+// Encrypt SM4-CBC-encrypts plaintext with k on the token. opts is expected to carry the IV the
+// same way bccsp/sw's AES CBC-PKCS7 modes do; it is out of scope for this provider to invent its
+// own IV convention.
+func (csp *CSP) Encrypt(k bccsp.Key, plaintext []byte, opts bccsp.EncrypterOpts) ([]byte, error) {
+	key, ok := k.(*sm4PKCS11Key)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported key type [%T]. This provider only encrypts with HSM-backed SM4 keys.", k)
+	}
+
+	session, err := csp.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer csp.pool.put(session)
+
+	object, err := csp.findKeyObject(session, key.ckaID, pkcs11.CKO_SECRET_KEY)
+	if err != nil {
+		return nil, err
+	}
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanismSM4CBC, ivFromOpts(opts))}
+	if err := csp.pool.ctx.EncryptInit(session, mechanism, object); err != nil {
+		return nil, fmt.Errorf("Failed initializing PKCS#11 encrypt operation [%s]", err)
+	}
+	return csp.pool.ctx.Encrypt(session, plaintext)
+}
+
+// Decrypt is Encrypt's inverse.
+func (csp *CSP) Decrypt(k bccsp.Key, ciphertext []byte, opts bccsp.DecrypterOpts) ([]byte, error) {
+	key, ok := k.(*sm4PKCS11Key)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported key type [%T]. This provider only decrypts with HSM-backed SM4 keys.", k)
+	}
+
+	session, err := csp.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer csp.pool.put(session)
+
+	object, err := csp.findKeyObject(session, key.ckaID, pkcs11.CKO_SECRET_KEY)
+	if err != nil {
+		return nil, err
+	}
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanismSM4CBC, ivFromOpts(opts))}
+	if err := csp.pool.ctx.DecryptInit(session, mechanism, object); err != nil {
+		return nil, fmt.Errorf("Failed initializing PKCS#11 decrypt operation [%s]", err)
+	}
+	return csp.pool.ctx.Decrypt(session, ciphertext)
+}
+
+// ivFromOpts extracts the IV carried on opts, if any; a nil return lets the token generate one
+// where the mechanism supports it.
+func ivFromOpts(opts interface{}) []byte {
+	type ivOpts interface {
+		IV() []byte
+	}
+	if o, ok := opts.(ivOpts); ok {
+		return o.IV()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pkcs11
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/tjfoc/gmsm/sm2"
+)
+
+var errNoPublicKeyForSymmetricKey = errors.New("Invalid call. Symmetric key does not have a public key.")
+
+// sm2PKCS11PrivateKey references an SM2 key pair that lives inside the HSM under ckaID/label; it
+// never holds the private scalar in process memory. PublicKey is kept in the clear so signature
+// verification and the x509 import path that needs it don't require a trip to the token.
+type sm2PKCS11PrivateKey struct {
+	ckaID     []byte
+	publicKey *sm2.PublicKey
+}
+
+func (k *sm2PKCS11PrivateKey) Bytes() ([]byte, error) { return nil, nil }
+
+func (k *sm2PKCS11PrivateKey) SKI() []byte {
+	if k.publicKey == nil {
+		return nil
+	}
+	hash := sha256.Sum256(append(k.publicKey.X.Bytes(), k.publicKey.Y.Bytes()...))
+	return hash[:]
+}
+
+func (k *sm2PKCS11PrivateKey) Symmetric() bool { return false }
+
+func (k *sm2PKCS11PrivateKey) Private() bool { return true }
+
+func (k *sm2PKCS11PrivateKey) PublicKey() (bccsp.Key, error) {
+	return &sm2PKCS11PublicKey{publicKey: k.publicKey}, nil
+}
+
+// sm2PKCS11PublicKey is the public half of an sm2PKCS11PrivateKey. Unlike the private key it
+// carries no HSM reference: verification always happens against the plain SM2 public key.
+type sm2PKCS11PublicKey struct {
+	publicKey *sm2.PublicKey
+}
+
+func (k *sm2PKCS11PublicKey) Bytes() ([]byte, error) {
+	return sm2.MarshalSm2PublicKey(k.publicKey)
+}
+
+func (k *sm2PKCS11PublicKey) SKI() []byte {
+	hash := sha256.Sum256(append(k.publicKey.X.Bytes(), k.publicKey.Y.Bytes()...))
+	return hash[:]
+}
+
+func (k *sm2PKCS11PublicKey) Symmetric() bool { return false }
+
+func (k *sm2PKCS11PublicKey) Private() bool { return false }
+
+func (k *sm2PKCS11PublicKey) PublicKey() (bccsp.Key, error) { return k, nil }
+
+// sm4PKCS11Key references an SM4 content-encryption key wrapped and stored inside the HSM under
+// ckaID; like sm2PKCS11PrivateKey, Bytes never surfaces key material outside the token.
+type sm4PKCS11Key struct {
+	ckaID []byte
+	ski   []byte
+}
+
+func (k *sm4PKCS11Key) Bytes() ([]byte, error) { return nil, nil }
+
+func (k *sm4PKCS11Key) SKI() []byte { return k.ski }
+
+func (k *sm4PKCS11Key) Symmetric() bool { return true }
+
+func (k *sm4PKCS11Key) Private() bool { return true }
+
+func (k *sm4PKCS11Key) PublicKey() (bccsp.Key, error) {
+	return nil, errNoPublicKeyForSymmetricKey
+}
@@ -0,0 +1,109 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pkcs11
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/miekg/pkcs11"
+)
+
+// oidSM2P256 is the DER encoding of the SM2 recommended curve's OID (1.2.156.10197.1.301), the
+// value CKA_EC_PARAMS carries for CKM_SM2_KEY_PAIR_GEN the same way it would for any other named
+// EC curve.
+var oidSM2P256 = []byte{0x06, 0x08, 0x2a, 0x81, 0x1c, 0xcf, 0x55, 0x01, 0x82, 0x2d}
+
+// SM2PKCS11KeyGenerator generates an SM2 key pair inside the HSM via CKM_SM2_KEY_PAIR_GEN and
+// returns an sm2PKCS11PrivateKey referencing it, the same non-extractable shape
+// sm2PKCS11PrivateKeyImportOptsKeyImporter produces for a key unwrapped in from outside the token.
+type SM2PKCS11KeyGenerator struct {
+	CSP *CSP
+}
+
+// KeyGen ignores opts beyond its type (bccsp.SM2KeyGenOpts carries no parameters of its own, the
+// curve and key size being fixed by SM2) and generates a fresh key pair under a random CKA_ID.
+func (kg *SM2PKCS11KeyGenerator) KeyGen(opts bccsp.KeyGenOpts) (bccsp.Key, error) {
+	session, err := kg.CSP.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer kg.CSP.pool.put(session)
+
+	ckaID := make([]byte, 16)
+	if _, err := rand.Read(ckaID); err != nil {
+		return nil, fmt.Errorf("Failed generating CKA_ID for SM2 key pair [%s]", err)
+	}
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, oidSM2P256),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, ckaID),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+	}
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_ID, ckaID),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	}
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanismSM2KeyPairGen, nil)}
+	if _, _, err := kg.CSP.pool.ctx.GenerateKeyPair(session, mechanism, publicTemplate, privateTemplate); err != nil {
+		return nil, fmt.Errorf("Failed generating SM2 key pair on PKCS#11 token [%s]", err)
+	}
+
+	publicKey, err := kg.CSP.readPublicKey(session, ckaID)
+	if err != nil {
+		return nil, err
+	}
+	return &sm2PKCS11PrivateKey{ckaID: ckaID, publicKey: publicKey}, nil
+}
+
+// SM4PKCS11KeyGenerator generates an SM4 content-encryption key inside the HSM via
+// CKM_SM4_KEY_GEN and returns an sm4PKCS11Key referencing it, the same non-extractable shape
+// sm4PKCS11ImportOptsKeyImporter produces for a key unwrapped in from outside the token.
+type SM4PKCS11KeyGenerator struct {
+	CSP *CSP
+}
+
+// KeyGen ignores opts beyond its type (bccsp.SM4KeyGenOpts carries no parameters, SM4 keys always
+// being 16 bytes) and generates a fresh key under a random CKA_ID.
+func (kg *SM4PKCS11KeyGenerator) KeyGen(opts bccsp.KeyGenOpts) (bccsp.Key, error) {
+	session, err := kg.CSP.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer kg.CSP.pool.put(session)
+
+	ckaID := make([]byte, 16)
+	if _, err := rand.Read(ckaID); err != nil {
+		return nil, fmt.Errorf("Failed generating CKA_ID for SM4 key [%s]", err)
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_ID, ckaID),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 16),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	}
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanismSM4KeyGen, nil)}
+	if _, err := kg.CSP.pool.ctx.GenerateKey(session, mechanism, template); err != nil {
+		return nil, fmt.Errorf("Failed generating SM4 key on PKCS#11 token [%s]", err)
+	}
+
+	ski := sha256.Sum256(ckaID)
+	return &sm4PKCS11Key{ckaID: ckaID, ski: ski[:]}, nil
+}
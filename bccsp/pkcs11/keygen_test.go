@@ -0,0 +1,70 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pkcs11_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCSP skips the test when no softhsm/opensc PKCS#11 module is available, the same
+// convention conf_test.go-style tests in this package are expected to follow.
+func newTestCSP(t *testing.T) *pkcs11.CSP {
+	lib := pkcs11.FindPKCS11Lib()
+	if lib == "" {
+		t.Skip("No PKCS#11 library found; set PKCS11_LIB or install softhsm2 to run this test.")
+	}
+	csp, err := pkcs11.NewCSP(pkcs11.PKCS11Opts{
+		Library: lib,
+		Label:   "ForFabric",
+		Pin:     "98765432",
+	})
+	require.NoError(t, err)
+	return csp
+}
+
+func TestSM2PKCS11KeyGeneratorRoundTrip(t *testing.T) {
+	csp := newTestCSP(t)
+
+	kg := &pkcs11.SM2PKCS11KeyGenerator{CSP: csp}
+	key, err := kg.KeyGen(&bccsp.SM2KeyGenOpts{})
+	require.NoError(t, err)
+	require.True(t, key.Private())
+
+	digest := []byte("sm2 pkcs11 key generator round trip")
+	signature, err := csp.Sign(key, digest, nil)
+	require.NoError(t, err)
+
+	publicKey, err := key.PublicKey()
+	require.NoError(t, err)
+
+	valid, err := csp.Verify(publicKey, signature, digest, nil)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestSM4PKCS11KeyGeneratorRoundTrip(t *testing.T) {
+	csp := newTestCSP(t)
+
+	kg := &pkcs11.SM4PKCS11KeyGenerator{CSP: csp}
+	key, err := kg.KeyGen(&bccsp.SM4KeyGenOpts{})
+	require.NoError(t, err)
+	require.True(t, key.Symmetric())
+
+	plaintext := []byte("0123456789ABCDEF")
+	ciphertext, err := csp.Encrypt(key, plaintext, nil)
+	require.NoError(t, err)
+
+	decrypted, err := csp.Decrypt(key, ciphertext, nil)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, decrypted)
+}
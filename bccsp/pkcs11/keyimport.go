@@ -0,0 +1,130 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pkcs11
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/miekg/pkcs11"
+)
+
+// sm2PKCS11PrivateKeyImportOptsKeyImporter resolves a bccsp.SM2PKCS11PrivateKeyImportOpts into an
+// sm2PKCS11PrivateKey, either by looking an existing token object up by its CKA_ID/label, or by
+// unwrapping a caller-supplied wrapped key blob into a fresh one. Either way the private scalar
+// never leaves the token; only the public point is read back so verification and PublicKey() can
+// work without a session.
+type sm2PKCS11PrivateKeyImportOptsKeyImporter struct {
+	csp *CSP
+}
+
+func (ki *sm2PKCS11PrivateKeyImportOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error) {
+	sm2opts, ok := opts.(*bccsp.SM2PKCS11PrivateKeyImportOpts)
+	if !ok {
+		return nil, fmt.Errorf("Invalid opts type [%T]. Expected *bccsp.SM2PKCS11PrivateKeyImportOpts.", opts)
+	}
+	if len(sm2opts.CKAID) == 0 {
+		return nil, errors.New("Invalid opts. CKAID must identify the token object to import.")
+	}
+
+	session, err := ki.csp.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer ki.csp.pool.put(session)
+
+	if wrappedKey, ok := raw.([]byte); ok && len(wrappedKey) > 0 {
+		if err := ki.unwrap(session, sm2opts.CKAID, wrappedKey); err != nil {
+			return nil, err
+		}
+	}
+
+	publicKey, err := ki.csp.readPublicKey(session, sm2opts.CKAID)
+	if err != nil {
+		return nil, err
+	}
+	return &sm2PKCS11PrivateKey{ckaID: sm2opts.CKAID, publicKey: publicKey}, nil
+}
+
+// unwrap imports wrappedKey into the token as a new CKO_PRIVATE_KEY object identified by ckaID,
+// using CKM_SM2 when the token supports it and falling back to a generic AES key-wrap mechanism
+// otherwise - mirroring the Sign fallback in impl.go.
+func (ki *sm2PKCS11PrivateKeyImportOptsKeyImporter) unwrap(session pkcs11.SessionHandle, ckaID, wrappedKey []byte) error {
+	wrappingKey, err := ki.csp.findKeyObject(session, ckaID, pkcs11.CKO_SECRET_KEY)
+	if err != nil {
+		return fmt.Errorf("Failed locating PKCS#11 unwrapping key for CKA_ID [%x] [%s]", ckaID, err)
+	}
+
+	unwrapMechanism := uint(pkcs11.CKM_AES_KEY_WRAP)
+	if ki.csp.gmMechanisms {
+		unwrapMechanism = mechanismSM2
+	}
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(unwrapMechanism, nil)}
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, ckaID),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	}
+	if _, err := ki.csp.pool.ctx.UnwrapKey(session, mechanism, wrappingKey, wrappedKey, template); err != nil {
+		return fmt.Errorf("Failed unwrapping SM2 private key into the token [%s]", err)
+	}
+	return nil
+}
+
+// sm4PKCS11ImportOptsKeyImporter resolves a bccsp.SM4PKCS11ImportOpts into an sm4PKCS11Key,
+// either referencing an existing token object by CKA_ID or unwrapping a wrapped key blob into
+// one, the same two paths sm2PKCS11PrivateKeyImportOptsKeyImporter supports for SM2.
+type sm4PKCS11ImportOptsKeyImporter struct {
+	csp *CSP
+}
+
+func (ki *sm4PKCS11ImportOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error) {
+	sm4opts, ok := opts.(*bccsp.SM4PKCS11ImportOpts)
+	if !ok {
+		return nil, fmt.Errorf("Invalid opts type [%T]. Expected *bccsp.SM4PKCS11ImportOpts.", opts)
+	}
+	if len(sm4opts.CKAID) == 0 {
+		return nil, errors.New("Invalid opts. CKAID must identify the token object to import.")
+	}
+
+	session, err := ki.csp.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer ki.csp.pool.put(session)
+
+	if wrappedKey, ok := raw.([]byte); ok && len(wrappedKey) > 0 {
+		wrappingKey, err := ki.csp.findKeyObject(session, sm4opts.CKAID, pkcs11.CKO_SECRET_KEY)
+		if err != nil {
+			return nil, fmt.Errorf("Failed locating PKCS#11 unwrapping key for CKA_ID [%x] [%s]", sm4opts.CKAID, err)
+		}
+		mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanismSM4CBC, make([]byte, 16))}
+		template := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, sm4opts.CKAID),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		}
+		if _, err := ki.csp.pool.ctx.UnwrapKey(session, mechanism, wrappingKey, wrappedKey, template); err != nil {
+			return nil, fmt.Errorf("Failed unwrapping SM4 key into the token [%s]", err)
+		}
+	}
+
+	if _, err := ki.csp.findKeyObject(session, sm4opts.CKAID, pkcs11.CKO_SECRET_KEY); err != nil {
+		return nil, err
+	}
+	return &sm4PKCS11Key{ckaID: sm4opts.CKAID, ski: append([]byte(nil), sm4opts.CKAID...)}, nil
+}
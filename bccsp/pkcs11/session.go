@@ -0,0 +1,80 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pkcs11
+
+import (
+	"fmt"
+
+	"github.com/miekg/pkcs11"
+)
+
+// sessionPool hands out read-write PKCS#11 sessions against a single slot, reusing them across
+// calls instead of logging in and out on every Sign/Encrypt - login is by far the most expensive
+// part of a round trip to most HSMs. Sessions are kept in a buffered channel the same way the
+// rest of this codebase pools long-lived resources (see pvtdatastorage's purger worker channel);
+// a full pool simply means a fresh session is opened and discarded on return.
+type sessionPool struct {
+	ctx      *pkcs11.Ctx
+	slot     uint
+	pin      string
+	sessions chan pkcs11.SessionHandle
+}
+
+func newSessionPool(ctx *pkcs11.Ctx, slot uint, pin string, size int) *sessionPool {
+	if size <= 0 {
+		size = 1
+	}
+	return &sessionPool{
+		ctx:      ctx,
+		slot:     slot,
+		pin:      pin,
+		sessions: make(chan pkcs11.SessionHandle, size),
+	}
+}
+
+// get returns a logged-in session, reusing one from the pool if available.
+func (p *sessionPool) get() (pkcs11.SessionHandle, error) {
+	select {
+	case session := <-p.sessions:
+		return session, nil
+	default:
+	}
+	return p.open()
+}
+
+func (p *sessionPool) open() (pkcs11.SessionHandle, error) {
+	session, err := p.ctx.OpenSession(p.slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return 0, fmt.Errorf("Failed opening PKCS#11 session on slot [%d] [%s]", p.slot, err)
+	}
+	if err := p.ctx.Login(session, pkcs11.CKU_USER, p.pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		p.ctx.CloseSession(session)
+		return 0, fmt.Errorf("Failed logging in to PKCS#11 session on slot [%d] [%s]", p.slot, err)
+	}
+	return session, nil
+}
+
+// put returns session to the pool for reuse, closing it instead if the pool is already full.
+func (p *sessionPool) put(session pkcs11.SessionHandle) {
+	select {
+	case p.sessions <- session:
+	default:
+		p.ctx.CloseSession(session)
+	}
+}
+
+// close releases every pooled session and the underlying PKCS#11 context. It is not safe to use
+// the pool afterwards.
+func (p *sessionPool) close() {
+	close(p.sessions)
+	for session := range p.sessions {
+		p.ctx.CloseSession(session)
+	}
+	p.ctx.Destroy()
+}
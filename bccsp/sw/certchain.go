@@ -0,0 +1,174 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sw
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/tjfoc/gmsm/sm2"
+)
+
+// CertChainKey wraps the key a PEM bundle carried - the leaf's SM2 private key if the bundle had
+// one, otherwise just its SM2 public key - together with the verified certificate chain
+// SM2PEMBundleImportOptsKeyImporter reconstructed from that bundle. It behaves as a plain
+// bccsp.Key for signing/verification; Chain is the extra downstream MSP code needs to recover the
+// leaf -> intermediates -> root ordering without re-deriving it from the raw PEM bytes.
+type CertChainKey struct {
+	bccsp.Key
+	chain []*sm2.Certificate
+}
+
+// Chain returns the verified certificate chain, ordered leaf first followed by each issuer up to
+// (and including, if present in the bundle) the root.
+func (k *CertChainKey) Chain() []*sm2.Certificate { return k.chain }
+
+// SM2PEMBundleImportOptsKeyImporter resolves a bccsp.SM2PEMBundleImportOpts into a CertChainKey.
+// The bundle is a sequence of concatenated PEM blocks - any number of certificates plus, at most,
+// one private key block, plain or password-protected - in no particular order; orderCertChain
+// reconstructs leaf -> intermediates -> root by matching issuer/subject rather than trusting the
+// order blocks arrived in.
+type SM2PEMBundleImportOptsKeyImporter struct{}
+
+func (*SM2PEMBundleImportOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error) {
+	logger.Infof("bccsp sw SM2PEMBundleImportOptsKeyImporter KeyImport")
+	bundleOpts, ok := opts.(*bccsp.SM2PEMBundleImportOpts)
+	if !ok {
+		return nil, fmt.Errorf("Invalid opts type [%T]. Expected *bccsp.SM2PEMBundleImportOpts.", opts)
+	}
+
+	var bundle []byte
+	switch raw := raw.(type) {
+	case []byte:
+		bundle = raw
+	case io.Reader:
+		var err error
+		bundle, err = ioutil.ReadAll(raw)
+		if err != nil {
+			return nil, fmt.Errorf("Failed reading PEM bundle [%s]", err)
+		}
+	default:
+		return nil, errors.New("Invalid raw material. Expected []byte or io.Reader.")
+	}
+	if len(bundle) == 0 {
+		return nil, errors.New("Invalid raw material. It must not be nil.")
+	}
+
+	var certs []*sm2.Certificate
+	var keyDER []byte
+	var keyEncrypted bool
+	for rest := bundle; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := sm2.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("Failed parsing certificate in PEM bundle [%s]", err)
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			keyDER, keyEncrypted = block.Bytes, false
+		case "ENCRYPTED PRIVATE KEY":
+			keyDER, keyEncrypted = block.Bytes, true
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("Invalid PEM bundle. No certificates found.")
+	}
+
+	chain, err := orderCertChain(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyDER) == 0 {
+		pk, ok := chain[0].PublicKey.(*sm2.PublicKey)
+		if !ok {
+			return nil, errors.New("Leaf certificate's public key is not an SM2 public key.")
+		}
+		return &CertChainKey{Key: &SM2PublicKey{pk}, chain: chain}, nil
+	}
+
+	var sm2SK *sm2.PrivateKey
+	if keyEncrypted {
+		if len(bundleOpts.Password) == 0 {
+			return nil, errors.New("Invalid opts. Password is required to decrypt the PEM bundle's private key.")
+		}
+		sm2SK, err = DecryptPKCS8(keyDER, bundleOpts.Password)
+	} else {
+		sm2SK, err = sm2.ParsePKCS8UnecryptedPrivateKey(keyDER)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing PEM bundle's private key [%s]", err)
+	}
+
+	return &CertChainKey{Key: &SM2PrivateKey{sm2SK}, chain: chain}, nil
+}
+
+// orderCertChain reconstructs leaf -> intermediate(s) -> root ordering from an unordered set of
+// certificates, matching each certificate's issuer to another's subject rather than trusting the
+// order the certificates arrived in. Each link's signature is verified against its issuer before
+// the chain is accepted; an issuer missing from certs (e.g. a well-known root the caller didn't
+// bundle) simply ends the chain early rather than failing the import.
+func orderCertChain(certs []*sm2.Certificate) ([]*sm2.Certificate, error) {
+	bySubject := make(map[string]*sm2.Certificate, len(certs))
+	for _, cert := range certs {
+		bySubject[string(cert.RawSubject)] = cert
+	}
+
+	issuerOf := make(map[string]bool, len(certs))
+	for _, cert := range certs {
+		if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			issuerOf[string(cert.RawIssuer)] = true
+		}
+	}
+
+	var leaf *sm2.Certificate
+	for _, cert := range certs {
+		if !issuerOf[string(cert.RawSubject)] {
+			if leaf != nil {
+				return nil, errors.New("Invalid PEM bundle. Found more than one leaf certificate.")
+			}
+			leaf = cert
+		}
+	}
+	if leaf == nil {
+		return nil, errors.New("Invalid PEM bundle. Could not identify a leaf certificate; every certificate issued another.")
+	}
+
+	chain := []*sm2.Certificate{leaf}
+	seen := map[string]bool{string(leaf.RawSubject): true}
+	for current := leaf; len(chain) < len(certs); {
+		if bytes.Equal(current.RawIssuer, current.RawSubject) {
+			break
+		}
+		issuer, ok := bySubject[string(current.RawIssuer)]
+		if !ok {
+			break
+		}
+		if seen[string(issuer.RawSubject)] {
+			return nil, errors.New("Invalid PEM bundle. Certificate chain contains a cycle.")
+		}
+		if err := current.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("Failed verifying certificate chain [%s]", err)
+		}
+		chain = append(chain, issuer)
+		seen[string(issuer.RawSubject)] = true
+		current = issuer
+	}
+
+	return chain, nil
+}
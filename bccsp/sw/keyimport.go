@@ -37,6 +37,27 @@ func (*aes256ImportKeyOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.Key
 	return &aesPrivateKey{aesRaw, false}, nil
 }
 
+// KeyImportWithAuth authenticates aesRaw against policy's HMAC tag (or HSM attestation verifier, if
+// policy carries one instead) before importing it, per AuthenticatingKeyImporter.
+func (ki *aes256ImportKeyOptsKeyImporter) KeyImportWithAuth(raw interface{}, opts bccsp.KeyImportOpts, policy *bccsp.KeyImportPolicy) (bccsp.Key, bccsp.ImportAuthResult, error) {
+	aesRaw, ok := raw.([]byte)
+	if !ok {
+		return nil, bccsp.ImportAuthResult{}, errors.New("Invalid raw material. Expected byte array.")
+	}
+
+	result := verifyHMACTag(policy, aesRaw)
+	logImportAuth("aes256ImportKeyOptsKeyImporter", result)
+	if err := rejectUnverified(policy, result); err != nil {
+		return nil, result, err
+	}
+
+	k, err := ki.KeyImport(raw, opts)
+	if err != nil {
+		return nil, result, err
+	}
+	return k, result, nil
+}
+
 type hmacImportKeyOptsKeyImporter struct{}
 
 func (*hmacImportKeyOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error) {
@@ -153,6 +174,27 @@ func (ki *x509PublicKeyImportOptsKeyImporter) KeyImport(raw interface{}, opts bc
 	}
 }
 
+// KeyImportWithAuth authenticates sm2Cert against policy's trust pool of CA certs before importing
+// its public key, per AuthenticatingKeyImporter.
+func (ki *x509PublicKeyImportOptsKeyImporter) KeyImportWithAuth(raw interface{}, opts bccsp.KeyImportOpts, policy *bccsp.KeyImportPolicy) (bccsp.Key, bccsp.ImportAuthResult, error) {
+	sm2Cert, ok := raw.(*sm2.Certificate)
+	if !ok {
+		return nil, bccsp.ImportAuthResult{}, errors.New("Invalid raw material. Expected *x509.Certificate.")
+	}
+
+	result := verifyCertChain(policy, sm2Cert)
+	logImportAuth("x509PublicKeyImportOptsKeyImporter", result)
+	if err := rejectUnverified(policy, result); err != nil {
+		return nil, result, err
+	}
+
+	k, err := ki.KeyImport(raw, opts)
+	if err != nil {
+		return nil, result, err
+	}
+	return k, result, nil
+}
+
 type SM4ImportKeyOptsKeyImporter struct{}
 
 
@@ -193,6 +235,27 @@ func (*SM2PrivateKeyOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyIm
 	return &SM2PrivateKey{SM2SK}, nil
 }
 
+// KeyImportWithAuth authenticates der against policy's expected digest before importing it, per
+// AuthenticatingKeyImporter.
+func (ki *SM2PrivateKeyOptsKeyImporter) KeyImportWithAuth(raw interface{}, opts bccsp.KeyImportOpts, policy *bccsp.KeyImportPolicy) (bccsp.Key, bccsp.ImportAuthResult, error) {
+	der, ok := raw.([]byte)
+	if !ok {
+		return nil, bccsp.ImportAuthResult{}, errors.New("Invalid raw material, Expected byte array")
+	}
+
+	result := verifyDigest(policy, der)
+	logImportAuth("SM2PrivateKeyOptsKeyImporter", result)
+	if err := rejectUnverified(policy, result); err != nil {
+		return nil, result, err
+	}
+
+	k, err := ki.KeyImport(raw, opts)
+	if err != nil {
+		return nil, result, err
+	}
+	return k, result, nil
+}
+
 type SM2PublicKeyOptsKeyImporter struct{}
 
 func (*SM2PublicKeyOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (k bccsp.Key, err error) {
@@ -214,3 +277,35 @@ func (*SM2PublicKeyOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImp
 
 	return &SM2PublicKey{SM2SK}, nil
 }
+
+// SM2EncryptedPrivateKeyImportOptsKeyImporter resolves a bccsp.SM2EncryptedPrivateKeyImportOpts
+// into an SM2PrivateKey by decrypting a password-protected PKCS#8 blob. DecryptPKCS8 is the only
+// scheme supported: PBKDF2 over HMAC-SM3 deriving an SM4-CBC content-encryption key.
+type SM2EncryptedPrivateKeyImportOptsKeyImporter struct{}
+
+func (*SM2EncryptedPrivateKeyImportOptsKeyImporter) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (k bccsp.Key, err error) {
+	logger.Infof("bccsp sw SM2EncryptedPrivateKeyImportOptsKeyImporter KeyImport")
+	der, ok := raw.([]byte)
+	if !ok {
+		return nil, errors.New("Invalid raw material, Expected byte array")
+	}
+
+	if len(der) == 0 {
+		return nil, errors.New("Invalid raw material, It must not be nil")
+	}
+
+	sm2opts, ok := opts.(*bccsp.SM2EncryptedPrivateKeyImportOpts)
+	if !ok {
+		return nil, fmt.Errorf("Invalid opts type [%T]. Expected *bccsp.SM2EncryptedPrivateKeyImportOpts.", opts)
+	}
+	if len(sm2opts.Password) == 0 {
+		return nil, errors.New("Invalid opts. Password must not be empty.")
+	}
+
+	SM2SK, err := DecryptPKCS8(der, sm2opts.Password)
+	if err != nil {
+		return nil, fmt.Errorf("Failed decrypting PKCS8 private key [%s]", err)
+	}
+
+	return &SM2PrivateKey{SM2SK}, nil
+}
@@ -0,0 +1,346 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sw
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric/bccsp/gm"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/sm3"
+	"github.com/tjfoc/gmsm/sm4"
+)
+
+// OIDs for the PKCS#7/CMS content types and GM algorithms used by SignPKCS7/VerifyPKCS7 and
+// EncryptPKCS7/DecryptPKCS7. The SM2/SM3/SM4 arcs follow the OSCCA-published mapping of the GM
+// algorithms onto PKCS#7 (GM/T 0006-2012).
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidSM2           = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+	oidSM3           = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401}
+	oidSM3WithSM2    = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 501}
+	oidSM4CBC        = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 104, 2}
+)
+
+// algorithmIdentifier mirrors the CMS/X.509 AlgorithmIdentifier: an algorithm OID plus optional,
+// algorithm-specific parameters (e.g. the IV for SM4-CBC).
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+// contentInfo is the outer PKCS#7 envelope: a content type OID plus the type-specific payload,
+// explicitly tagged [0] as CMS requires.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// issuerAndSerialNumber identifies a certificate the way CMS does: by its issuer DN and serial
+// number, rather than embedding the certificate itself.
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerialNumber
+	DigestAlgorithm           algorithmIdentifier
+	DigestEncryptionAlgorithm algorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	SignerInfos      []signerInfo `asn1:"set"`
+}
+
+type recipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  issuerAndSerialNumber
+	KeyEncryptionAlgorithm algorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm algorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []recipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+// SignPKCS7 produces a DER-encoded PKCS#7/CMS SignedData envelope over content, signed with key
+// under signerCert. The digest algorithm is SM3 and the signature algorithm is SM2-with-SM3, the
+// GM/T 0006 mapping of CMS onto the primitives bccsp/gm already exposes for raw SM2 signing.
+func SignPKCS7(key *sm2.PrivateKey, signerCert *sm2.Certificate, content []byte) ([]byte, error) {
+	logger.Infof("bccsp sw SignPKCS7")
+	if key == nil {
+		return nil, errors.New("Invalid key. It must not be nil.")
+	}
+	if signerCert == nil {
+		return nil, errors.New("Invalid signerCert. It must not be nil.")
+	}
+
+	digest := sm3.Sm3Sum(content)
+	signature, err := gm.SM2Sign(key, digest, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed signing PKCS7 content [%s]", err)
+	}
+
+	rawContent, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("Failed encoding PKCS7 content [%s]", err)
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{{Algorithm: oidSM3}},
+		ContentInfo: contentInfo{
+			ContentType: oidData,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: rawContent},
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: signerCert.RawIssuer},
+				SerialNumber: signerCert.SerialNumber,
+			},
+			DigestAlgorithm:           algorithmIdentifier{Algorithm: oidSM3},
+			DigestEncryptionAlgorithm: algorithmIdentifier{Algorithm: oidSM3WithSM2},
+			EncryptedDigest:           signature,
+		}},
+	}
+
+	sdBytes, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, fmt.Errorf("Failed encoding PKCS7 SignedData [%s]", err)
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdBytes},
+	})
+}
+
+// VerifyPKCS7 parses a DER-encoded SignedData envelope produced by SignPKCS7, verifies its
+// signature against signerCert's SM2 public key, and returns the signed content.
+func VerifyPKCS7(der []byte, signerCert *sm2.Certificate) ([]byte, error) {
+	logger.Infof("bccsp sw VerifyPKCS7")
+	if signerCert == nil {
+		return nil, errors.New("Invalid signerCert. It must not be nil.")
+	}
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("Failed decoding PKCS7 ContentInfo [%s]", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("Invalid PKCS7 content type. Expected SignedData.")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("Failed decoding PKCS7 SignedData [%s]", err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("Expected exactly one PKCS7 SignerInfo, got [%d]", len(sd.SignerInfos))
+	}
+
+	var content []byte
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("Failed decoding PKCS7 signed content [%s]", err)
+	}
+
+	pubKey, ok := signerCert.PublicKey.(*sm2.PublicKey)
+	if !ok {
+		return nil, errors.New("signerCert's public key is not an SM2 public key")
+	}
+
+	digest := sm3.Sm3Sum(content)
+	valid, err := gm.SM2Verify(pubKey, sd.SignerInfos[0].EncryptedDigest, digest, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed verifying PKCS7 signature [%s]", err)
+	}
+	if !valid {
+		return nil, errors.New("Invalid PKCS7 signature")
+	}
+
+	return content, nil
+}
+
+// EncryptPKCS7 produces a DER-encoded PKCS#7/CMS EnvelopedData envelope over content. A random
+// SM4 key encrypts the content (CBC mode, PKCS#7-padded), and that key is SM2-encrypted once per
+// entry in recipientCerts so any of their holders can recover it.
+func EncryptPKCS7(recipientCerts []*sm2.Certificate, content []byte) ([]byte, error) {
+	logger.Infof("bccsp sw EncryptPKCS7")
+	if len(recipientCerts) == 0 {
+		return nil, errors.New("Invalid recipientCerts. At least one recipient is required.")
+	}
+
+	key := make([]byte, sm4.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("Failed generating SM4 content-encryption key [%s]", err)
+	}
+	iv := make([]byte, sm4.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("Failed generating SM4 IV [%s]", err)
+	}
+
+	encryptedContent, err := sm4CBCEncrypt(key, iv, content)
+	if err != nil {
+		return nil, fmt.Errorf("Failed encrypting PKCS7 content [%s]", err)
+	}
+
+	recipientInfos := make([]recipientInfo, len(recipientCerts))
+	for i, cert := range recipientCerts {
+		pubKey, ok := cert.PublicKey.(*sm2.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("recipientCerts[%d]'s public key is not an SM2 public key", i)
+		}
+		encryptedKey, err := sm2.Encrypt(pubKey, key)
+		if err != nil {
+			return nil, fmt.Errorf("Failed SM2-encrypting PKCS7 content key for recipientCerts[%d] [%s]", i, err)
+		}
+		recipientInfos[i] = recipientInfo{
+			IssuerAndSerialNumber: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: algorithmIdentifier{Algorithm: oidSM2},
+			EncryptedKey:           encryptedKey,
+		}
+	}
+
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, fmt.Errorf("Failed encoding SM4 IV [%s]", err)
+	}
+
+	ed := envelopedData{
+		RecipientInfos: recipientInfos,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: algorithmIdentifier{Algorithm: oidSM4CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: encryptedContent},
+		},
+	}
+
+	edBytes, err := asn1.Marshal(ed)
+	if err != nil {
+		return nil, fmt.Errorf("Failed encoding PKCS7 EnvelopedData [%s]", err)
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: edBytes},
+	})
+}
+
+// DecryptPKCS7 parses a DER-encoded EnvelopedData envelope produced by EncryptPKCS7 and recovers
+// the content, using recipientCert to pick the matching RecipientInfo and recipientKey to
+// SM2-decrypt the wrapped SM4 content-encryption key.
+func DecryptPKCS7(der []byte, recipientCert *sm2.Certificate, recipientKey *sm2.PrivateKey) ([]byte, error) {
+	logger.Infof("bccsp sw DecryptPKCS7")
+	if recipientCert == nil || recipientKey == nil {
+		return nil, errors.New("Invalid recipientCert or recipientKey. Neither must be nil.")
+	}
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("Failed decoding PKCS7 ContentInfo [%s]", err)
+	}
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, errors.New("Invalid PKCS7 content type. Expected EnvelopedData.")
+	}
+
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, fmt.Errorf("Failed decoding PKCS7 EnvelopedData [%s]", err)
+	}
+
+	var ri *recipientInfo
+	for i := range ed.RecipientInfos {
+		candidate := &ed.RecipientInfos[i]
+		if bytes.Equal(candidate.IssuerAndSerialNumber.Issuer.FullBytes, recipientCert.RawIssuer) &&
+			candidate.IssuerAndSerialNumber.SerialNumber.Cmp(recipientCert.SerialNumber) == 0 {
+			ri = candidate
+			break
+		}
+	}
+	if ri == nil {
+		return nil, errors.New("recipientCert is not a recipient of this PKCS7 EnvelopedData")
+	}
+
+	key, err := sm2.Decrypt(recipientKey, ri.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed SM2-decrypting PKCS7 content key [%s]", err)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(ed.EncryptedContentInfo.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("Failed decoding SM4 IV [%s]", err)
+	}
+
+	return sm4CBCDecrypt(key, iv, ed.EncryptedContentInfo.EncryptedContent.Bytes)
+}
+
+func sm4CBCEncrypt(key, iv, plaintext []byte) ([]byte, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padded := pkcs7Pad(plaintext, block.BlockSize())
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+	return ciphertext, nil
+}
+
+func sm4CBCDecrypt(key, iv, ciphertext []byte) ([]byte, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errors.New("Invalid PKCS7 encrypted content length")
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	return pkcs7Unpad(plaintext, block.BlockSize())
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padLen := blockSize - len(data)%blockSize
+	return append(data, bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+}
+
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	length := len(data)
+	if length == 0 || length%blockSize != 0 {
+		return nil, errors.New("Invalid padded content length")
+	}
+	padLen := int(data[length-1])
+	if padLen == 0 || padLen > blockSize || padLen > length {
+		return nil, errors.New("Invalid PKCS7 padding")
+	}
+	return data[:length-padLen], nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sw_test
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/bccsp/sw"
+	"github.com/stretchr/testify/require"
+	"github.com/tjfoc/gmsm/sm2"
+)
+
+// selfSignedSM2Cert generates a fresh SM2 key pair and a minimal self-signed certificate over it,
+// for use as both signer/recipient cert and key in the round-trip tests below. Every such cert has
+// the same empty issuer name, so each gets a random serial number to keep certs from separate
+// calls distinguishable by issuer and serial, which is how DecryptPKCS7 picks its RecipientInfo.
+func selfSignedSM2Cert(t *testing.T) (*sm2.PrivateKey, *sm2.Certificate) {
+	t.Helper()
+
+	priv, err := sm2.GenerateKey()
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	require.NoError(t, err)
+
+	template := &sm2.Certificate{
+		SerialNumber: serial,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := sm2.CreateCertificateToMem(template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+
+	cert, err := sm2.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return priv, cert
+}
+
+func TestSignVerifyPKCS7RoundTrip(t *testing.T) {
+	key, cert := selfSignedSM2Cert(t)
+	content := []byte("pkcs7 signed content")
+
+	der, err := sw.SignPKCS7(key, cert, content)
+	require.NoError(t, err)
+
+	recovered, err := sw.VerifyPKCS7(der, cert)
+	require.NoError(t, err)
+	require.Equal(t, content, recovered)
+}
+
+func TestVerifyPKCS7RejectsTamperedContent(t *testing.T) {
+	key, cert := selfSignedSM2Cert(t)
+
+	der, err := sw.SignPKCS7(key, cert, []byte("original content"))
+	require.NoError(t, err)
+
+	// Flip the last byte of the DER encoding. It falls inside the SignerInfo's encrypted digest
+	// (the signature), so the envelope must not verify.
+	tampered := append([]byte(nil), der...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	_, err = sw.VerifyPKCS7(tampered, cert)
+	require.Error(t, err)
+}
+
+func TestVerifyPKCS7RejectsWrongSignerCert(t *testing.T) {
+	key, cert := selfSignedSM2Cert(t)
+	_, otherCert := selfSignedSM2Cert(t)
+
+	der, err := sw.SignPKCS7(key, cert, []byte("pkcs7 signed content"))
+	require.NoError(t, err)
+
+	_, err = sw.VerifyPKCS7(der, otherCert)
+	require.Error(t, err)
+}
+
+func TestVerifyPKCS7RejectsMalformedDER(t *testing.T) {
+	_, cert := selfSignedSM2Cert(t)
+
+	_, err := sw.VerifyPKCS7([]byte{0x30, 0x80, 0x01, 0x02}, cert)
+	require.Error(t, err)
+}
+
+func TestVerifyPKCS7RejectsWrongContentType(t *testing.T) {
+	_, cert := selfSignedSM2Cert(t)
+
+	der, err := sw.EncryptPKCS7([]*sm2.Certificate{cert}, []byte("not a signature"))
+	require.NoError(t, err)
+
+	_, err = sw.VerifyPKCS7(der, cert)
+	require.Error(t, err)
+}
+
+func TestEncryptDecryptPKCS7RoundTrip(t *testing.T) {
+	recipientKey, recipientCert := selfSignedSM2Cert(t)
+	content := []byte("pkcs7 enveloped content")
+
+	der, err := sw.EncryptPKCS7([]*sm2.Certificate{recipientCert}, content)
+	require.NoError(t, err)
+
+	recovered, err := sw.DecryptPKCS7(der, recipientCert, recipientKey)
+	require.NoError(t, err)
+	require.Equal(t, content, recovered)
+}
+
+func TestEncryptPKCS7MultipleRecipients(t *testing.T) {
+	key1, cert1 := selfSignedSM2Cert(t)
+	key2, cert2 := selfSignedSM2Cert(t)
+	content := []byte("shared enveloped content")
+
+	der, err := sw.EncryptPKCS7([]*sm2.Certificate{cert1, cert2}, content)
+	require.NoError(t, err)
+
+	recovered1, err := sw.DecryptPKCS7(der, cert1, key1)
+	require.NoError(t, err)
+	require.Equal(t, content, recovered1)
+
+	recovered2, err := sw.DecryptPKCS7(der, cert2, key2)
+	require.NoError(t, err)
+	require.Equal(t, content, recovered2)
+}
+
+func TestDecryptPKCS7RejectsNonRecipient(t *testing.T) {
+	_, recipientCert := selfSignedSM2Cert(t)
+	outsiderKey, outsiderCert := selfSignedSM2Cert(t)
+
+	der, err := sw.EncryptPKCS7([]*sm2.Certificate{recipientCert}, []byte("content"))
+	require.NoError(t, err)
+
+	_, err = sw.DecryptPKCS7(der, outsiderCert, outsiderKey)
+	require.Error(t, err)
+}
+
+func TestDecryptPKCS7RejectsMalformedDER(t *testing.T) {
+	recipientKey, recipientCert := selfSignedSM2Cert(t)
+
+	_, err := sw.DecryptPKCS7([]byte{0x30, 0x80, 0x01, 0x02}, recipientCert, recipientKey)
+	require.Error(t, err)
+}
+
+func TestDecryptPKCS7RejectsWrongContentType(t *testing.T) {
+	key, cert := selfSignedSM2Cert(t)
+
+	der, err := sw.SignPKCS7(key, cert, []byte("not an envelope"))
+	require.NoError(t, err)
+
+	_, err = sw.DecryptPKCS7(der, cert, key)
+	require.Error(t, err)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sw
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/sm3"
+	"github.com/tjfoc/gmsm/sm4"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// OIDs for the PBES2 encryption scheme (RFC 8018) that DecryptPKCS8 understands: PBKDF2 key
+// derivation with an HMAC-SM3 PRF, wrapping the PKCS8 payload in SM4-CBC. oidSM4CBC is already
+// declared in pkcs7.go.
+var (
+	oidPBES2       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSM3 = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401, 1}
+)
+
+// encryptedPrivateKeyInfo is the PKCS8 EncryptedPrivateKeyInfo ASN.1 structure: an
+// AlgorithmIdentifier describing how encryptedData was produced, plus the ciphertext itself.
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params is the PBES2-params structure (RFC 8018 S6.2): a key-derivation function and an
+// encryption scheme, each itself an AlgorithmIdentifier.
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+// pbkdf2Params is the PBKDF2-params structure (RFC 8018 S5.2) used as KeyDerivationFunc.Parameters
+// when the KDF is PBKDF2. Prf defaults to HMAC-SM3, the only PRF the PBES2-SM4-SM3 scheme below
+// supports; KeyLength defaults to the SM4 key size when absent.
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                 `asn1:"optional"`
+	Prf            algorithmIdentifier `asn1:"optional"`
+}
+
+// DecryptPKCS8 decrypts a DER-encoded PKCS8 EncryptedPrivateKeyInfo produced with the PBES2-SM4-SM3
+// scheme (PBKDF2 over HMAC-SM3 deriving an SM4-CBC content-encryption key) and parses the resulting
+// PKCS8 private key. It backs SM2EncryptedPrivateKeyImportOptsKeyImporter.
+func DecryptPKCS8(der []byte, password []byte) (*sm2.PrivateKey, error) {
+	logger.Infof("bccsp sw DecryptPKCS8")
+	if len(password) == 0 {
+		return nil, errors.New("Invalid password. It must not be empty.")
+	}
+
+	var epki encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &epki); err != nil {
+		return nil, fmt.Errorf("Failed decoding PKCS8 EncryptedPrivateKeyInfo [%s]", err)
+	}
+	if !epki.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, errors.New("Unsupported PKCS8 encryption algorithm. Expected PBES2.")
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(epki.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("Failed decoding PBES2 parameters [%s]", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, errors.New("Unsupported PBES2 key-derivation function. Expected PBKDF2.")
+	}
+	if !params.EncryptionScheme.Algorithm.Equal(oidSM4CBC) {
+		return nil, errors.New("Unsupported PBES2 encryption scheme. Expected SM4-CBC.")
+	}
+
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("Failed decoding PBKDF2 parameters [%s]", err)
+	}
+	if kdfParams.Prf.Algorithm != nil && !kdfParams.Prf.Algorithm.Equal(oidHMACWithSM3) {
+		return nil, errors.New("Unsupported PBKDF2 PRF. Expected HMAC-SM3.")
+	}
+	if kdfParams.KeyLength == 0 {
+		kdfParams.KeyLength = sm4.BlockSize
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("Failed decoding SM4 IV [%s]", err)
+	}
+
+	key := pbkdf2.Key(password, kdfParams.Salt, kdfParams.IterationCount, kdfParams.KeyLength, sm3.New)
+
+	plaintext, err := sm4CBCDecrypt(key, iv, epki.EncryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed decrypting PKCS8 private key [%s]", err)
+	}
+
+	sm2SK, err := sm2.ParsePKCS8UnecryptedPrivateKey(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing decrypted PKCS8 private key [%s]", err)
+	}
+
+	return sm2SK, nil
+}
@@ -0,0 +1,39 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sw
+
+import (
+	"reflect"
+
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/sw/suites"
+)
+
+// init registers this package's two cipher suites with bccsp/sw/suites. Each suite only lists the
+// KeyImporters this package already has a concrete type for; Signer/Verifier/BulkCipher/KDF are
+// left nil here because sw's own implementations of those operations aren't part of this package
+// - once they are, wiring them in is a one-line addition to the relevant Suite literal below
+// rather than another switch arm somewhere else.
+func init() {
+	suites.Register(&suites.Suite{
+		Name: "gm",
+		KeyImporters: map[reflect.Type]suites.KeyImporter{
+			reflect.TypeOf(&bccsp.SM2PrivateKeyImportOpts{}): &SM2PrivateKeyOptsKeyImporter{},
+			reflect.TypeOf(&bccsp.SM2PublicKeyImportOpts{}):  &SM2PublicKeyOptsKeyImporter{},
+			reflect.TypeOf(&bccsp.SM4ImportKeyOpts{}):        &SM4ImportKeyOptsKeyImporter{},
+		},
+	})
+
+	suites.Register(&suites.Suite{
+		Name: "ecdsa",
+		KeyImporters: map[reflect.Type]suites.KeyImporter{
+			reflect.TypeOf(&bccsp.ECDSAPrivateKeyImportOpts{}):    &ecdsaPrivateKeyImportOptsKeyImporter{},
+			reflect.TypeOf(&bccsp.ECDSAPKIXPublicKeyImportOpts{}): &ecdsaPKIXPublicKeyImportOptsKeyImporter{},
+			reflect.TypeOf(&bccsp.AES256ImportKeyOpts{}):          &aes256ImportKeyOptsKeyImporter{},
+		},
+	})
+}
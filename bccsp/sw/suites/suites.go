@@ -0,0 +1,144 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package suites is a pluggable cipher-suite registry for bccsp/sw. Before this package, adding a
+// curve or bulk-cipher to bccsp/sw meant introducing a new concrete Key type, widening every
+// switch that dispatches on concrete key types (e.g. x509PublicKeyImportOptsKeyImporter's
+// *sm2.PublicKey/*ecdsa.PublicKey switch), and adding another entry to CSP.KeyImporters - all in
+// lockstep. A Suite instead bundles a KeyGenerator, KeyImporters, Signer, Verifier, BulkCipher and
+// KDF under a single name ("gm", "p256", ...), registered once via Register. CSP.KeyImport/KeyGen
+// resolve by the suite named in the opts (see Resolve) and only fall back to their own legacy
+// reflect.Type-keyed maps for callers that still pass an algorithm-specific Opts type directly.
+package suites
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/hyperledger/fabric/bccsp"
+)
+
+// KeyGenerator mirrors bccsp.KeyGenerator; declared locally, the way bccsp/pkcs11 declares its own
+// KeyImporter, so this package depends only on bccsp and never on bccsp/sw or bccsp/gm.
+type KeyGenerator interface {
+	KeyGen(opts bccsp.KeyGenOpts) (bccsp.Key, error)
+}
+
+// KeyImporter mirrors bccsp.KeyImporter.
+type KeyImporter interface {
+	KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error)
+}
+
+// Signer mirrors bccsp.Signer.
+type Signer interface {
+	Sign(k bccsp.Key, digest []byte, opts bccsp.SignerOpts) ([]byte, error)
+}
+
+// Verifier mirrors bccsp.Verifier.
+type Verifier interface {
+	Verify(k bccsp.Key, signature, digest []byte, opts bccsp.SignerOpts) (bool, error)
+}
+
+// BulkCipher mirrors the bccsp.Encrypter/bccsp.Decrypter pair a suite's symmetric mode needs.
+type BulkCipher interface {
+	Encrypt(k bccsp.Key, plaintext []byte, opts bccsp.EncrypterOpts) ([]byte, error)
+	Decrypt(k bccsp.Key, ciphertext []byte, opts bccsp.DecrypterOpts) ([]byte, error)
+}
+
+// KDF mirrors bccsp.KeyDeriver.
+type KDF interface {
+	KeyDeriv(k bccsp.Key, opts bccsp.KeyDerivOpts) (bccsp.Key, error)
+}
+
+// SuiteNamer is implemented by a KeyImportOpts or KeyGenOpts that declares which suite should
+// resolve it, e.g. a future bccsp.SuiteKeyImportOpts{Suite: "gm", ...}. Resolve uses this to let
+// CSP.KeyImport/KeyGen route by name before falling back to their legacy type-reflection maps.
+type SuiteNamer interface {
+	Suite() string
+}
+
+// Suite bundles the operations a CSP needs for one algorithm family under a single name.
+// KeyGenerators and KeyImporters are keyed by the concrete bccsp.KeyGenOpts/KeyImportOpts type the
+// suite accepts, mirroring how CSP.KeyImporters is keyed today - so a suite with several key
+// shapes (e.g. "gm" has an SM2 key pair and a symmetric SM4 key) dispatches correctly without its
+// own type switch. Signer, Verifier, BulkCipher and KDF may be nil when a suite doesn't support
+// that operation (e.g. a signature-only suite has no BulkCipher); callers must check before use,
+// the same way a CSP checks whether a KeyImporter is registered for an Opts type today.
+type Suite struct {
+	Name string
+
+	KeyGenerators map[reflect.Type]KeyGenerator
+	KeyImporters  map[reflect.Type]KeyImporter
+
+	Signer     Signer
+	Verifier   Verifier
+	BulkCipher BulkCipher
+	KDF        KDF
+}
+
+// KeyGen dispatches to the KeyGenerator registered for opts' concrete type.
+func (s *Suite) KeyGen(opts bccsp.KeyGenOpts) (bccsp.Key, error) {
+	kg, ok := s.KeyGenerators[reflect.TypeOf(opts)]
+	if !ok {
+		return nil, fmt.Errorf("suites: suite [%s] has no KeyGenerator registered for opts type [%T]", s.Name, opts)
+	}
+	return kg.KeyGen(opts)
+}
+
+// KeyImport dispatches to the KeyImporter registered for opts' concrete type.
+func (s *Suite) KeyImport(raw interface{}, opts bccsp.KeyImportOpts) (bccsp.Key, error) {
+	ki, ok := s.KeyImporters[reflect.TypeOf(opts)]
+	if !ok {
+		return nil, fmt.Errorf("suites: suite [%s] has no KeyImporter registered for opts type [%T]", s.Name, opts)
+	}
+	return ki.KeyImport(raw, opts)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*Suite{}
+)
+
+// Register adds suite to the registry under suite.Name, replacing any suite already registered
+// under that name. Suites are expected to self-register from an init() function in the package
+// that wires their concrete Signer/Verifier/KeyImporters together (e.g. bccsp/sw's "gm" and
+// "ecdsa" suites), the way database/sql drivers register themselves.
+func Register(suite *Suite) {
+	if suite == nil || suite.Name == "" {
+		panic("suites: cannot register a nil suite or a suite with an empty Name")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	registry[suite.Name] = suite
+}
+
+// Lookup returns the suite registered under name, or an error if none was.
+func Lookup(name string) (*Suite, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	suite, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("suites: no suite registered under name [%s]", name)
+	}
+	return suite, nil
+}
+
+// Resolve returns the suite opts declares via SuiteNamer, if any. It is the routing step
+// CSP.KeyImport/KeyGen are expected to perform before falling back to their own legacy
+// reflect.Type-keyed maps: ok is false when opts doesn't implement SuiteNamer (a legacy caller) or
+// names a suite nothing has registered.
+func Resolve(opts interface{}) (suite *Suite, ok bool) {
+	namer, isNamer := opts.(SuiteNamer)
+	if !isNamer {
+		return nil, false
+	}
+	suite, err := Lookup(namer.Suite())
+	if err != nil {
+		return nil, false
+	}
+	return suite, true
+}
@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedBlockCodecRoundTrip(t *testing.T) {
+	for _, format := range []BlockFileFormat{BlockFileFormatSnappy, BlockFileFormatZstd} {
+		format := format
+		t.Run(string(format), func(t *testing.T) {
+			codec, err := NewCompressedBlockCodec(format)
+			require.NoError(t, err)
+			require.NotNil(t, codec)
+
+			plaintext := []byte("a serialized block goes here, repeated for compressibility, " +
+				"a serialized block goes here, repeated for compressibility")
+			frame := codec.Encode(plaintext)
+
+			decoded, err := codec.Decode(bytes.NewReader(frame))
+			require.NoError(t, err)
+			require.Equal(t, plaintext, decoded)
+		})
+	}
+}
+
+func TestCompressedBlockCodecRaw(t *testing.T) {
+	codec, err := NewCompressedBlockCodec(BlockFileFormatRaw)
+	require.NoError(t, err)
+	require.Nil(t, codec)
+}
+
+func TestRewriteToCompressedFormat(t *testing.T) {
+	codec, err := NewCompressedBlockCodec(BlockFileFormatSnappy)
+	require.NoError(t, err)
+
+	dir := testPath()
+	destPath := filepath.Join(dir, "blockfile_compressed_000000")
+	blocks := map[uint64][]byte{
+		0: []byte("block zero"),
+		1: []byte("block one"),
+		2: []byte("block two"),
+	}
+
+	offsets, err := rewriteToCompressedFormat(destPath, codec, blocks)
+	require.NoError(t, err)
+	require.Len(t, offsets, 3)
+
+	f, err := os.Open(destPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, blockNum := range []uint64{0, 1, 2} {
+		_, err := f.Seek(offsets[blockNum], 0)
+		require.NoError(t, err)
+		decoded, err := codec.Decode(f)
+		require.NoError(t, err)
+		require.Equal(t, blocks[blockNum], decoded)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmappedFile is a read-only memory mapping of a .idx file: binary-searching data is then a
+// matter of touching only the pages the search actually visits, instead of reading the whole
+// file into the Go heap up front.
+type mmappedFile struct {
+	data []byte
+}
+
+func mmapOpenFile(path string) (*mmappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return &mmappedFile{data: nil}, nil
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mmappedFile{data: data}, nil
+}
+
+func (m *mmappedFile) close() error {
+	if m.data == nil {
+		return nil
+	}
+	return syscall.Munmap(m.data)
+}
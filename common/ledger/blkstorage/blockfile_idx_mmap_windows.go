@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import "os"
+
+// mmappedFile on windows falls back to an ordinary read into memory: fabric has no vendored
+// mmap support for windows, and a fully-read byte slice still satisfies blockIndexFile's
+// binary-search-over-a-byte-slice contract, just without the page-fault-on-touch benefit.
+type mmappedFile struct {
+	data []byte
+}
+
+func mmapOpenFile(path string) (*mmappedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mmappedFile{data: data}, nil
+}
+
+func (m *mmappedFile) close() error {
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockIndexWriterReader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blockfile_index_000000.idx")
+
+	w := newBlockIndexWriter()
+	for i := uint64(0); i < 1000; i++ {
+		require.NoError(t, w.add(blockLocation{blockNum: i, fileSuffix: uint32(i / 100), offset: i * 10, length: 10}))
+	}
+	require.NoError(t, w.snapshot(path))
+
+	f, err := openBlockIndex(path)
+	require.NoError(t, err)
+	defer f.close()
+
+	for i := uint64(0); i < 1000; i++ {
+		loc, err := f.Lookup(i)
+		require.NoError(t, err)
+		require.Equal(t, blockLocation{blockNum: i, fileSuffix: uint32(i / 100), offset: i * 10, length: 10}, loc)
+	}
+
+	_, err = f.Lookup(1000)
+	require.Error(t, err)
+}
+
+func TestBlockIndexWriterOutOfOrder(t *testing.T) {
+	w := newBlockIndexWriter()
+	require.NoError(t, w.add(blockLocation{blockNum: 5}))
+	require.Error(t, w.add(blockLocation{blockNum: 5}))
+	require.Error(t, w.add(blockLocation{blockNum: 4}))
+}
+
+func TestBlockIndexChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blockfile_index_000000.idx")
+
+	w := newBlockIndexWriter()
+	require.NoError(t, w.add(blockLocation{blockNum: 0, fileSuffix: 0, offset: 0, length: 10}))
+	require.NoError(t, w.snapshot(path))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	raw[blockIndexHeaderSize] ^= 0xFF // corrupt the first record byte, leaving the checksum stale
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+	_, err = openBlockIndex(path)
+	require.Error(t, err)
+}
+
+func TestBlockIndexInvalidMagic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blockfile_index_000000.idx")
+	require.NoError(t, os.WriteFile(path, make([]byte, blockIndexHeaderSize+blockIndexChecksumSize), 0o600))
+
+	_, err := openBlockIndex(path)
+	require.Error(t, err)
+}
+
+func TestFanoutShiftFor(t *testing.T) {
+	require.Equal(t, byte(0), fanoutShiftFor(nil))
+	require.Equal(t, byte(0), fanoutShiftFor([]blockLocation{{blockNum: 10}}))
+
+	shift := fanoutShiftFor([]blockLocation{{blockNum: 1_000_000}})
+	require.Less(t, uint64(1_000_000)>>uint(shift), uint64(blockIndexFanoutEntries))
+}
@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"bufio"
+	"os"
+)
+
+// FrameStream sequentially reads the raw per-block bytes out of a single blockfile, in the order
+// they were written, without needing the file-wide index blockfileMgr builds for random access -
+// the same forward-only access pattern a bulk channel export walks with. Both BlockFileFormatRaw
+// and the codecs in blockfile_compressed.go wrap each block in the same outer uvarint-length frame
+// (readFrame/writeFrame); FrameStream just walks that sequence of frames, decompressing via codec
+// when one is given, so a caller never has to decode a block into *common.Block and re-encode it
+// just to pass it along unchanged.
+//
+// FrameStream is meant to back PeerLedger.GetBlockIterator's RawBytes mode once blockfileMgr and
+// the kvLedger that would own one are part of this checkout; see blockfile_stream_test.go for
+// what's exercised against it directly until then.
+type FrameStream struct {
+	f     *os.File
+	r     *bufio.Reader // an io.ByteReader, so readFrame/codec.Decode don't read frame headers a byte per file read
+	codec CompressedBlockCodec
+}
+
+// OpenFrameStream opens the blockfile at path for sequential reading from the beginning, decoding
+// each frame with codec (nil for BlockFileFormatRaw, meaning a frame's payload is already the raw
+// block bytes).
+func OpenFrameStream(path string, codec CompressedBlockCodec) (*FrameStream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FrameStream{f: f, r: bufio.NewReader(f), codec: codec}, nil
+}
+
+// Next returns the next block's raw bytes, or io.EOF once every frame in the file has been read.
+func (s *FrameStream) Next() ([]byte, error) {
+	if s.codec != nil {
+		return s.codec.Decode(s.r)
+	}
+	return readFrame(s.r)
+}
+
+// Close closes the underlying blockfile.
+func (s *FrameStream) Close() error {
+	return s.f.Close()
+}
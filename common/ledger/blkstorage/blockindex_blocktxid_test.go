@@ -0,0 +1,50 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockIndexSelectiveIndexingBlockTxID(t *testing.T) {
+	testBlockIndexSelectiveIndexingBlockTxID(t, []IndexableAttr{})
+	testBlockIndexSelectiveIndexingBlockTxID(t, []IndexableAttr{IndexableAttrBlockTxID})
+	testBlockIndexSelectiveIndexingBlockTxID(t, []IndexableAttr{IndexableAttrBlockTxID, IndexableAttrTxID})
+}
+
+func testBlockIndexSelectiveIndexingBlockTxID(t *testing.T, indexItems []IndexableAttr) {
+	var testName string
+	for _, s := range indexItems {
+		testName = testName + string(s)
+	}
+	t.Run(testName, func(t *testing.T) {
+		env := newTestEnvSelectiveIndexing(t, NewConf(testPath(), 0), indexItems, &disabled.Provider{})
+		defer env.Cleanup()
+		blkfileMgrWrapper := newTestBlockfileWrapper(env, "testledger")
+		defer blkfileMgrWrapper.close()
+
+		blocks := testutil.ConstructTestBlocks(t, 1)
+		blkfileMgrWrapper.addBlocks(blocks)
+		blockfileMgr := blkfileMgrWrapper.blockfileMgr
+
+		txid, err := protoutil.GetOrComputeTxIDFromEnvelope(blocks[0].Data.Data[0])
+		assert.NoError(t, err)
+
+		block, err := blockfileMgr.retrieveBlockByBlockTxIDIndex(txid)
+		if containsAttr(indexItems, IndexableAttrBlockTxID) {
+			assert.NoError(t, err, "Error while retrieving block by BlockTxID index")
+			assert.Equal(t, blocks[0], block)
+		} else {
+			assert.Exactly(t, ErrAttrNotIndexed, err)
+		}
+	})
+}
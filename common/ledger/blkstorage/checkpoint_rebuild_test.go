@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testFileSummarizer builds a blockFileSummarizer backed by a fixed table of summaries keyed by
+// path, so tests can drive checkpointRebuilder without real block files.
+func testFileSummarizer(t *testing.T, table map[string]fileSummary, failOn map[string]bool) blockFileSummarizer {
+	return func(filePath string, fileSuffixNum int) (fileSummary, error) {
+		if failOn[filePath] {
+			return fileSummary{}, errors.New("simulated corruption in " + filePath)
+		}
+		s, ok := table[filePath]
+		require.True(t, ok, "no summary fixture for %s", filePath)
+		return s, nil
+	}
+}
+
+func contiguousFiles(n int, blocksPerFile uint64) ([]filePath, map[string]fileSummary) {
+	var files []filePath
+	table := map[string]fileSummary{}
+	for i := 0; i < n; i++ {
+		path := filepath.Join("testfiles", fileName(i))
+		files = append(files, filePath{path: path, suffixNum: i})
+		table[path] = fileSummary{
+			FileSuffixNum:   i,
+			FirstBlockNum:   uint64(i) * blocksPerFile,
+			LastBlockNum:    uint64(i)*blocksPerFile + blocksPerFile - 1,
+			LastValidOffset: 1024,
+		}
+	}
+	return files, table
+}
+
+func fileName(i int) string {
+	return "blockfile_" + string(rune('0'+i))
+}
+
+func TestCheckpointRebuilderParallel(t *testing.T) {
+	files, table := contiguousFiles(6, 100)
+	r := newCheckpointRebuilder(testFileSummarizer(t, table, nil), 3, "", 0)
+
+	cp, err := r.rebuild(files)
+	require.NoError(t, err)
+	require.Equal(t, &rebuiltCheckpoint{
+		LastBlockNumber:          599,
+		LatestFileChunksize:      1024,
+		LatestFileChunkSuffixNum: 5,
+	}, cp)
+}
+
+func TestCheckpointRebuilderEmpty(t *testing.T) {
+	r := newCheckpointRebuilder(testFileSummarizer(t, nil, nil), 2, "", 0)
+	cp, err := r.rebuild(nil)
+	require.NoError(t, err)
+	require.Equal(t, &rebuiltCheckpoint{IsChainEmpty: true}, cp)
+}
+
+func TestCheckpointRebuilderDetectsGap(t *testing.T) {
+	files, table := contiguousFiles(4, 100)
+	gapPath := files[2].path
+	table[gapPath] = fileSummary{
+		FileSuffixNum:   2,
+		FirstBlockNum:   250, // should be 200 - creates a gap against file 1's last block of 199
+		LastBlockNum:    349,
+		LastValidOffset: 1024,
+	}
+
+	r := newCheckpointRebuilder(testFileSummarizer(t, table, nil), 4, "", 0)
+	_, err := r.rebuild(files)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "block number gap")
+}
+
+func TestCheckpointRebuilderResumesFromCursor(t *testing.T) {
+	dir := t.TempDir()
+	cursorPath := filepath.Join(dir, "rebuild_cursor.json")
+
+	files, table := contiguousFiles(6, 100)
+
+	// Fail on the last file the first time through, simulating a crash partway through the
+	// rebuild; everything before it should already be checkpointed.
+	failingR := newCheckpointRebuilder(testFileSummarizer(t, table, map[string]bool{files[5].path: true}), 3, cursorPath, 1)
+	_, err := failingR.rebuild(files)
+	require.Error(t, err)
+
+	done, err := failingR.loadCursor()
+	require.NoError(t, err)
+	require.Len(t, done, 5)
+	_, stillPending := done[5]
+	require.False(t, stillPending)
+
+	resumedR := newCheckpointRebuilder(testFileSummarizer(t, table, nil), 3, cursorPath, 1)
+	cp, err := resumedR.rebuild(files)
+	require.NoError(t, err)
+
+	sequentialR := newCheckpointRebuilder(testFileSummarizer(t, table, nil), 1, "", 0)
+	sequentialCP, err := sequentialR.rebuild(files)
+	require.NoError(t, err)
+
+	require.Equal(t, sequentialCP, cp)
+}
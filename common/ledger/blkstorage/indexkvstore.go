@@ -0,0 +1,106 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+)
+
+// NOTE: blockIndex itself - and the Conf type NewConf builds - live in blockindex.go, which is not
+// part of this checkout, so the db field blockindex_test.go drives directly
+// (originalIndexStore := blkfileMgr.index.db, a *leveldbhelper.DBHandle) can't be repointed at this
+// interface here. IndexKVStore, leveldbIndexStore and boltIndexStore are written ready for that
+// swap - index.db's declared type becoming IndexKVStore and NewIndexKVStore taking over from
+// leveldbProvider.GetDBHandle - once blockindex.go is restored; IndexBackend in Conf would then
+// pick which constructor runs.
+
+// IndexKVStore is the narrow key/value surface blockIndex needs from its backing store: exactly
+// the *leveldbhelper.DBHandle methods blockindex.go uses today, pulled out so a backend other than
+// LevelDB can stand in without blockindex.go or its tests caring which one is underneath.
+type IndexKVStore interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte, sync bool) error
+	Delete(key []byte, sync bool) error
+	GetIterator(startKey, endKey []byte) KVIterator
+	NewUpdateBatch() KVBatch
+	WriteBatch(batch KVBatch, sync bool) error
+	Close() error
+}
+
+// KVIterator mirrors *leveldbhelper.Iterator.
+type KVIterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Release()
+}
+
+// KVBatch mirrors *leveldbhelper.UpdateBatch.
+type KVBatch interface {
+	Put(key, value []byte)
+	Delete(key []byte)
+	Len() int
+}
+
+// IndexBackend names one of the IndexKVStore implementations NewIndexKVStore can build. LevelDB
+// remains the default - it is what every index file on disk today was written with - and Bolt is
+// opt-in per Conf, its single-file B+tree suiting read-heavy archival nodes that would rather avoid
+// LevelDB's background compaction. No other backend is implemented.
+type IndexBackend string
+
+const (
+	IndexBackendLevelDB IndexBackend = "leveldb"
+	IndexBackendBolt    IndexBackend = "bolt"
+)
+
+// NewIndexKVStore builds the IndexKVStore named by backend. provider and dbName are only used by
+// IndexBackendLevelDB, to keep reusing the blockstorage provider's shared LevelDB instance the way
+// GetDBHandle does today; boltFilePath is only used by IndexBackendBolt, which - unlike LevelDB's
+// one-provider-many-handles model - opens its own file per ledger.
+func NewIndexKVStore(backend IndexBackend, provider *leveldbhelper.Provider, dbName string, boltFilePath string) (IndexKVStore, error) {
+	switch backend {
+	case IndexBackendBolt:
+		return newBoltIndexStore(boltFilePath)
+	case IndexBackendLevelDB, "":
+		return &leveldbIndexStore{h: provider.GetDBHandle(dbName)}, nil
+	default:
+		return nil, &unsupportedIndexBackendError{backend: backend}
+	}
+}
+
+type unsupportedIndexBackendError struct {
+	backend IndexBackend
+}
+
+func (e *unsupportedIndexBackendError) Error() string {
+	return "unsupported index backend [" + string(e.backend) + "]"
+}
+
+// leveldbIndexStore adapts *leveldbhelper.DBHandle to IndexKVStore.
+type leveldbIndexStore struct {
+	h *leveldbhelper.DBHandle
+}
+
+func (s *leveldbIndexStore) Get(key []byte) ([]byte, error) { return s.h.Get(key) }
+
+func (s *leveldbIndexStore) Put(key, value []byte, sync bool) error {
+	return s.h.Put(key, value, sync)
+}
+
+func (s *leveldbIndexStore) Delete(key []byte, sync bool) error { return s.h.Delete(key, sync) }
+
+func (s *leveldbIndexStore) GetIterator(startKey, endKey []byte) KVIterator {
+	return s.h.GetIterator(startKey, endKey)
+}
+
+func (s *leveldbIndexStore) NewUpdateBatch() KVBatch { return s.h.NewUpdateBatch() }
+
+func (s *leveldbIndexStore) WriteBatch(batch KVBatch, sync bool) error {
+	return s.h.WriteBatch(batch.(*leveldbhelper.UpdateBatch), sync)
+}
+
+func (s *leveldbIndexStore) Close() error { return nil }
@@ -0,0 +1,156 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltIndexBucket is the single bucket every key blockIndex writes lives in; Bolt requires all
+// reads/writes to name a bucket, unlike LevelDB's flat keyspace.
+var boltIndexBucket = []byte("blockindex")
+
+// boltIndexStore is an IndexKVStore backed by a single-file BoltDB database, suited to read-heavy
+// archival peers that would rather avoid LevelDB's background compaction than benefit from its
+// write throughput.
+type boltIndexStore struct {
+	db *bolt.DB
+}
+
+func newBoltIndexStore(filePath string) (*boltIndexStore, error) {
+	db, err := bolt.Open(filePath, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltIndexBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltIndexStore{db: db}, nil
+}
+
+func (s *boltIndexStore) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltIndexBucket).Get(key)
+		if v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return value, err
+}
+
+func (s *boltIndexStore) Put(key, value []byte, sync bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltIndexBucket).Put(key, value)
+	})
+}
+
+func (s *boltIndexStore) Delete(key []byte, sync bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltIndexBucket).Delete(key)
+	})
+}
+
+// GetIterator returns a snapshot iterator over [startKey, endKey), materialized eagerly from a
+// single read transaction the way blockIndex's callers already expect to fully drain an iterator
+// before the underlying store changes again - Bolt's own cursor is only valid for the lifetime of
+// the transaction that created it, so it can't be held open across calls the way
+// *leveldbhelper.Iterator is.
+func (s *boltIndexStore) GetIterator(startKey, endKey []byte) KVIterator {
+	itr := &boltIterator{}
+	s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltIndexBucket).Cursor()
+		for k, v := c.Seek(startKey); k != nil && (endKey == nil || bytes.Compare(k, endKey) < 0); k, v = c.Next() {
+			itr.keys = append(itr.keys, append([]byte(nil), k...))
+			itr.values = append(itr.values, append([]byte(nil), v...))
+		}
+		return nil
+	})
+	return itr
+}
+
+func (s *boltIndexStore) NewUpdateBatch() KVBatch { return &boltBatch{} }
+
+func (s *boltIndexStore) WriteBatch(batch KVBatch, sync bool) error {
+	b, ok := batch.(*boltBatch)
+	if !ok {
+		return errNotABoltBatch
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltIndexBucket)
+		for _, op := range b.ops {
+			if op.delete {
+				if err := bucket.Delete(op.key); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := bucket.Put(op.key, op.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltIndexStore) Close() error { return s.db.Close() }
+
+var errNotABoltBatch = boltBatchTypeError{}
+
+type boltBatchTypeError struct{}
+
+func (boltBatchTypeError) Error() string { return "blkstorage: batch was not created by boltIndexStore" }
+
+type boltOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// boltBatch accumulates Put/Delete operations the way *leveldbhelper.UpdateBatch does, applying
+// them all inside one Bolt transaction in WriteBatch.
+type boltBatch struct {
+	ops []boltOp
+}
+
+func (b *boltBatch) Put(key, value []byte) {
+	b.ops = append(b.ops, boltOp{key: key, value: value})
+}
+
+func (b *boltBatch) Delete(key []byte) {
+	b.ops = append(b.ops, boltOp{key: key, delete: true})
+}
+
+func (b *boltBatch) Len() int { return len(b.ops) }
+
+// boltIterator is a materialized, already-sorted (Bolt's cursor walks keys in byte order, same as
+// LevelDB's) snapshot of a key range.
+type boltIterator struct {
+	keys, values [][]byte
+	pos          int
+	started      bool
+}
+
+func (itr *boltIterator) Next() bool {
+	if !itr.started {
+		itr.started = true
+	} else {
+		itr.pos++
+	}
+	return itr.pos < len(itr.keys)
+}
+
+func (itr *boltIterator) Key() []byte   { return itr.keys[itr.pos] }
+func (itr *boltIterator) Value() []byte { return itr.values[itr.pos] }
+func (itr *boltIterator) Release()      {}
@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blkstorage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexKVStoreBackends(t *testing.T) {
+	testCases := []struct {
+		name    string
+		newGen  func(t *testing.T) IndexKVStore
+	}{
+		{
+			name: "leveldb",
+			newGen: func(t *testing.T) IndexKVStore {
+				provider, err := leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: filepath.Join(testPath(), "leveldb")})
+				require.NoError(t, err)
+				t.Cleanup(provider.Close)
+				store, err := NewIndexKVStore(IndexBackendLevelDB, provider, "testdb", "")
+				require.NoError(t, err)
+				return store
+			},
+		},
+		{
+			name: "bolt",
+			newGen: func(t *testing.T) IndexKVStore {
+				store, err := NewIndexKVStore(IndexBackendBolt, nil, "", filepath.Join(testPath(), "index.bolt"))
+				require.NoError(t, err)
+				t.Cleanup(func() { store.Close() })
+				return store
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.newGen(t)
+
+			require.NoError(t, store.Put([]byte("k1"), []byte("v1"), true))
+			v, err := store.Get([]byte("k1"))
+			require.NoError(t, err)
+			require.Equal(t, []byte("v1"), v)
+
+			batch := store.NewUpdateBatch()
+			batch.Put([]byte("k2"), []byte("v2"))
+			batch.Put([]byte("k3"), []byte("v3"))
+			require.NoError(t, store.WriteBatch(batch, true))
+
+			itr := store.GetIterator([]byte("k1"), nil)
+			defer itr.Release()
+			var keys []string
+			for itr.Next() {
+				keys = append(keys, string(itr.Key()))
+			}
+			require.Equal(t, []string{"k1", "k2", "k3"}, keys)
+
+			require.NoError(t, store.Delete([]byte("k2"), true))
+			v, err = store.Get([]byte("k2"))
+			require.NoError(t, err)
+			require.Nil(t, v)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package snapshot provides a small binary file format - a leading format-version byte followed by
+// a stream of length-prefixed strings and unsigned varints (encoding/binary's Uvarint) - used across the ledger's
+// various snapshot exporters (blkstorage's unique-txID export, pvtdatastorage's live-data export)
+// so each exporter doesn't reinvent its own framing, and a SnapshotService can stream any of them
+// to a joining peer without caring what they contain.
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// FileWriter appends a format-version byte to newly created files and then writes
+// length-prefixed strings or unsigned varints, matching what FileReader expects back.
+type FileWriter struct {
+	file *os.File
+	bufw *bufio.Writer
+	buf  []byte
+}
+
+// CreateFile creates path, writes formatVersion as its first byte, and returns a FileWriter ready
+// to append records.
+func CreateFile(path string, formatVersion byte) (*FileWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &FileWriter{file: f, bufw: bufio.NewWriter(f), buf: make([]byte, binary.MaxVarintLen64)}
+	if err := w.bufw.WriteByte(formatVersion); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// EncodeString appends s as a varint length prefix followed by its bytes.
+func (w *FileWriter) EncodeString(s string) error {
+	if err := w.EncodeUVarInt(uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := w.bufw.WriteString(s)
+	return err
+}
+
+// EncodeUVarInt appends v as an unsigned varint.
+func (w *FileWriter) EncodeUVarInt(v uint64) error {
+	n := binary.PutUvarint(w.buf, v)
+	_, err := w.bufw.Write(w.buf[:n])
+	return err
+}
+
+// Close flushes any buffered writes and closes the underlying file.
+func (w *FileWriter) Close() error {
+	if err := w.bufw.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// FileReader reads back records written by FileWriter, in the order they were written.
+type FileReader struct {
+	file *os.File
+	bufr *bufio.Reader
+}
+
+// OpenFile opens path and checks that its first byte matches expectedFormatVersion, returning a
+// FileReader positioned right after that byte.
+func OpenFile(path string, expectedFormatVersion byte) (*FileReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	bufr := bufio.NewReader(f)
+	version, err := bufr.ReadByte()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if version != expectedFormatVersion {
+		f.Close()
+		return nil, fmt.Errorf("unexpected snapshot file format version [%d] in file [%s], expected [%d]", version, path, expectedFormatVersion)
+	}
+	return &FileReader{file: f, bufr: bufr}, nil
+}
+
+// DecodeString reads back a record written by EncodeString.
+func (r *FileReader) DecodeString() (string, error) {
+	length, err := r.DecodeUVarInt()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, length)
+	if _, err := readFull(r.bufr, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// DecodeUVarInt reads back a record written by EncodeUVarInt.
+func (r *FileReader) DecodeUVarInt() (uint64, error) {
+	return binary.ReadUvarint(r.bufr)
+}
+
+// Close closes the underlying file.
+func (r *FileReader) Close() error {
+	return r.file.Close()
+}
+
+func readFull(r *bufio.Reader, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		c, err := r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		b[n] = c
+		n++
+	}
+	return n, nil
+}
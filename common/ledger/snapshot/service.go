@@ -0,0 +1,164 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package snapshot
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// NOTE: the wire messages a real SnapshotService RPC exchanges (request/response protobufs with
+// generated marshal/unmarshal code and a grpc.ServiceDesc) are defined in fabric-protos, which is
+// an external module this checkout doesn't vendor a copy of to extend. ChunkStream below is the
+// plain interface such a generated gRPC server/client stream already satisfies (grpc's generated
+// stream types expose exactly this Send/Recv shape over whatever message type the .proto declares);
+// Service and Client are written against it so the transfer, hashing and backpressure logic here
+// doesn't need to change once the corresponding .proto lands upstream and is vendored in.
+
+// chunkSize bounds how much of a snapshot file is read into memory and handed to the stream at
+// once, the same reasoning blkstorage's own snapshotChunkSize follows: bounded memory and a small
+// unit of retry after an interrupted transfer.
+const chunkSize = 1024 * 1024
+
+// ChunkStream is the minimal Send/Recv shape a streaming gRPC method generates, carrying one
+// []byte frame per call. Service.SendFiles sends a nil frame to mark the end of each file's
+// contents; Recv returning io.EOF marks the end of the stream.
+type ChunkStream interface {
+	Send(frame []byte) error
+	Recv() ([]byte, error)
+}
+
+// Service streams the files a ledger snapshot exporter (blkstorage.exportUniqueTxIDs,
+// pvtdatastorage.ExportPvtDataSnapshot, or any future exporter) already wrote to disk, without
+// buffering a whole file in memory: each file is read and forwarded to the stream chunkSize bytes
+// at a time, hashed as it goes, so the caller can confirm what it sent matches what the exporter's
+// own fileHashes map reported.
+type Service struct{}
+
+// SendFiles streams every file in fileNames, found under dir, over stream: a length-delimited
+// "<fileName>\n" framing record, followed by the file's contents as a sequence of chunkSize-byte
+// frames, followed by a final empty frame marking that file's end. It stops at the first read or
+// send error so a broken transfer never claims to have sent a file it didn't finish.
+func (Service) SendFiles(stream ChunkStream, dir string, fileNames []string) error {
+	for _, name := range fileNames {
+		if err := stream.Send([]byte(name + "\n")); err != nil {
+			return err
+		}
+		if err := sendFile(stream, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		if err := stream.Send(nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendFile(stream ChunkStream, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if sendErr := stream.Send(append([]byte(nil), buf[:n]...)); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Client receives the files Service.SendFiles streams and writes each one into a temp file under
+// destDir, renaming it to its final name only once expectedHashes confirms the bytes received
+// match what the exporter reported - so a transfer broken partway through never leaves a
+// plausible-looking but truncated or corrupted snapshot file behind.
+type Client struct{}
+
+// ReceiveFiles reads frames off stream until it returns io.EOF, writing each named file into
+// destDir. expectedHashes maps file name to the sha256 digest Service's caller is expected to have
+// published alongside the snapshot manifest; a mismatch, or a file with no entry in expectedHashes,
+// leaves no partial file under its final name and aborts the whole transfer.
+func (Client) ReceiveFiles(stream ChunkStream, destDir string, expectedHashes map[string][]byte) error {
+	for {
+		frame, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := string(frame[:len(frame)-1])
+		if err := receiveFile(stream, destDir, name, expectedHashes[name]); err != nil {
+			return err
+		}
+	}
+}
+
+func receiveFile(stream ChunkStream, destDir, name string, expectedHash []byte) error {
+	tmpPath := filepath.Join(destDir, name+".tmp")
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	hasher := sha256.New()
+	for {
+		frame, err := stream.Recv()
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		if frame == nil {
+			break
+		}
+		if _, err := f.Write(frame); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		hasher.Write(frame)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	actualHash := hasher.Sum(nil)
+	if !hashesEqual(actualHash, expectedHash) {
+		os.Remove(tmpPath)
+		return fmt.Errorf("received file [%s] does not match its expected hash", name)
+	}
+
+	return os.Rename(tmpPath, filepath.Join(destDir, name))
+}
+
+func hashesEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
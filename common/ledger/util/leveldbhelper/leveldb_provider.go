@@ -0,0 +1,208 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package leveldbhelper
+
+import (
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Conf configures the single shared leveldb instance backing a Provider.
+type Conf struct {
+	DBPath string
+}
+
+// Provider hands out DBHandles that share a single leveldb instance, each scoped to its own
+// dbName by prefixing every key with dbName. This lets callers such as pvtdatastorage open one
+// logical "database" per ledger while only a single file-backed leveldb is ever opened.
+type Provider struct {
+	conf *Conf
+	db   *leveldb.DB
+
+	dbHandlesMutex sync.Mutex
+	dbHandles      map[string]*DBHandle
+}
+
+// NewProvider opens (or creates) the leveldb instance at conf.DBPath.
+func NewProvider(conf *Conf) (*Provider, error) {
+	db, err := leveldb.OpenFile(conf.DBPath, &opt.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		conf:      conf,
+		db:        db,
+		dbHandles: make(map[string]*DBHandle),
+	}, nil
+}
+
+// IsCorrupted reports whether err indicates that the underlying leveldb files are corrupted,
+// as opposed to an ordinary I/O or not-found error. Callers can use this to decide whether
+// Provider.Recover is worth attempting.
+func IsCorrupted(err error) bool {
+	return errors.IsCorrupted(err)
+}
+
+// Recover closes the shared leveldb instance, salvages as much of it as possible via
+// goleveldb's RecoverFile, and reopens it in place. Every outstanding DBHandle is repointed
+// at the recovered instance. Callers are responsible for restoring any higher-level
+// consistency (e.g. replaying in-progress conversions) that the salvage pass may have
+// disturbed, since RecoverFile can drop the most recent, not-yet-flushed writes.
+func (p *Provider) Recover() error {
+	p.dbHandlesMutex.Lock()
+	defer p.dbHandlesMutex.Unlock()
+
+	p.db.Close()
+	db, err := leveldb.RecoverFile(p.conf.DBPath, &opt.Options{})
+	if err != nil {
+		return err
+	}
+	p.db = db
+	for _, dbHandle := range p.dbHandles {
+		dbHandle.db = db
+	}
+	return nil
+}
+
+// GetDBHandle returns a handle scoped to dbName, creating it on first use.
+func (p *Provider) GetDBHandle(dbName string) *DBHandle {
+	p.dbHandlesMutex.Lock()
+	defer p.dbHandlesMutex.Unlock()
+	dbHandle := p.dbHandles[dbName]
+	if dbHandle == nil {
+		dbHandle = &DBHandle{dbName: dbName, db: p.db}
+		p.dbHandles[dbName] = dbHandle
+	}
+	return dbHandle
+}
+
+// Close closes the underlying leveldb instance.
+func (p *Provider) Close() {
+	p.db.Close()
+}
+
+// DBHandle is a handle to a logical, dbName-prefixed slice of the shared leveldb instance.
+type DBHandle struct {
+	dbName string
+	db     *leveldb.DB
+}
+
+func (h *DBHandle) prefixedKey(key []byte) []byte {
+	return append(append([]byte{}, []byte(h.dbName)...), append([]byte{0}, key...)...)
+}
+
+// Get retrieves the value for key, returning (nil, nil) if it is absent.
+func (h *DBHandle) Get(key []byte) ([]byte, error) {
+	val, err := h.db.Get(h.prefixedKey(key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return val, err
+}
+
+// Put writes key/value, optionally syncing to disk before returning.
+func (h *DBHandle) Put(key []byte, value []byte, sync bool) error {
+	return h.db.Put(h.prefixedKey(key), value, &opt.WriteOptions{Sync: sync})
+}
+
+// Delete removes key, optionally syncing to disk before returning.
+func (h *DBHandle) Delete(key []byte, sync bool) error {
+	return h.db.Delete(h.prefixedKey(key), &opt.WriteOptions{Sync: sync})
+}
+
+// WriteBatch atomically applies batch to the database.
+func (h *DBHandle) WriteBatch(batch *UpdateBatch, sync bool) error {
+	if batch.leveldbBatch.Len() == 0 {
+		return nil
+	}
+	return h.db.Write(batch.leveldbBatch, &opt.WriteOptions{Sync: sync})
+}
+
+// NewUpdateBatch returns an UpdateBatch whose Put/Delete calls are pre-prefixed with this
+// handle's dbName, so it can be handed straight to WriteBatch without any further translation.
+func (h *DBHandle) NewUpdateBatch() *UpdateBatch {
+	return &UpdateBatch{
+		dbName:       h.dbName,
+		leveldbBatch: &leveldb.Batch{},
+	}
+}
+
+// GetIterator returns an iterator over this handle's keyspace bounded by [startKey, endKey); a
+// nil endKey means "through the end of the handle's keyspace".
+func (h *DBHandle) GetIterator(startKey []byte, endKey []byte) *Iterator {
+	rng := &util.Range{Start: h.prefixedKey(startKey)}
+	if endKey == nil {
+		rng.Limit = h.prefixedKey(nil)
+		rng.Limit[len(rng.Limit)-1]++
+	} else {
+		rng.Limit = h.prefixedKey(endKey)
+	}
+	return &Iterator{dbName: h.dbName, iter: h.db.NewIterator(rng, nil)}
+}
+
+// Iterator wraps a goleveldb iterator, stripping the dbName prefix off returned keys.
+type Iterator struct {
+	dbName string
+	iter   iterator.Iterator
+}
+
+func (itr *Iterator) Next() bool { return itr.iter.Next() }
+
+func (itr *Iterator) Key() []byte {
+	return itr.iter.Key()[len(itr.dbName)+1:]
+}
+
+func (itr *Iterator) Value() []byte { return itr.iter.Value() }
+
+func (itr *Iterator) Release() { itr.iter.Release() }
+
+// UpdateBatch accumulates Put/Delete operations directly into a goleveldb *leveldb.Batch (rather
+// than an intermediate map), so that the entries it was built from - already iterated in sorted
+// key order by every caller in this package - are written out without an extra sort pass or a
+// duplicate in-memory key map. len counts the Put/Delete calls made on this batch, which Len
+// reports.
+type UpdateBatch struct {
+	dbName       string
+	leveldbBatch *leveldb.Batch
+	len          int
+}
+
+// NewUpdateBatch returns a standalone batch not bound to any dbName prefix: its keys are staged
+// exactly as given. Prefer DBHandle.NewUpdateBatch for batches destined for WriteBatch on that
+// handle.
+func NewUpdateBatch() *UpdateBatch {
+	return &UpdateBatch{leveldbBatch: &leveldb.Batch{}}
+}
+
+func (b *UpdateBatch) key(key []byte) []byte {
+	if b.dbName == "" {
+		return key
+	}
+	return append(append([]byte{}, []byte(b.dbName)...), append([]byte{0}, key...)...)
+}
+
+// Put stages a key/value write.
+func (b *UpdateBatch) Put(key []byte, value []byte) {
+	b.leveldbBatch.Put(b.key(key), value)
+	b.len++
+}
+
+// Delete stages a key deletion.
+func (b *UpdateBatch) Delete(key []byte) {
+	b.leveldbBatch.Delete(b.key(key))
+	b.len++
+}
+
+// Len returns the number of Put/Delete operations staged in this batch.
+func (b *UpdateBatch) Len() int {
+	return b.len
+}
@@ -7,14 +7,18 @@ SPDX-License-Identifier: Apache-2.0
 package viperutil
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -135,37 +139,222 @@ func customDecodeHook(f reflect.Type, t reflect.Type, data interface{}) (interfa
 	return data, nil
 }
 
+// byteSizePattern matches a size string such as "64MB", "2GiB", or (the hook's original,
+// back-compat form) a bare "4g": an integer, a k/m/g/t/p unit, an optional "i" marking it
+// IEC-binary, and an optional "b"; a number with no unit letter does not match. A unit with no "i"
+// and no "b" at all (the back-compat form) is also treated as binary; only <unit>b without "i"
+// (KB, MB, ...) is decimal SI.
+var byteSizePattern = regexp.MustCompile(`(?i)^(-?[0-9]+)\s*([kmgtp])(i?)(b?)$`)
+
+// byteSizeUnitPowers maps a byteSizePattern unit letter to the power its base (1024 or 1000, per
+// byteSizeDecodeHook's binary/decimal determination) is raised to.
+var byteSizeUnitPowers = map[byte]uint{
+	'k': 1,
+	'm': 2,
+	'g': 3,
+	't': 4,
+	'p': 5,
+}
+
+// byteSizeBounds gives the inclusive [min, max] a parsed size must fall within for each target
+// kind byteSizeDecodeHook supports; `int` is treated as 64-bit since fabric only ships on 64-bit
+// platforms.
+var byteSizeBounds = map[reflect.Kind]struct{ min, max *big.Int }{
+	reflect.Uint32: {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint32)},
+	reflect.Uint64: {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+	reflect.Int64:  {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	reflect.Int:    {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+}
+
+// ByteSizeError is returned by byteSizeDecodeHook for a size string it recognizes the shape of
+// but can't actually use - out of range for the target field, or negative for an unsigned one -
+// rather than the hook's previous behavior of either silently passing the raw string through
+// (an unparsable numeral) or returning a value alongside a non-nil error (the old overflow case),
+// which left EnhancedExactUnmarshal reporting the hook's own %s-formatted message with no
+// indication a size hook was even involved.
+type ByteSizeError struct {
+	// Raw is the original size string that failed to parse.
+	Raw string
+	Err error
+}
+
+func (e *ByteSizeError) Error() string {
+	return fmt.Sprintf("invalid byte size %q: %v", e.Raw, e.Err)
+}
+
+func (e *ByteSizeError) Unwrap() error {
+	return e.Err
+}
+
+// byteSizeDecodeHook parses a human-readable size string into an integer byte count for a field
+// typed uint32, uint64, int64, or int - ledger/snapshot size config fields that have outgrown
+// uint32's ~4GiB ceiling. A KiB/MiB/GiB/TiB/PiB suffix is the IEC binary (1024-based) unit; a
+// KB/MB/GB/TB/PB suffix (no "i") is the SI decimal (1000-based) unit; a bare k/m/g/t/p with
+// neither is also binary, the hook's original (pre-IEC/SI) behavior, kept for back-compat.
 func byteSizeDecodeHook(f reflect.Kind, t reflect.Kind, data interface{}) (interface{}, error) {
-	if f != reflect.String || t != reflect.Uint32 {
+	if f != reflect.String {
+		return data, nil
+	}
+	if _, ok := byteSizeBounds[t]; !ok {
 		return data, nil
 	}
 	raw := data.(string)
 	if raw == "" {
 		return data, nil
 	}
-	var re = regexp.MustCompile(`^(?P<size>[0-9]+)\s*(?i)(?P<unit>(k|m|g))b?$`)
-	if re.MatchString(raw) {
-		size, err := strconv.ParseUint(re.ReplaceAllString(raw, "${size}"), 0, 64)
+
+	m := byteSizePattern.FindStringSubmatch(raw)
+	if m == nil {
+		return data, nil
+	}
+	numeral, unitLetter, iecFlag, bFlag := m[1], strings.ToLower(m[2]), m[3], m[4]
+
+	if strings.HasPrefix(numeral, "-") && (t == reflect.Uint32 || t == reflect.Uint64) {
+		return nil, &ByteSizeError{Raw: raw, Err: errors.Errorf("negative size is not valid for an unsigned field")}
+	}
+
+	count, ok := new(big.Int).SetString(numeral, 10)
+	if !ok {
+		// unreachable given byteSizePattern's digit-only numeral group; kept defensive rather
+		// than trusting the regex to never change underneath this code.
+		return nil, &ByteSizeError{Raw: raw, Err: errors.Errorf("not a valid integer")}
+	}
+
+	binary := iecFlag != "" || bFlag == ""
+	base := big.NewInt(1000)
+	if binary {
+		base = big.NewInt(1024)
+	}
+	total := new(big.Int).Set(count)
+	for i := uint(0); i < byteSizeUnitPowers[unitLetter[0]]; i++ {
+		total.Mul(total, base)
+	}
+
+	bounds := byteSizeBounds[t]
+	if total.Cmp(bounds.min) < 0 || total.Cmp(bounds.max) > 0 {
+		return nil, &ByteSizeError{Raw: raw, Err: errors.Errorf("overflows %s", t)}
+	}
+
+	switch t {
+	case reflect.Uint32:
+		return uint32(total.Uint64()), nil
+	case reflect.Uint64:
+		return total.Uint64(), nil
+	case reflect.Int64:
+		return total.Int64(), nil
+	default: // reflect.Int
+		return int(total.Int64()), nil
+	}
+}
+
+// envVarRefPattern matches ${VAR}, ${VAR:-default}, and $VAR references, the subset of shell
+// parameter expansion this package's File:/Secret: path interpolation supports.
+var envVarRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expandEnvRefs substitutes ${VAR}, ${VAR:-default}, and $VAR references in s using os.LookupEnv,
+// in a single left-to-right pass - the replacement text is never itself rescanned for further
+// references, so a variable whose value happens to contain "${VAR}" again cannot recurse.
+// A referenced variable that is unset and has no :-default form is left as an error.
+func expandEnvRefs(s string) (string, error) {
+	var missing string
+	expanded := envVarRefPattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := envVarRefPattern.FindStringSubmatch(match)
+		name := groups[1]
+		hasDefault := groups[2] != "" || strings.Contains(match, ":-")
+		if name == "" {
+			name = groups[3]
+		}
+		if val, ok := os.LookupEnv(name); ok {
+			return val
+		}
+		if hasDefault {
+			return groups[2]
+		}
+		if missing == "" {
+			missing = name
+		}
+		return match
+	})
+	if missing != "" {
+		return "", errors.Errorf("environment variable %q referenced in config is not set and has no default", missing)
+	}
+	return expanded, nil
+}
+
+// maxSecretFileMode rejects a Secret: file that is readable by anyone other than its owner -
+// world-readable (or writable) credential material is the kind of mistake a secret mount is
+// supposed to make impossible to ship.
+const maxSecretFileMode = 0o600
+
+// resolveFileReference is shared by stringFromFileDecodeHook and pemBlocksFromFileDecodeHook: it
+// reads the content a File:, Env:, or Secret: map entry names, after expanding any ${VAR} /
+// $VAR / ${VAR:-default} references in a File:/Secret: path.
+func resolveFileReference(d map[string]interface{}) (content []byte, present bool, err error) {
+	if envName, ok := stringEntry(d, "Env", "env"); ok {
+		val, ok := os.LookupEnv(envName)
+		if !ok {
+			return nil, true, errors.Errorf("environment variable %q referenced by Env: is not set", envName)
+		}
+		return []byte(val), true, nil
+	}
+
+	if secretPath, ok := stringEntry(d, "Secret", "secret"); ok {
+		path, err := expandEnvRefs(secretPath)
 		if err != nil {
-			return data, nil
+			return nil, true, err
 		}
-		unit := re.ReplaceAllString(raw, "${unit}")
-		switch strings.ToLower(unit) {
-		case "g":
-			size = size << 10
-			fallthrough
-		case "m":
-			size = size << 10
-			fallthrough
-		case "k":
-			size = size << 10
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, true, err
+		}
+		if info.Mode().Perm()&^maxSecretFileMode != 0 {
+			return nil, true, errors.Errorf("Secret: file %s is more permissive than 0600, refusing to read it", path)
 		}
-		if size > math.MaxUint32 {
-			return size, fmt.Errorf("value '%s' overflows uint32", raw)
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, true, err
 		}
-		return size, nil
+		return raw, true, nil
 	}
-	return data, nil
+
+	if filePath, ok := stringEntry(d, "File", "file"); ok {
+		path, err := expandEnvRefs(filePath)
+		if err != nil {
+			return nil, true, err
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, true, err
+		}
+		return raw, true, nil
+	}
+	if fileKeyPresent(d, "File") || fileKeyPresent(d, "file") {
+		return nil, true, fmt.Errorf("Value of File: was nil")
+	}
+
+	return nil, false, nil
+}
+
+// fileKeyPresent reports whether d has key at all, used only to preserve this package's existing
+// "Value of File: was nil" error for a File: entry whose value is nil rather than a string.
+func fileKeyPresent(d map[string]interface{}, key string) bool {
+	_, ok := d[key]
+	return ok
+}
+
+// stringEntry looks up key, then keyLower, in d, returning ok=false if neither is present or the
+// value present isn't a string (including nil, which callers report as their own "was nil" error
+// to match this package's existing behavior for a File: key with no value).
+func stringEntry(d map[string]interface{}, key, keyLower string) (string, bool) {
+	val, ok := d[key]
+	if !ok {
+		val, ok = d[keyLower]
+	}
+	if !ok {
+		return "", false
+	}
+	s, ok := val.(string)
+	return s, ok
 }
 
 func stringFromFileDecodeHook(f reflect.Kind, t reflect.Kind, data interface{}) (interface{}, error) {
@@ -182,21 +371,13 @@ func stringFromFileDecodeHook(f reflect.Kind, t reflect.Kind, data interface{})
 	case reflect.String:
 		return data, nil
 	case reflect.Map:
-		d := data.(map[string]interface{})
-		fileName, ok := d["File"]
-		if !ok {
-			fileName, ok = d["file"]
+		d := toStringInterfaceMap(data)
+		content, present, err := resolveFileReference(d)
+		if err != nil {
+			return nil, err
 		}
-		switch {
-		case ok && fileName != nil:
-			bytes, err := ioutil.ReadFile(fileName.(string))
-			if err != nil {
-				return data, err
-			}
-			return string(bytes), nil
-		case ok:
-			// fileName was nil
-			return nil, fmt.Errorf("Value of File: was nil")
+		if present {
+			return string(content), nil
 		}
 	}
 	return data, nil
@@ -216,50 +397,51 @@ func pemBlocksFromFileDecodeHook(f reflect.Kind, t reflect.Kind, data interface{
 	case reflect.String:
 		return data, nil
 	case reflect.Map:
-		var fileName string
-		var ok bool
-		switch d := data.(type) {
-		case map[string]string:
-			fileName, ok = d["File"]
-			if !ok {
-				fileName, ok = d["file"]
-			}
-		case map[string]interface{}:
-			var fileI interface{}
-			fileI, ok = d["File"]
-			if !ok {
-				fileI = d["file"]
-			}
-			fileName, ok = fileI.(string)
+		d := toStringInterfaceMap(data)
+		content, present, err := resolveFileReference(d)
+		if err != nil {
+			return nil, err
+		}
+		if !present {
+			return data, nil
 		}
 
-		switch {
-		case ok && fileName != "":
-			var result []string
-			bytes, err := ioutil.ReadFile(fileName)
-			if err != nil {
-				return data, err
+		var result []string
+		bytes := content
+		for len(bytes) > 0 {
+			var block *pem.Block
+			block, bytes = pem.Decode(bytes)
+			if block == nil {
+				break
 			}
-			for len(bytes) > 0 {
-				var block *pem.Block
-				block, bytes = pem.Decode(bytes)
-				if block == nil {
-					break
-				}
-				if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
-					continue
-				}
-				result = append(result, string(pem.EncodeToMemory(block)))
+			if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+				continue
 			}
-			return result, nil
-		case ok:
-			// fileName was nil
-			return nil, fmt.Errorf("Value of File: was nil")
+			result = append(result, string(pem.EncodeToMemory(block)))
 		}
+		return result, nil
 	}
 	return data, nil
 }
 
+// toStringInterfaceMap normalizes a map[string]string or map[string]interface{} (the two shapes
+// mapstructure hands decode hooks a map source as) into map[string]interface{}, so
+// resolveFileReference only needs to handle one shape.
+func toStringInterfaceMap(data interface{}) map[string]interface{} {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		return d
+	case map[string]string:
+		m := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			m[k] = v
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
 var kafkaVersionConstraints map[sarama.KafkaVersion]version.Constraints
 
 func init() {
@@ -274,9 +456,29 @@ func init() {
 	kafkaVersionConstraints[sarama.V0_10_1_0], _ = version.NewConstraint(">=0.10.1.0,<0.10.2.0")
 	kafkaVersionConstraints[sarama.V0_10_2_0], _ = version.NewConstraint(">=0.10.2.0,<0.11.0.0")
 	kafkaVersionConstraints[sarama.V0_11_0_0], _ = version.NewConstraint(">=0.11.0.0,<1.0.0")
-	kafkaVersionConstraints[sarama.V1_0_0_0], _ = version.NewConstraint(">=1.0.0")
+	kafkaVersionConstraints[sarama.V1_0_0_0], _ = version.NewConstraint(">=1.0.0,<1.0.1")
+	kafkaVersionConstraints[sarama.V1_0_1_0], _ = version.NewConstraint(">=1.0.1,<1.1.0")
+	kafkaVersionConstraints[sarama.V1_1_0_0], _ = version.NewConstraint(">=1.1.0,<1.1.1")
+	kafkaVersionConstraints[sarama.V1_1_1_0], _ = version.NewConstraint(">=1.1.1,<2.0.0")
+	kafkaVersionConstraints[sarama.V2_0_0_0], _ = version.NewConstraint(">=2.0.0,<2.0.1")
+	kafkaVersionConstraints[sarama.V2_0_1_0], _ = version.NewConstraint(">=2.0.1,<2.1.0")
+	kafkaVersionConstraints[sarama.V2_1_0_0], _ = version.NewConstraint(">=2.1.0,<2.2.0")
+	kafkaVersionConstraints[sarama.V2_2_0_0], _ = version.NewConstraint(">=2.2.0,<2.3.0")
+	kafkaVersionConstraints[sarama.V2_3_0_0], _ = version.NewConstraint(">=2.3.0,<2.4.0")
+	kafkaVersionConstraints[sarama.V2_4_0_0], _ = version.NewConstraint(">=2.4.0,<2.5.0")
+	kafkaVersionConstraints[sarama.V2_5_0_0], _ = version.NewConstraint(">=2.5.0,<2.6.0")
+	// highestSupportedKafkaVersion has no upper bound: any 2.6+ version string (including any
+	// as-yet-unreleased 2.x/future major) resolves here rather than erroring, since sarama's wire
+	// protocol negotiation degrades gracefully to the closest version it actually knows.
+	kafkaVersionConstraints[highestSupportedKafkaVersion], _ = version.NewConstraint(">=2.6.0")
 }
 
+// highestSupportedKafkaVersion is the sarama.KafkaVersion the final, unbounded
+// kafkaVersionConstraints entry resolves to - the version kafkaVersionDecodeHook now falls back
+// to for any 1.x/2.x (or later) version string that isn't covered by a narrower constraint above,
+// rather than rejecting it as unsupported.
+var highestSupportedKafkaVersion = sarama.V2_6_0_0
+
 func kafkaVersionDecodeHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
 	if f.Kind() != reflect.String || t != reflect.TypeOf(sarama.KafkaVersion{}) {
 		return data, nil
@@ -296,6 +498,80 @@ func kafkaVersionDecodeHook(f reflect.Type, t reflect.Type, data interface{}) (i
 	return nil, fmt.Errorf("Unsupported Kafka version: '%s'", data)
 }
 
+// KafkaTLSConfig is the Kafka.TLS block of the orderer's Kafka config: whether the broker
+// connection uses TLS, and the client certificate/key/CA material. PrivateKey and Certificate
+// follow the same {File: path}-or-inline-PEM convention stringFromFileDecodeHook already decodes
+// elsewhere in this file; RootCAs follows pemBlocksFromFileDecodeHook's equivalent for a slice of
+// certificates.
+type KafkaTLSConfig struct {
+	Enabled     bool
+	PrivateKey  string
+	Certificate string
+	RootCAs     []string
+}
+
+// KafkaSASLConfig is the Kafka.SASL block of the orderer's Kafka config: whether SASL
+// authentication is used for the broker connection, the credentials, and which SASL mechanism to
+// negotiate.
+type KafkaSASLConfig struct {
+	Enabled   bool
+	User      string
+	Password  string
+	Mechanism sarama.SASLMechanism
+}
+
+// kafkaSASLMechanismDecodeHook validates a Kafka.SASL.Mechanism string against the SASL
+// mechanisms sarama itself supports, rather than letting an unrecognized value reach
+// ResolvedSaramaConfig and fail only once the orderer tries to actually connect to Kafka.
+func kafkaSASLMechanismDecodeHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t != reflect.TypeOf(sarama.SASLMechanism("")) {
+		return data, nil
+	}
+
+	mechanism := sarama.SASLMechanism(data.(string))
+	switch mechanism {
+	case "", sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+		return mechanism, nil
+	default:
+		return nil, fmt.Errorf("Unsupported Kafka SASL mechanism: '%s'", data)
+	}
+}
+
+// ResolvedSaramaConfig builds the *sarama.Config a Kafka producer/consumer needs from the
+// decoded version, TLS, and SASL settings, so orderer code (and tests) have one place to get this
+// translation right instead of re-deriving Net.TLS/Net.SASL field assignments themselves.
+func ResolvedSaramaConfig(kafkaVersion sarama.KafkaVersion, tlsConfig KafkaTLSConfig, saslConfig KafkaSASLConfig) (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.Version = kafkaVersion
+
+	if tlsConfig.Enabled {
+		clientCert, err := tls.X509KeyPair([]byte(tlsConfig.Certificate), []byte(tlsConfig.PrivateKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load Kafka TLS client certificate/key pair")
+		}
+		rootCAPool := x509.NewCertPool()
+		for _, rootCA := range tlsConfig.RootCAs {
+			if !rootCAPool.AppendCertsFromPEM([]byte(rootCA)) {
+				return nil, errors.New("failed to add a Kafka TLS root CA to the certificate pool")
+			}
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = &tls.Config{
+			Certificates: []tls.Certificate{clientCert},
+			RootCAs:      rootCAPool,
+		}
+	}
+
+	if saslConfig.Enabled {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = saslConfig.User
+		config.Net.SASL.Password = saslConfig.Password
+		config.Net.SASL.Mechanism = saslConfig.Mechanism
+	}
+
+	return config, nil
+}
+
 func bccspHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
 	if t != reflect.TypeOf(&factory.FactoryOpts{}) {
 		return data, nil
@@ -311,43 +587,175 @@ func bccspHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, e
 	return config, nil
 }
 
-// EnhancedExactUnmarshal is intended to unmarshal a config file into a structure
-// producing error when extraneous variables are introduced and supporting
-// the time.Duration type
-func EnhancedExactUnmarshal(v *viper.Viper, output interface{}) error {
-	oType := reflect.TypeOf(output)
-	if oType.Kind() != reflect.Ptr {
-		return errors.Errorf("supplied output argument must be a pointer to a struct but is not pointer")
+// fromKey is the reserved key EnhancedExactUnmarshalWithProfiles looks for at the top level and
+// within any nested section: its value names a profile, fetched from the supplied ProfileSource,
+// whose settings are merged in as defaults underneath that section before mapstructure decodes it.
+const fromKey = "from"
+
+// ProfileSource resolves a named configuration profile to the settings it declares, the way a
+// Docker context created `--from` another context resolves that parent context's settings.
+type ProfileSource interface {
+	GetProfile(name string) (map[string]interface{}, error)
+}
+
+// mapProfileSource is a ProfileSource backed by an in-memory table, typically populated by
+// LoadProfilesDir from a directory of profile files.
+type mapProfileSource map[string]map[string]interface{}
+
+func (m mapProfileSource) GetProfile(name string) (map[string]interface{}, error) {
+	profile, ok := m[name]
+	if !ok {
+		return nil, errors.Errorf("no such configuration profile %q", name)
+	}
+	return profile, nil
+}
+
+// LoadProfilesDir builds a ProfileSource from every *.yaml file directly inside dir, naming each
+// profile after its file name with the extension stripped - e.g. profiles/kafka-base.yaml becomes
+// the profile "kafka-base" a config's top-level or per-section `From: kafka-base` can reference.
+func LoadProfilesDir(dir string) (ProfileSource, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
 	}
-	eType := oType.Elem()
-	if eType.Kind() != reflect.Struct {
-		return errors.Errorf("supplied output argument must be a pointer to a struct, but it is pointer to something else")
+
+	profiles := mapProfileSource{}
+	for _, match := range matches {
+		pv := viper.New()
+		pv.SetConfigFile(match)
+		if err := pv.ReadInConfig(); err != nil {
+			return nil, errors.Wrapf(err, "failed to read configuration profile %s", match)
+		}
+		name := strings.TrimSuffix(filepath.Base(match), filepath.Ext(match))
+		profiles[name] = pv.AllSettings()
 	}
+	return profiles, nil
+}
 
-	baseKeys := v.AllSettings()
+// resolveFrom walks section depth-first, resolving any nested section's own `From` key before
+// resolving section's, then - if section itself names a profile - deep-merges the (already
+// resolved) profile's settings in as defaults, with section's own keys taking precedence.
+// chain is the sequence of profile names already being resolved along this lineage, so a profile
+// whose From eventually points back at itself is reported rather than looped on forever.
+func resolveFrom(section map[string]interface{}, source ProfileSource, chain []string) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(section))
+	for k, v := range section {
+		switch nested := v.(type) {
+		case map[string]interface{}:
+			r, err := resolveFrom(nested, source, nil)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = r
+		case map[interface{}]interface{}:
+			tmp := make(map[string]interface{}, len(nested))
+			for ik, iv := range nested {
+				cik, ok := ik.(string)
+				if !ok {
+					return nil, errors.Errorf("non-string key found while resolving config profile inheritance")
+				}
+				tmp[cik] = iv
+			}
+			r, err := resolveFrom(tmp, source, nil)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = r
+		default:
+			resolved[k] = v
+		}
+	}
 
-	getterWithClass := func(key string) interface{} { return v.Get(key) } // hide receiver
-	leafKeys := getKeysRecursively("", getterWithClass, baseKeys, eType)
+	var fromName string
+	var hasFrom bool
+	for k, v := range resolved {
+		if strings.EqualFold(k, fromKey) {
+			fromName, hasFrom = fmt.Sprintf("%v", v), true
+			delete(resolved, k)
+			break
+		}
+	}
+	if !hasFrom {
+		return resolved, nil
+	}
+	if source == nil {
+		return nil, errors.Errorf("config declares From: %q but no configuration profile source was supplied", fromName)
+	}
 
-	logger.Debugf("%+v", leafKeys)
-	config := &mapstructure.DecoderConfig{
-		ErrorUnused:      true,
-		Metadata:         nil,
-		Result:           output,
-		WeaklyTypedInput: true,
-		DecodeHook: mapstructure.ComposeDecodeHookFunc(
-			bccspHook,
-			customDecodeHook,
-			byteSizeDecodeHook,
-			stringFromFileDecodeHook,
-			pemBlocksFromFileDecodeHook,
-			kafkaVersionDecodeHook,
-		),
+	for _, seen := range chain {
+		if seen == fromName {
+			return nil, errors.Errorf("cycle detected in config profile inheritance: %s -> %s", strings.Join(append(chain, fromName), " -> "), fromName)
+		}
 	}
 
-	decoder, err := mapstructure.NewDecoder(config)
+	profile, err := source.GetProfile(fromName)
+	if err != nil {
+		return nil, err
+	}
+	resolvedProfile, err := resolveFrom(profile, source, append(chain, fromName))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return deepMergeDefaults(resolved, resolvedProfile), nil
+}
+
+// deepMergeDefaults merges defaults underneath override: keys present in override always win;
+// where both sides have a nested map for the same key, the merge recurses so a child only needs
+// to declare the specific leaves it overrides.
+func deepMergeDefaults(override, defaults map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults)+len(override))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range override {
+		if overrideMap, ok := v.(map[string]interface{}); ok {
+			if defaultMap, ok := merged[k].(map[string]interface{}); ok {
+				merged[k] = deepMergeDefaults(overrideMap, defaultMap)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// lookupDotted resolves a viper-style dotted key (e.g. "general.ledgertype") against settings,
+// the nested map[string]interface{} tree baseKeys holds after resolveFrom - the same shape
+// v.AllSettings() itself returns, just with any `From` profile merged in. Viper keys are
+// lowercased throughout, so key is lowercased before matching.
+func lookupDotted(settings map[string]interface{}, key string) interface{} {
+	segments := strings.Split(strings.ToLower(key), ".")
+	var cur interface{} = settings
+	for _, seg := range segments {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur, ok = m[seg]
+		if !ok {
+			return nil
+		}
 	}
-	return decoder.Decode(leafKeys)
+	return cur
+}
+
+// EnhancedExactUnmarshal is intended to unmarshal a config file into a structure
+// producing error when extraneous variables are introduced and supporting
+// the time.Duration type
+func EnhancedExactUnmarshal(v *viper.Viper, output interface{}) error {
+	return EnhancedExactUnmarshalWithProfiles(v, output, nil)
+}
+
+// EnhancedExactUnmarshalWithProfiles behaves like EnhancedExactUnmarshal, additionally resolving
+// any top-level or per-section `From` key against profiles - by merging the named profile's
+// settings, fetched from profileSource, underneath the section as defaults - before decoding. A
+// nil profileSource behaves exactly like EnhancedExactUnmarshal: any `From` key encountered is an
+// error rather than silently ignored.
+//
+// Both of these are thin wrappers around NewUnmarshaler - see hooks.go - kept so the large
+// majority of callers that want viperutil's built-in decode hooks and nothing else don't need to
+// build an Unmarshaler themselves.
+func EnhancedExactUnmarshalWithProfiles(v *viper.Viper, output interface{}, profileSource ProfileSource) error {
+	return NewUnmarshaler(WithProfiles(profileSource)).Unmarshal(v, output)
 }
@@ -0,0 +1,397 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package viperutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+type testKafkaConfig struct {
+	Brokers []string
+	Version string
+	Retry   struct {
+		ShortInterval int
+	}
+}
+
+type testBCCSPConfig struct {
+	Default   string
+	HashFamily string
+}
+
+type testProfileConfig struct {
+	Kafka testKafkaConfig
+	BCCSP testBCCSPConfig
+}
+
+func newViperFromYAML(t *testing.T, yamlContent string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(yamlContent)))
+	return v
+}
+
+func TestEnhancedExactUnmarshalWithProfilesMergesDefaults(t *testing.T) {
+	profiles := mapProfileSource{
+		"kafka-base": {
+			"version": "1.0.0",
+			"retry": map[string]interface{}{
+				"shortinterval": 5,
+			},
+		},
+	}
+
+	v := newViperFromYAML(t, `
+kafka:
+  from: kafka-base
+  brokers: [broker1, broker2]
+  retry:
+    shortinterval: 9
+bccsp:
+  default: SW
+  hashfamily: SM3
+`)
+
+	var cfg testProfileConfig
+	require.NoError(t, EnhancedExactUnmarshalWithProfiles(v, &cfg, profiles))
+
+	require.Equal(t, []string{"broker1", "broker2"}, cfg.Kafka.Brokers)
+	require.Equal(t, "1.0.0", cfg.Kafka.Version) // inherited from the kafka-base profile
+	require.Equal(t, 9, cfg.Kafka.Retry.ShortInterval) // child override wins over the profile's 5
+	require.Equal(t, "SW", cfg.BCCSP.Default)
+	require.Equal(t, "SM3", cfg.BCCSP.HashFamily)
+}
+
+func TestEnhancedExactUnmarshalWithProfilesDetectsCycle(t *testing.T) {
+	profiles := mapProfileSource{
+		"a": {"from": "b"},
+		"b": {"from": "a"},
+	}
+
+	v := newViperFromYAML(t, `
+kafka:
+  from: a
+`)
+
+	var cfg testProfileConfig
+	err := EnhancedExactUnmarshalWithProfiles(v, &cfg, profiles)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cycle detected")
+}
+
+func TestEnhancedExactUnmarshalWithProfilesUnknownProfile(t *testing.T) {
+	v := newViperFromYAML(t, `
+kafka:
+  from: does-not-exist
+`)
+
+	var cfg testProfileConfig
+	err := EnhancedExactUnmarshalWithProfiles(v, &cfg, mapProfileSource{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no such configuration profile")
+}
+
+func TestEnhancedExactUnmarshalNoProfileSourceRejectsFrom(t *testing.T) {
+	v := newViperFromYAML(t, `
+kafka:
+  from: kafka-base
+`)
+
+	var cfg testProfileConfig
+	err := EnhancedExactUnmarshal(v, &cfg)
+	require.Error(t, err)
+}
+
+func TestDeepMergeDefaultsChildWins(t *testing.T) {
+	defaults := map[string]interface{}{
+		"a": 1,
+		"nested": map[string]interface{}{
+			"x": "default-x",
+			"y": "default-y",
+		},
+	}
+	override := map[string]interface{}{
+		"nested": map[string]interface{}{
+			"x": "override-x",
+		},
+	}
+
+	merged := deepMergeDefaults(override, defaults)
+	require.Equal(t, 1, merged["a"])
+	nested := merged["nested"].(map[string]interface{})
+	require.Equal(t, "override-x", nested["x"])
+	require.Equal(t, "default-y", nested["y"])
+}
+
+func TestKafkaVersionDecodeHookBroaderRange(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected sarama.KafkaVersion
+	}{
+		{"1.0.0", sarama.V1_0_0_0},
+		{"1.1.1", sarama.V1_1_1_0},
+		{"2.0.1", sarama.V2_0_1_0},
+		{"2.3.5", sarama.V2_3_0_0},  // falls within the 2.3.x bucket
+		{"2.9.0", highestSupportedKafkaVersion}, // beyond any explicit bucket
+		{"3.4.0", highestSupportedKafkaVersion}, // future major, still resolves rather than erroring
+	}
+	for _, tt := range tests {
+		got, err := kafkaVersionDecodeHook(reflect.TypeOf(""), reflect.TypeOf(sarama.KafkaVersion{}), tt.raw)
+		require.NoError(t, err, tt.raw)
+		require.Equal(t, tt.expected, got, tt.raw)
+	}
+}
+
+func TestKafkaVersionDecodeHookRejectsGarbage(t *testing.T) {
+	_, err := kafkaVersionDecodeHook(reflect.TypeOf(""), reflect.TypeOf(sarama.KafkaVersion{}), "not-a-version")
+	require.Error(t, err)
+}
+
+func TestKafkaSASLMechanismDecodeHook(t *testing.T) {
+	saslMechanismType := reflect.TypeOf(sarama.SASLMechanism(""))
+
+	got, err := kafkaSASLMechanismDecodeHook(reflect.TypeOf(""), saslMechanismType, string(sarama.SASLTypeSCRAMSHA256))
+	require.NoError(t, err)
+	require.Equal(t, sarama.SASLTypeSCRAMSHA256, got)
+
+	_, err = kafkaSASLMechanismDecodeHook(reflect.TypeOf(""), saslMechanismType, "not-a-mechanism")
+	require.Error(t, err)
+}
+
+func TestResolvedSaramaConfigPlaintext(t *testing.T) {
+	config, err := ResolvedSaramaConfig(sarama.V2_3_0_0, KafkaTLSConfig{}, KafkaSASLConfig{})
+	require.NoError(t, err)
+	require.Equal(t, sarama.V2_3_0_0, config.Version)
+	require.False(t, config.Net.TLS.Enable)
+	require.False(t, config.Net.SASL.Enable)
+}
+
+func TestResolvedSaramaConfigSASL(t *testing.T) {
+	config, err := ResolvedSaramaConfig(sarama.V2_3_0_0, KafkaTLSConfig{}, KafkaSASLConfig{
+		Enabled:   true,
+		User:      "alice",
+		Password:  "secret",
+		Mechanism: sarama.SASLTypeSCRAMSHA256,
+	})
+	require.NoError(t, err)
+	require.True(t, config.Net.SASL.Enable)
+	require.Equal(t, "alice", config.Net.SASL.User)
+	require.Equal(t, sarama.SASLTypeSCRAMSHA256, config.Net.SASL.Mechanism)
+}
+
+func TestExpandEnvRefsSubstitutesAndDefaults(t *testing.T) {
+	require.NoError(t, os.Setenv("VIPERUTIL_TEST_VAR", "bar"))
+	defer os.Unsetenv("VIPERUTIL_TEST_VAR")
+
+	got, err := expandEnvRefs("foo/${VIPERUTIL_TEST_VAR}/$VIPERUTIL_TEST_VAR/${VIPERUTIL_TEST_UNSET:-fallback}")
+	require.NoError(t, err)
+	require.Equal(t, "foo/bar/bar/fallback", got)
+}
+
+func TestExpandEnvRefsMissingVariableErrors(t *testing.T) {
+	require.NoError(t, os.Unsetenv("VIPERUTIL_TEST_UNSET"))
+
+	_, err := expandEnvRefs("${VIPERUTIL_TEST_UNSET}")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "VIPERUTIL_TEST_UNSET")
+}
+
+func TestExpandEnvRefsDoesNotRescanSubstitutedText(t *testing.T) {
+	// A value that happens to look like another reference, once substituted in, must not be
+	// expanded a second time - expandEnvRefs is a single left-to-right pass over the original
+	// string, not a fixed-point loop, so self-referential-looking values terminate.
+	require.NoError(t, os.Setenv("VIPERUTIL_TEST_OUTER", "${VIPERUTIL_TEST_INNER}"))
+	defer os.Unsetenv("VIPERUTIL_TEST_OUTER")
+	require.NoError(t, os.Setenv("VIPERUTIL_TEST_INNER", "should-not-appear"))
+	defer os.Unsetenv("VIPERUTIL_TEST_INNER")
+
+	got, err := expandEnvRefs("${VIPERUTIL_TEST_OUTER}")
+	require.NoError(t, err)
+	require.Equal(t, "${VIPERUTIL_TEST_INNER}", got)
+}
+
+func TestStringFromFileDecodeHookEnv(t *testing.T) {
+	require.NoError(t, os.Setenv("VIPERUTIL_TEST_ENV_HOOK", "hook-value"))
+	defer os.Unsetenv("VIPERUTIL_TEST_ENV_HOOK")
+
+	got, err := stringFromFileDecodeHook(reflect.Map, reflect.String, map[string]interface{}{"Env": "VIPERUTIL_TEST_ENV_HOOK"})
+	require.NoError(t, err)
+	require.Equal(t, "hook-value", got)
+
+	_, err = stringFromFileDecodeHook(reflect.Map, reflect.String, map[string]interface{}{"Env": "VIPERUTIL_TEST_ENV_HOOK_UNSET"})
+	require.Error(t, err)
+}
+
+func TestStringFromFileDecodeHookFileExpandsEnvInPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "value.txt")
+	require.NoError(t, os.WriteFile(path, []byte("file-contents"), 0o600))
+
+	require.NoError(t, os.Setenv("VIPERUTIL_TEST_FILE_DIR", dir))
+	defer os.Unsetenv("VIPERUTIL_TEST_FILE_DIR")
+
+	got, err := stringFromFileDecodeHook(reflect.Map, reflect.String, map[string]interface{}{"File": "${VIPERUTIL_TEST_FILE_DIR}/value.txt"})
+	require.NoError(t, err)
+	require.Equal(t, "file-contents", got)
+}
+
+func TestStringFromFileDecodeHookSecretRejectsPermissiveMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.txt")
+	require.NoError(t, os.WriteFile(path, []byte("top-secret"), 0o644))
+
+	_, err := stringFromFileDecodeHook(reflect.Map, reflect.String, map[string]interface{}{"Secret": path})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "more permissive than 0600")
+}
+
+func TestStringFromFileDecodeHookSecretAcceptsRestrictiveMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.txt")
+	require.NoError(t, os.WriteFile(path, []byte("top-secret"), 0o600))
+
+	got, err := stringFromFileDecodeHook(reflect.Map, reflect.String, map[string]interface{}{"Secret": path})
+	require.NoError(t, err)
+	require.Equal(t, "top-secret", got)
+}
+
+func TestByteSizeDecodeHook(t *testing.T) {
+	tests := []struct {
+		raw      string
+		target   reflect.Kind
+		expected interface{}
+	}{
+		{"4k", reflect.Uint32, uint32(4 << 10)},            // bare unit: binary, back-compat
+		{"4m", reflect.Uint32, uint32(4 << 20)},
+		{"3g", reflect.Uint32, uint32(3 << 30)},
+		{"4KB", reflect.Uint64, uint64(4000)},               // decimal SI
+		{"4MB", reflect.Uint64, uint64(4_000_000)},
+		{"4GB", reflect.Uint64, uint64(4_000_000_000)},
+		{"4KiB", reflect.Uint64, uint64(4 << 10)},           // IEC binary
+		{"4MiB", reflect.Uint64, uint64(4 << 20)},
+		{"4GiB", reflect.Uint64, uint64(4 << 30)},
+		{"2TB", reflect.Uint64, uint64(2_000_000_000_000)},
+		{"2TiB", reflect.Uint64, uint64(2) << 40},
+		{"1PB", reflect.Uint64, uint64(1_000_000_000_000_000)},
+		{"1PiB", reflect.Int64, int64(1) << 50},
+		{"8EiB", reflect.Int, nil},                          // not a recognized unit letter
+		{"-5MB", reflect.Int64, int64(-5_000_000)},          // negative allowed for signed targets
+		{"not-a-size", reflect.Uint32, nil},                 // passed through unchanged
+	}
+
+	for _, tt := range tests {
+		got, err := byteSizeDecodeHook(reflect.String, tt.target, tt.raw)
+		require.NoError(t, err, tt.raw)
+		if tt.expected == nil {
+			require.Equal(t, tt.raw, got, tt.raw)
+			continue
+		}
+		require.Equal(t, tt.expected, got, tt.raw)
+	}
+}
+
+func TestByteSizeDecodeHookRejectsNegativeForUnsignedTarget(t *testing.T) {
+	_, err := byteSizeDecodeHook(reflect.String, reflect.Uint64, "-1MB")
+	require.Error(t, err)
+	var sizeErr *ByteSizeError
+	require.True(t, errors.As(err, &sizeErr))
+	require.Contains(t, sizeErr.Error(), "negative size")
+}
+
+func TestByteSizeDecodeHookRejectsOverflow(t *testing.T) {
+	_, err := byteSizeDecodeHook(reflect.String, reflect.Uint32, "5GB")
+	require.Error(t, err)
+	var sizeErr *ByteSizeError
+	require.True(t, errors.As(err, &sizeErr))
+	require.Contains(t, sizeErr.Error(), "overflows")
+}
+
+func TestRegisterDecodeHookDuplicatePanics(t *testing.T) {
+	RegisterDecodeHook("test-dup-hook", customDecodeHook)
+	require.Panics(t, func() {
+		RegisterDecodeHook("test-dup-hook", customDecodeHook)
+	})
+}
+
+func TestWithHooksUnregisteredNameErrors(t *testing.T) {
+	v := newViperFromYAML(t, `kafka: {brokers: [broker1]}`)
+
+	var cfg testProfileConfig
+	err := NewUnmarshaler(WithHooks("does-not-exist")).Unmarshal(v, &cfg)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does-not-exist")
+}
+
+func TestUnmarshalerWithHooksExtendsBuiltins(t *testing.T) {
+	RegisterDecodeHook("test-upper-hashfamily", func(f, t reflect.Kind, data interface{}) (interface{}, error) {
+		if f != reflect.String || t != reflect.String {
+			return data, nil
+		}
+		return strings.ToUpper(data.(string)), nil
+	})
+
+	v := newViperFromYAML(t, `
+kafka:
+  brokers: [broker1]
+bccsp:
+  default: SW
+  hashfamily: sm3
+`)
+
+	var cfg testProfileConfig
+	u := NewUnmarshaler(WithHooks("test-upper-hashfamily"))
+	require.NoError(t, u.Unmarshal(v, &cfg))
+	require.Equal(t, "SM3", cfg.BCCSP.HashFamily)
+}
+
+func TestUnmarshalerDecodeErrorReportsKeyAndHook(t *testing.T) {
+	type kafkaVersionConfig struct {
+		Version sarama.KafkaVersion
+	}
+
+	v := newViperFromYAML(t, `version: not-a-version`)
+
+	var cfg kafkaVersionConfig
+	err := NewUnmarshaler().Unmarshal(v, &cfg)
+	require.Error(t, err)
+
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	require.Equal(t, "kafka-version", decodeErr.Hook)
+	require.Contains(t, decodeErr.Key, "Version")
+}
+
+func TestEnhancedExactUnmarshalResolvesSecretWithoutLeakingKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hashfamily.txt")
+	require.NoError(t, os.WriteFile(path, []byte("SM3"), 0o600))
+
+	v := newViperFromYAML(t, `
+kafka:
+  brokers: [broker1]
+bccsp:
+  default: SW
+  hashfamily:
+    Secret: `+path+`
+`)
+
+	var cfg testProfileConfig
+	// ErrorUnused is on; if resolveFileReference's Secret handling leaked the "Secret" map key
+	// through to mapstructure instead of collapsing it to a plain string, this would fail as an
+	// unused/unrecognized field.
+	require.NoError(t, EnhancedExactUnmarshal(v, &cfg))
+	require.Equal(t, "SM3", cfg.BCCSP.HashFamily)
+}
@@ -0,0 +1,267 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package viperutil
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// decodeHookRegistryMu guards decodeHookRegistry, populated both by this package's own init()
+// (registering its built-in hooks under stable names) and by any downstream package - orderer
+// localconfig, peer core config, MSP - that calls RegisterDecodeHook from its own init().
+var (
+	decodeHookRegistryMu sync.Mutex
+	decodeHookRegistry   = map[string]mapstructure.DecodeHookFunc{}
+)
+
+// RegisterDecodeHook adds a named mapstructure.DecodeHookFunc to the registry WithHooks draws
+// from, so a downstream package can contribute its own decode hook - a polymorphic
+// Orderer.ConsensusType hook, a chaincode.ExternalBuilders slice hook - without this package
+// growing a case for every caller. Registering the same name twice panics: hook names are meant
+// to be stable, one-per-concern identifiers, not something two packages can silently clobber.
+func RegisterDecodeHook(name string, h mapstructure.DecodeHookFunc) {
+	decodeHookRegistryMu.Lock()
+	defer decodeHookRegistryMu.Unlock()
+	if _, exists := decodeHookRegistry[name]; exists {
+		panic(fmt.Sprintf("viperutil: decode hook %q already registered", name))
+	}
+	decodeHookRegistry[name] = h
+}
+
+func lookupDecodeHook(name string) (mapstructure.DecodeHookFunc, bool) {
+	decodeHookRegistryMu.Lock()
+	defer decodeHookRegistryMu.Unlock()
+	h, ok := decodeHookRegistry[name]
+	return h, ok
+}
+
+// builtinDecodeHookNames is the fixed decode hook list EnhancedExactUnmarshal has always used,
+// now registered under names of their own so NewUnmarshaler can start from the same set and a
+// WithHooks caller can extend it instead of re-declaring it.
+var builtinDecodeHookNames = []string{
+	"bccsp",
+	"duration-and-bracket-slice",
+	"byte-size",
+	"file-string",
+	"file-pem-blocks",
+	"kafka-version",
+	"kafka-sasl-mechanism",
+}
+
+func init() {
+	RegisterDecodeHook("bccsp", bccspHook)
+	RegisterDecodeHook("duration-and-bracket-slice", customDecodeHook)
+	RegisterDecodeHook("byte-size", byteSizeDecodeHook)
+	RegisterDecodeHook("file-string", stringFromFileDecodeHook)
+	RegisterDecodeHook("file-pem-blocks", pemBlocksFromFileDecodeHook)
+	RegisterDecodeHook("kafka-version", kafkaVersionDecodeHook)
+	RegisterDecodeHook("kafka-sasl-mechanism", kafkaSASLMechanismDecodeHook)
+}
+
+// Unmarshaler decodes a viper config into a struct using EnhancedExactUnmarshal's own
+// getKeysRecursively/resolveFrom machinery, but with a configurable decode hook list instead of
+// EnhancedExactUnmarshal's fixed one - built with NewUnmarshaler and the WithHooks/WithProfiles
+// options below.
+type Unmarshaler struct {
+	profileSource ProfileSource
+	hookNames     []string
+}
+
+// UnmarshalerOption configures an Unmarshaler built by NewUnmarshaler.
+type UnmarshalerOption func(*Unmarshaler)
+
+// WithHooks appends the named, previously-registered decode hooks (see RegisterDecodeHook) to an
+// Unmarshaler's hook list, in the order given. An unregistered name is not rejected here; it
+// surfaces as an error from Unmarshal instead, consistent with how an unresolved `From` profile
+// already surfaces at decode time rather than construction time in this package.
+func WithHooks(names ...string) UnmarshalerOption {
+	return func(u *Unmarshaler) {
+		u.hookNames = append(u.hookNames, names...)
+	}
+}
+
+// WithProfiles attaches the ProfileSource an Unmarshaler resolves `From` keys against,
+// equivalent to EnhancedExactUnmarshalWithProfiles's profileSource argument. A nil source (the
+// zero value) rejects any `From` key encountered, same as EnhancedExactUnmarshal.
+func WithProfiles(source ProfileSource) UnmarshalerOption {
+	return func(u *Unmarshaler) {
+		u.profileSource = source
+	}
+}
+
+// NewUnmarshaler builds an Unmarshaler starting from viperutil's own built-in decode hooks - the
+// same set EnhancedExactUnmarshal has always used - plus whatever options are supplied.
+func NewUnmarshaler(opts ...UnmarshalerOption) *Unmarshaler {
+	u := &Unmarshaler{hookNames: append([]string{}, builtinDecodeHookNames...)}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Unmarshal decodes v into output, an error if output is not a pointer to a struct or if
+// mapstructure rejects the decode (extraneous keys, or a hook/type-conversion failure - returned
+// as a *DecodeError). It is the Unmarshaler equivalent of EnhancedExactUnmarshalWithProfiles.
+func (u *Unmarshaler) Unmarshal(v *viper.Viper, output interface{}) error {
+	oType := reflect.TypeOf(output)
+	if oType.Kind() != reflect.Ptr {
+		return errors.Errorf("supplied output argument must be a pointer to a struct but is not pointer")
+	}
+	eType := oType.Elem()
+	if eType.Kind() != reflect.Struct {
+		return errors.Errorf("supplied output argument must be a pointer to a struct, but it is pointer to something else")
+	}
+
+	baseKeys := v.AllSettings()
+	baseKeys, err := resolveFrom(baseKeys, u.profileSource, nil)
+	if err != nil {
+		return err
+	}
+
+	getterWithClass := func(key string) interface{} {
+		if val := lookupDotted(baseKeys, key); val != nil {
+			return val
+		}
+		return v.Get(key) // not covered by baseKeys (e.g. a field with no config or profile default at all)
+	} // hide receiver
+	leafKeys := getKeysRecursively("", getterWithClass, baseKeys, eType)
+
+	logger.Debugf("%+v", leafKeys)
+
+	var lastFailure *hookFailure
+	hooks := make([]mapstructure.DecodeHookFunc, 0, len(u.hookNames))
+	for _, name := range u.hookNames {
+		h, ok := lookupDecodeHook(name)
+		if !ok {
+			return errors.Errorf("viperutil: no decode hook registered under name %q", name)
+		}
+		hooks = append(hooks, wrapDecodeHook(name, h, &lastFailure))
+	}
+
+	config := &mapstructure.DecoderConfig{
+		ErrorUnused:      true,
+		Metadata:         nil,
+		Result:           output,
+		WeaklyTypedInput: true,
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(hooks...),
+	}
+
+	decoder, err := mapstructure.NewDecoder(config)
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(leafKeys); err != nil {
+		return newDecodeError(v, err, lastFailure)
+	}
+	return nil
+}
+
+// hookFailure is how wrapDecodeHook reports a hook's own error back to Unmarshal, across the
+// mapstructure.DecodeHookFunc boundary that only returns (interface{}, error) - capturing the
+// expected type right there, since it's only available at the point the hook is called, not once
+// mapstructure has folded the error into its own message text.
+type hookFailure struct {
+	hook         string
+	expectedType reflect.Type
+	err          error
+}
+
+// wrapDecodeHook runs h and, on error, records a hookFailure into *last before returning the
+// error unchanged, so mapstructure's own error propagation (and its field-path-bearing message)
+// is untouched; newDecodeError reattaches the recorded hook name/type afterward.
+func wrapDecodeHook(name string, h mapstructure.DecodeHookFunc, last **hookFailure) mapstructure.DecodeHookFunc {
+	return mapstructure.DecodeHookFuncType(func(f, t reflect.Type, data interface{}) (interface{}, error) {
+		out, err := mapstructure.DecodeHookExec(h, f, t, data)
+		if err != nil {
+			*last = &hookFailure{hook: name, expectedType: t, err: err}
+		}
+		return out, err
+	})
+}
+
+// decodeErrorKeyPattern pulls the dotted field path out of a mapstructure per-field error
+// message, the only place that path is recorded - both its own type-conversion errors
+// ("'Kafka.Version' expected type ...") and its decode-hook wrapper ("error decoding
+// 'Kafka.Version': ...") lead with the key in single quotes.
+var decodeErrorKeyPattern = regexp.MustCompile(`^(?:error decoding )?'([^']+)'`)
+
+// DecodeError is returned by Unmarshal (and, via it, EnhancedExactUnmarshal) in place of
+// mapstructure's own multierror text, so a caller - typically a CLI reporting a config mistake to
+// a human - can point at the offending key and config file instead of relaying mapstructure's
+// message verbatim.
+type DecodeError struct {
+	// Key is the dotted field path mapstructure was decoding when it failed, e.g.
+	// "Kafka.Retry.ShortInterval". Empty if it could not be parsed out of the underlying error.
+	Key string
+	// File is the config file Key was read from, from v.ConfigFileUsed(). Empty if the Viper was
+	// not backed by a config file (e.g. keys set only via Set or environment binding).
+	File string
+	// Line is always 0: viper tracks only the config file as a whole, not a per-key source line.
+	Line int
+	// ExpectedType is the Go type the failing decode hook was asked to produce. Nil unless Hook
+	// is set: a plain mapstructure type-conversion failure (no hook involved) doesn't carry one.
+	ExpectedType reflect.Type
+	// Hook names the registered decode hook that rejected the value. Empty if the failure was
+	// mapstructure's own type-conversion error rather than a hook's.
+	Hook string
+	// Err is the underlying error returned by the hook, or by mapstructure itself.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	key := e.Key
+	if key == "" {
+		key = "<unknown key>"
+	}
+	if e.File != "" {
+		key = fmt.Sprintf("%s (in %s)", key, e.File)
+	}
+	if e.Hook != "" {
+		return fmt.Sprintf("%s: decode hook %q rejected value (expected %s): %v", key, e.Hook, e.ExpectedType, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// newDecodeError wraps a mapstructure decode failure into a *DecodeError, filling in the field
+// path parsed out of mapstructure's own message and, if exactly one decode hook failed while this
+// Unmarshal call ran, that hook's name and expected type (recorded by wrapDecodeHook - mapstructure's
+// message itself never names which hook produced an error). When several hooks fail across
+// different fields in one Decode call, only the last one recorded is attributed; the full
+// multierror text is still reachable via Err.
+func newDecodeError(v *viper.Viper, err error, last *hookFailure) error {
+	message := err.Error()
+	if merr, ok := err.(*mapstructure.Error); ok && len(merr.Errors) > 0 {
+		message = merr.Errors[0]
+	}
+
+	key := ""
+	if m := decodeErrorKeyPattern.FindStringSubmatch(message); m != nil {
+		key = m[1]
+	}
+
+	de := &DecodeError{
+		Key:  key,
+		File: v.ConfigFileUsed(),
+		Err:  err,
+	}
+	if last != nil {
+		de.Hook = last.hook
+		de.ExpectedType = last.expectedType
+	}
+	return de
+}
@@ -0,0 +1,31 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package platforms
+
+import "io"
+
+// BuildBackend describes one way of turning a chaincode package's source at path into the
+// binpackage.tar a launcher can hand to the docker daemon (or an equivalent runtime). golang.
+// Platform's DockerBuildOptions is one implementation, driving a shell script fed to the docker
+// daemon; a BuildKit-based implementation would instead describe the same base-image, source-copy,
+// and module/vendor/GOPATH-conditional go build steps as an LLB build graph sent to a buildkitd
+// endpoint, giving rootless/daemonless builds and cacheable layer reuse across chaincode installs.
+//
+// This checkout's core/chaincode/platforms/golang has no platform.go - Platform, GenerateDockerfile,
+// and the util.DockerBuildOptions type this interface is meant to sit alongside only appear in
+// platform_test.go - and no vendored moby/buildkit client or llb package is present either, so a
+// concrete BuildKitBuild/BuildBackend implementation and its chaincode.builder=buildkit|docker peer
+// config wiring cannot be built against real code here. This interface records the extension point
+// so both backends can be added once golang.Platform exists in this checkout.
+type BuildBackend interface {
+	// Name identifies the backend, e.g. for the chaincode.builder=buildkit|docker config switch.
+	Name() string
+
+	// Build produces the binpackage.tar for the chaincode package at path, streaming build
+	// progress to the supplied logger as it goes.
+	Build(path string, progress io.Writer) (binpackage io.Reader, err error)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package golang
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const sampleGoSum = `github.com/pkg/errors v0.9.1 h1:FEBLx1zS214owpjy7qsBeixbURkuhQAwrK5UwLGTwt4=
+github.com/pkg/errors v0.9.1/go.mod h1:bwawxfHBFNV+L2hUp1rHADufV3IMtnDRdf1r5NINEl0=
+github.com/stretchr/testify v1.7.0 h1:nwc3DEeHmmLAfoZucVR881uASk0Mfjw8xYJ99tb5CcY=
+github.com/stretchr/testify v1.7.0/go.mod h1:6Fq8oRcR53rry900zMqJjRRixrwX3KX962/h/Wwjteg=
+`
+
+func TestParseGoSum(t *testing.T) {
+	records, err := parseGoSum([]byte(sampleGoSum))
+	require.NoError(t, err)
+	require.Equal(t, []moduleRecord{
+		{Path: "github.com/pkg/errors", Version: "v0.9.1", Sum: "h1:FEBLx1zS214owpjy7qsBeixbURkuhQAwrK5UwLGTwt4="},
+		{Path: "github.com/stretchr/testify", Version: "v1.7.0", Sum: "h1:nwc3DEeHmmLAfoZucVR881uASk0Mfjw8xYJ99tb5CcY="},
+	}, records)
+}
+
+func TestParseGoSumMalformedLine(t *testing.T) {
+	_, err := parseGoSum([]byte("github.com/pkg/errors v0.9.1\n"))
+	require.Error(t, err)
+}
+
+func TestBuildDependencyManifest(t *testing.T) {
+	manifestBytes, err := buildDependencyManifest([]byte(sampleGoSum))
+	require.NoError(t, err)
+
+	var manifest spdxLiteManifest
+	require.NoError(t, json.Unmarshal(manifestBytes, &manifest))
+	require.Equal(t, "SPDX-lite-1", manifest.SPDXVersion)
+	require.Len(t, manifest.Packages, 2)
+	require.Equal(t, "github.com/pkg/errors", manifest.Packages[0].Path)
+}
+
+const sampleGoModGraph = `github.com/hyperledger/fabric github.com/pkg/errors@v0.9.1
+github.com/hyperledger/fabric github.com/stretchr/testify@v1.7.0
+github.com/stretchr/testify@v1.7.0 github.com/pkg/errors@v0.9.1
+`
+
+func TestParseGoModGraph(t *testing.T) {
+	modules, err := parseGoModGraph([]byte(sampleGoModGraph))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"github.com/hyperledger/fabric",
+		"github.com/pkg/errors",
+		"github.com/stretchr/testify",
+	}, modules)
+}
+
+func TestParseGoModGraphMalformedLine(t *testing.T) {
+	_, err := parseGoModGraph([]byte("github.com/hyperledger/fabric\n"))
+	require.Error(t, err)
+}
+
+func TestMatchesDeniedModule(t *testing.T) {
+	tests := []struct {
+		modulePath string
+		patterns   []string
+		matches    bool
+	}{
+		{modulePath: "github.com/evil/malware", patterns: []string{"github.com/evil/*"}, matches: true},
+		{modulePath: "github.com/good/lib", patterns: []string{"github.com/evil/*"}, matches: false},
+		{modulePath: "github.com/evil/malware", patterns: nil, matches: false},
+	}
+	for _, tt := range tests {
+		matched, err := matchesDeniedModule(tt.modulePath, tt.patterns)
+		require.NoError(t, err)
+		require.Equal(t, tt.matches, matched)
+	}
+}
+
+func TestMatchesDeniedModuleInvalidPattern(t *testing.T) {
+	_, err := matchesDeniedModule("github.com/good/lib", []string{"["})
+	require.Error(t, err)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package golang
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// goWorkFile is the name of the file a Go workspace root is marked by, per "go help work".
+const goWorkFile = "go.work"
+
+// goWorkspace is the result of parsing a go.work file: the set of module directories its `use`
+// directives list, relative to the workspace root.
+type goWorkspace struct {
+	// root is the directory goWorkFile was found in.
+	root string
+	// use lists the directories named by `use` directives, relative to root.
+	use []string
+}
+
+// findGoWorkspace looks for a go.work file starting at ccPath and walking up its parent directories,
+// checking at most maxWorkspaceSearchDepth directories in all (ccPath included), mirroring how `go`
+// itself locates a workspace root.
+// It returns nil, nil if no go.work file is found within that bound.
+func findGoWorkspace(ccPath string) (*goWorkspace, error) {
+	const maxWorkspaceSearchDepth = 10
+
+	dir, err := filepath.Abs(ccPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < maxWorkspaceSearchDepth; i++ {
+		goWorkPath := filepath.Join(dir, goWorkFile)
+		if _, err := os.Stat(goWorkPath); err == nil {
+			return parseGoWork(dir, goWorkPath)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return nil, nil
+}
+
+// parseGoWork extracts the `use` directives from the go.work file at goWorkPath, in either their
+// single-line (`use ./foo`) or block (`use (\n\t./foo\n\t./bar\n)`) form. It deliberately does not
+// interpret `go`, `toolchain`, or `replace` directives: this is only as much of go.work as
+// packaging a workspace's modules requires.
+func parseGoWork(root, goWorkPath string) (*goWorkspace, error) {
+	f, err := os.Open(goWorkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ws := &goWorkspace{root: root}
+	inUseBlock := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		switch {
+		case inUseBlock:
+			if line == ")" {
+				inUseBlock = false
+				continue
+			}
+			ws.use = append(ws.use, unquote(stripLineComment(line)))
+		case line == "use (":
+			inUseBlock = true
+		case strings.HasPrefix(line, "use "):
+			ws.use = append(ws.use, unquote(stripLineComment(strings.TrimSpace(strings.TrimPrefix(line, "use ")))))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inUseBlock {
+		return nil, errors.Errorf("%s: unterminated use block", goWorkPath)
+	}
+	return ws, nil
+}
+
+func stripLineComment(s string) string {
+	if idx := strings.Index(s, "//"); idx >= 0 {
+		s = s[:idx]
+	}
+	return strings.TrimSpace(s)
+}
+
+// unquote strips the double quotes go.work allows around a use path (needed for paths containing
+// spaces), mirroring how `go` itself accepts both `use ./foo` and `use "./foo bar"`.
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
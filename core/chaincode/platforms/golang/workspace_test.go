@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindGoWorkspace(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "find-go-workspace")
+	require.NoError(t, err, "failed to create temporary directory")
+	defer os.RemoveAll(tempdir)
+
+	t.Run("NoWorkspace", func(t *testing.T) {
+		ws, err := findGoWorkspace(tempdir)
+		require.NoError(t, err)
+		require.Nil(t, ws)
+	})
+
+	t.Run("SingleLineUse", func(t *testing.T) {
+		root := filepath.Join(tempdir, "single")
+		require.NoError(t, os.Mkdir(root, 0o755))
+		writeGoWork(t, root, "go 1.20\n\nuse ./chaincode\n")
+
+		ws, err := findGoWorkspace(root)
+		require.NoError(t, err)
+		require.Equal(t, root, ws.root)
+		require.Equal(t, []string{"./chaincode"}, ws.use)
+	})
+
+	t.Run("UseBlock", func(t *testing.T) {
+		root := filepath.Join(tempdir, "block")
+		require.NoError(t, os.Mkdir(root, 0o755))
+		writeGoWork(t, root, "go 1.20\n\nuse (\n\t./chaincode\n\t./helper // shared library\n)\n")
+
+		ws, err := findGoWorkspace(root)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./chaincode", "./helper"}, ws.use)
+	})
+
+	t.Run("QuotedPathWithSpace", func(t *testing.T) {
+		root := filepath.Join(tempdir, "quoted")
+		require.NoError(t, os.Mkdir(root, 0o755))
+		writeGoWork(t, root, "go 1.20\n\nuse \"./my chaincode\"\n")
+
+		ws, err := findGoWorkspace(root)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./my chaincode"}, ws.use)
+	})
+
+	t.Run("FoundFromSubdirectory", func(t *testing.T) {
+		root := filepath.Join(tempdir, "nested")
+		sub := filepath.Join(root, "chaincode", "pkg")
+		require.NoError(t, os.MkdirAll(sub, 0o755))
+		writeGoWork(t, root, "go 1.20\n\nuse ./chaincode\n")
+
+		ws, err := findGoWorkspace(sub)
+		require.NoError(t, err)
+		require.Equal(t, root, ws.root)
+	})
+}
+
+func writeGoWork(t *testing.T, dir, contents string) {
+	t.Helper()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, goWorkFile), []byte(contents), 0o644))
+}
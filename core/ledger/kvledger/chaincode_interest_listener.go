@@ -0,0 +1,172 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvledger
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// chaincodeInterestListener is a built-in ledger.StateListener that derives
+// each commit's ChaincodeInterest - which namespaces, and which of their
+// private-data collections, were written - from the updates
+// StateUpdateTrigger delivers. The Gateway endorsement planner consults it
+// instead of relying on ad-hoc chaincode-side discovery.
+//
+// StateUpdateTrigger aggregates updates per namespace for a whole commit
+// rather than per transaction, so interests are recorded per commit, in
+// commit order, via Interest(n); once StateUpdateTrigger carries a TxID this
+// can key on it directly instead.
+type chaincodeInterestListener struct {
+	namespaces  []string
+	collections map[string][]string // namespace -> collections this listener watches
+
+	mu       sync.RWMutex
+	byCommit []*peer.ChaincodeInterest
+}
+
+// newChaincodeInterestListener constructs a listener that derives interest
+// over namespaces, and, for each namespace, the private-data collections
+// named in collections[namespace].
+func newChaincodeInterestListener(namespaces []string, collections map[string][]string) *chaincodeInterestListener {
+	return &chaincodeInterestListener{
+		namespaces:  namespaces,
+		collections: collections,
+	}
+}
+
+func (l *chaincodeInterestListener) Name() string {
+	return "chaincode interest listener"
+}
+
+func (l *chaincodeInterestListener) Initialize(ledgerID string, qe ledger.SimpleQueryExecutor) error {
+	return nil
+}
+
+// EncodeSnapshot serializes byCommit as-is, so InitializeFromSnapshot can resume exactly where
+// this listener left off: its state is nothing but the per-commit interests accumulated so far, so
+// there is no namespace range scan to avoid replaying, only the commit history to restore.
+func (l *chaincodeInterestListener) EncodeSnapshot() ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return json.Marshal(l.byCommit)
+}
+
+// InitializeFromSnapshot restores byCommit from a snapshot EncodeSnapshot produced, standing in
+// for Initialize's (here, trivial) bootstrap. qe and blockHeight are unused: this listener's state
+// is derived entirely from HandleStateUpdates, so InitializeListeners replaying the commits between
+// the snapshot height and the current ledger height is what brings it fully up to date, not
+// anything read back from the ledger here.
+func (l *chaincodeInterestListener) InitializeFromSnapshot(ledgerID string, snapshot []byte, blockHeight uint64, qe ledger.SimpleQueryExecutor) error {
+	var byCommit []*peer.ChaincodeInterest
+	if err := json.Unmarshal(snapshot, &byCommit); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.byCommit = byCommit
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *chaincodeInterestListener) InterestedInNamespaces() []string {
+	return l.namespaces
+}
+
+// InterestedInCollections returns every collection this listener watches,
+// across all of its namespaces, so it is delivered their CollectionUpdates/
+// HashedUpdates alongside the namespaces' PublicUpdates.
+func (l *chaincodeInterestListener) InterestedInCollections() []string {
+	var colls []string
+	for _, cs := range l.collections {
+		colls = append(colls, cs...)
+	}
+	return colls
+}
+
+func (l *chaincodeInterestListener) HandleStateUpdates(trigger *ledger.StateUpdateTrigger) error {
+	interest := &peer.ChaincodeInterest{}
+	for _, ns := range l.namespaces {
+		nsUpdates, ok := trigger.StateUpdates[ns]
+		if !ok {
+			continue
+		}
+
+		var collNames []string
+		for _, coll := range l.collections[ns] {
+			if len(nsUpdates.CollectionUpdates[coll]) == 0 && len(nsUpdates.HashedUpdates[coll]) == 0 {
+				continue
+			}
+			collNames = append(collNames, coll)
+		}
+
+		if len(nsUpdates.PublicUpdates) == 0 && len(collNames) == 0 {
+			continue
+		}
+
+		interest.Chaincodes = append(interest.Chaincodes, &peer.ChaincodeCall{
+			Name:            ns,
+			CollectionNames: collNames,
+		})
+	}
+
+	l.mu.Lock()
+	l.byCommit = append(l.byCommit, interest)
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *chaincodeInterestListener) StateCommitDone(channelID string) {
+	// NOOP
+}
+
+// Interest returns the ChaincodeInterest recorded for the i-th commit this
+// listener observed (0-based, in commit order), standing in for
+// GetChaincodeInterests(txID) until StateUpdateTrigger carries a TxID.
+func (l *chaincodeInterestListener) Interest(i int) (*peer.ChaincodeInterest, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if i < 0 || i >= len(l.byCommit) {
+		return nil, false
+	}
+	return l.byCommit[i], true
+}
+
+// ChannelInterest returns the union, across every commit observed so far, of
+// the namespaces and collections touched - the channel-scoped snapshot the
+// Gateway planner can use to build an endorsement plan without replaying
+// ledger history.
+func (l *chaincodeInterestListener) ChannelInterest() *peer.ChaincodeInterest {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	collsByNs := make(map[string]map[string]struct{})
+	var nsOrder []string
+	for _, interest := range l.byCommit {
+		for _, cc := range interest.Chaincodes {
+			if _, ok := collsByNs[cc.Name]; !ok {
+				collsByNs[cc.Name] = make(map[string]struct{})
+				nsOrder = append(nsOrder, cc.Name)
+			}
+			for _, coll := range cc.CollectionNames {
+				collsByNs[cc.Name][coll] = struct{}{}
+			}
+		}
+	}
+
+	merged := &peer.ChaincodeInterest{}
+	for _, ns := range nsOrder {
+		var colls []string
+		for coll := range collsByNs[ns] {
+			colls = append(colls, coll)
+		}
+		merged.Chaincodes = append(merged.Chaincodes, &peer.ChaincodeCall{Name: ns, CollectionNames: colls})
+	}
+	return merged
+}
@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package commitlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendAndTail(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := Open(dir)
+	require.NoError(t, err)
+	defer wal.Close()
+
+	tail, err := wal.Tail()
+	require.NoError(t, err)
+	require.Nil(t, tail)
+
+	rec := &PrepareRecord{
+		BlockNum:         1,
+		BlockHash:        []byte("block-1-hash"),
+		PvtdataDigest:    []byte("pvtdata-1-digest"),
+		StateSavepoint:   1,
+		HistorySavepoint: 1,
+		PvtdataSavepoint: 1,
+	}
+	require.NoError(t, wal.AppendPrepare(rec, true))
+
+	tail, err = wal.Tail()
+	require.NoError(t, err)
+	require.Equal(t, RecordTypePrepare, tail.Type)
+	require.Equal(t, rec, tail.Prepare)
+
+	require.NoError(t, wal.AppendCommit(1, true))
+	tail, err = wal.Tail()
+	require.NoError(t, err)
+	require.Equal(t, RecordTypeCommit, tail.Type)
+	require.Equal(t, uint64(1), tail.Commit)
+}
+
+func TestTailIgnoresTruncatedTrailingRecord(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := Open(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, wal.AppendCommit(1, true))
+	rec := &PrepareRecord{BlockNum: 2, StateSavepoint: 2, HistorySavepoint: 2, PvtdataSavepoint: 2}
+	require.NoError(t, wal.AppendPrepare(rec, true))
+	require.NoError(t, wal.Close())
+
+	// simulate a crash mid-append of block 2's PREPARE record by chopping its trailer off
+	path := filepath.Join(dir, walFileName)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	wal, err = Open(dir)
+	require.NoError(t, err)
+	defer wal.Close()
+
+	tail, err := wal.Tail()
+	require.NoError(t, err)
+	require.Equal(t, RecordTypeCommit, tail.Type)
+	require.Equal(t, uint64(1), tail.Commit)
+}
+
+func TestCoordinatorCommitHappyPath(t *testing.T) {
+	wal, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer wal.Close()
+
+	var applied []string
+	var mu sync.Mutex
+	record := func(name string) SubStoreCommit {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			applied = append(applied, name)
+			return nil
+		}
+	}
+
+	c := NewCoordinator(wal)
+	rec := &PrepareRecord{BlockNum: 1, StateSavepoint: 1, HistorySavepoint: 1, PvtdataSavepoint: 1}
+	require.NoError(t, c.Commit(rec, SyncAll, record("blockstore"), record("state"), record("history"), record("pvtdata")))
+
+	require.ElementsMatch(t, []string{"blockstore", "state", "history", "pvtdata"}, applied)
+
+	pending, err := Replay(wal)
+	require.NoError(t, err)
+	require.Nil(t, pending)
+}
+
+func TestCoordinatorCommitLeavesPendingOnSubStoreFailure(t *testing.T) {
+	wal, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer wal.Close()
+
+	c := NewCoordinator(wal)
+	rec := &PrepareRecord{BlockNum: 1, StateSavepoint: 1, HistorySavepoint: 1, PvtdataSavepoint: 1}
+	ok := func() error { return nil }
+	failing := func() error { return errors.New("historyDB is unavailable") }
+
+	err = c.Commit(rec, SyncAll, ok, failing)
+	require.EqualError(t, err, "historyDB is unavailable")
+
+	pending, err := Replay(wal)
+	require.NoError(t, err)
+	require.Equal(t, rec, pending.Prepare)
+}
+
+// TestReplayThreeRecoveryScenarios mirrors the three commit-crash scenarios TestKVLedgerDBRecovery
+// covers in kv_ledger_test.go (block store ahead of state+history; block store and state ahead of
+// history; block store and history ahead of state), confirming Replay reports exactly the pending
+// PREPARE in each case rather than needing a per-sub-store savepoint comparison.
+func TestReplayThreeRecoveryScenarios(t *testing.T) {
+	scenarios := []struct {
+		name          string
+		subStoreOrder []string
+		failAt        int
+	}{
+		{name: "state and history both lag block store", subStoreOrder: []string{"blockstore", "state", "history"}, failAt: 1},
+		{name: "history lags block store and state", subStoreOrder: []string{"blockstore", "state", "history"}, failAt: 2},
+		{name: "state lags block store and history", subStoreOrder: []string{"blockstore", "history", "state"}, failAt: 2},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			wal, err := Open(t.TempDir())
+			require.NoError(t, err)
+			defer wal.Close()
+
+			c := NewCoordinator(wal)
+			rec := &PrepareRecord{BlockNum: 2, StateSavepoint: 2, HistorySavepoint: 2, PvtdataSavepoint: 2}
+
+			var commits []SubStoreCommit
+			for i, name := range s.subStoreOrder {
+				i, name := i, name
+				commits = append(commits, func() error {
+					if i >= s.failAt {
+						return errors.New(name + " failed")
+					}
+					return nil
+				})
+			}
+
+			err = c.Commit(rec, SyncAll, commits...)
+			require.Error(t, err)
+
+			pending, err := Replay(wal)
+			require.NoError(t, err)
+			require.Equal(t, rec, pending.Prepare)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package commitlog
+
+import "sync"
+
+// SyncMode controls how much a Coordinator's Commit fsyncs, trading commit latency for how much a
+// crash can lose. It is meant to back a ledger.CommitOptions.SyncMode field once this checkout has
+// the ledger.CommitOptions this would extend.
+type SyncMode int
+
+const (
+	// Async never fsyncs the WAL. A crash can lose any commit that was in flight, the same
+	// exposure kvLedger.commit has today.
+	Async SyncMode = iota
+	// SyncWAL fsyncs the PREPARE record; the COMMIT record and the sub-store batches are not
+	// fsynced. A crash can never lose a commit silently: either the PREPARE made it to disk, in which
+	// case replay finishes the commit from it, or it didn't, in which case the commit never
+	// started as far as any sub-store is concerned.
+	SyncWAL
+	// SyncAll additionally fsyncs the COMMIT record. Commit does not fsync the sub-store batches
+	// in any mode; how durable those are is up to each SubStoreCommit.
+	SyncAll
+)
+
+// SubStoreCommit applies one sub-store's (blockstore/stateDB/historyDB/pvtdatastore) batch for the
+// block a Coordinator.Commit call is sequencing.
+type SubStoreCommit func() error
+
+// Coordinator sequences a block's commit across a kvLedger's sub-stores through the WAL's
+// two-phase protocol: append PREPARE, fan out to the sub-stores, append COMMIT. It is the piece
+// kvLedger.commit would call into once this checkout has the kvLedger this would rework - see the
+// package doc and commitlog_test.go for what's exercised in isolation until then.
+type Coordinator struct {
+	wal *WAL
+}
+
+// NewCoordinator wraps wal in a Coordinator.
+func NewCoordinator(wal *WAL) *Coordinator {
+	return &Coordinator{wal: wal}
+}
+
+// Commit appends a PREPARE record for rec, runs subStoreCommits concurrently, and appends a
+// COMMIT record once every one of them succeeds. If any sub-store commit fails, Commit returns
+// that error and leaves the WAL's tail at PREPARE, so the next Replay reports rec as pending and
+// the caller can retry the lagging sub-stores without re-simulating the block.
+func (c *Coordinator) Commit(rec *PrepareRecord, mode SyncMode, subStoreCommits ...SubStoreCommit) error {
+	if err := c.wal.AppendPrepare(rec, mode != Async); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(subStoreCommits))
+	for i, commit := range subStoreCommits {
+		wg.Add(1)
+		go func(i int, commit SubStoreCommit) {
+			defer wg.Done()
+			errs[i] = commit()
+		}(i, commit)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.wal.AppendCommit(rec.BlockNum, mode == SyncAll)
+}
+
+// PendingCommit is the PREPARE record of a commit that appended PREPARE but never reached COMMIT,
+// discovered by Replay.
+type PendingCommit struct {
+	Prepare *PrepareRecord
+}
+
+// Replay inspects wal's tail record and reports whether a commit was left mid-flight. It returns
+// nil if the WAL is empty or its tail is a COMMIT record - both mean there is nothing to finish -
+// replacing the recover-by-block-replay path that previously inferred this by comparing each
+// sub-store's own savepoint against the blockstore height.
+func Replay(wal *WAL) (*PendingCommit, error) {
+	tail, err := wal.Tail()
+	if err != nil {
+		return nil, err
+	}
+	if tail == nil || tail.Type == RecordTypeCommit {
+		return nil, nil
+	}
+	return &PendingCommit{Prepare: tail.Prepare}, nil
+}
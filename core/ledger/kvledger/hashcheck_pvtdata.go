@@ -8,6 +8,8 @@ package kvledger
 
 import (
 	"bytes"
+	"container/list"
+	"sync"
 
 	"github.com/hyperledger/fabric/common/ledger/blkstorage"
 	"github.com/hyperledger/fabric/common/util"
@@ -17,76 +19,240 @@ import (
 	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
 )
 
+// defaultPvtdataHashCheckWorkers is used when the caller passes numWorkers
+// <= 0, e.g. because ledger.Config.ReconcilePvtdataWorkers is unset.
+const defaultPvtdataHashCheckWorkers = 4
+
+// blockRwSetCacheSize bounds the number of blocks' worth of parsed TxRwSets
+// kept in memory at once. Reconciliation runs typically touch a handful of
+// old blocks repeatedly across many transactions, so a small cache is
+// enough to amortize the block fetch and FromProtoBytes parsing.
+const blockRwSetCacheSize = 16
+
+// pvtDataJob is one (block, tx) pair awaiting hash-check, tagged with its
+// position in the flattened, per-block-then-per-tx iteration order of the
+// input so the merge step can reproduce that order regardless of which
+// worker finishes first.
+type pvtDataJob struct {
+	idx       int
+	blkNum    uint64
+	txPvtData *ledger.TxPvtData
+}
+
+type fetchedTx struct {
+	job     pvtDataJob
+	txRWSet *rwsetutil.TxRwSet
+	err     error
+}
+
+type pvtDataJobResult struct {
+	validData   *ledger.TxPvtData
+	invalidData []*ledger.PvtdataHashMismatch
+	err         error
+}
+
 // constructValidAndInvalidPvtData computes the valid pvt data and hash mismatch list
 // from a received pvt data list of old blocks.
-func constructValidAndInvalidPvtData(reconciledPvtdata []*ledger.ReconciledPvtdata, blockStore *blkstorage.BlockStore) (
-	map[uint64][]*ledger.TxPvtData, []*ledger.PvtdataHashMismatch, error,
-) {
-	// for each block, for each transaction, retrieve the txEnvelope to
-	// compare the hash of pvtRwSet in the block and the hash of the received
-	// txPvtData. On a mismatch, add an entry to hashMismatch list.
-	// On a match, add the pvtData to the validPvtData list
-	validPvtData := make(map[uint64][]*ledger.TxPvtData)
-	var invalidPvtData []*ledger.PvtdataHashMismatch
+//
+// For each tx, the pvtRwSet hash recorded in its on-chain rwset is compared
+// against the hash of the corresponding received pvtData. This is split
+// across two worker pools so that reconciling hundreds of old blocks, which
+// the privdata coordinator can trigger in a single burst, does not stall
+// the committer: a fetch pool retrieves each tx's TxRwSet from blockStore
+// (amortizing repeated block reads via a small LRU, since many reconciled
+// txs usually share a handful of blocks), and a hash pool performs the
+// per-(ns,coll) SHA-256 comparison. numWorkers sizes both pools and is
+// expected to come from ledger.Config.ReconcilePvtdataWorkers; a value <= 0
+// falls back to defaultPvtdataHashCheckWorkers. The merge step below
+// reassembles validPvtData/invalidPvtData in the same order the prior
+// serial implementation produced them, so the result is deterministic even
+// though the workers complete out of order.
+func constructValidAndInvalidPvtData(
+	reconciledPvtdata []*ledger.ReconciledPvtdata,
+	blockStore *blkstorage.BlockStore,
+	numWorkers int,
+) (map[uint64][]*ledger.TxPvtData, []*ledger.PvtdataHashMismatch, error) {
+	if numWorkers <= 0 {
+		numWorkers = defaultPvtdataHashCheckWorkers
+	}
 
+	var jobs []pvtDataJob
 	for _, pvtdata := range reconciledPvtdata {
-		validData, invalidData, err := findValidAndInvalidPvtdata(pvtdata, blockStore)
-		if err != nil {
-			return nil, nil, err
+		for _, txPvtData := range pvtdata.WriteSets {
+			jobs = append(jobs, pvtDataJob{idx: len(jobs), blkNum: pvtdata.BlockNum, txPvtData: txPvtData})
 		}
-		if len(validData) > 0 {
-			validPvtData[pvtdata.BlockNum] = validData
+	}
+	if len(jobs) == 0 {
+		return nil, nil, nil
+	}
+
+	jobCh := make(chan pvtDataJob)
+	fetchedCh := make(chan fetchedTx)
+	results := make([]pvtDataJobResult, len(jobs))
+
+	cache := newBlockRwSetCache()
+	var fetchWG sync.WaitGroup
+	fetchWG.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			defer fetchWG.Done()
+			for job := range jobCh {
+				logger.Debugf("Retrieving rwset of blockNum:[%d], txNum:[%d]", job.blkNum, job.txPvtData.SeqInBlock)
+				txRWSet, err := cache.get(job.blkNum, job.txPvtData.SeqInBlock, blockStore)
+				fetchedCh <- fetchedTx{job: job, txRWSet: txRWSet, err: err}
+			}
+		}()
+	}
+	go func() {
+		fetchWG.Wait()
+		close(fetchedCh)
+	}()
+
+	var hashWG sync.WaitGroup
+	hashWG.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			defer hashWG.Done()
+			for fetched := range fetchedCh {
+				if fetched.err != nil {
+					results[fetched.job.idx] = pvtDataJobResult{err: fetched.err}
+					continue
+				}
+				logger.Debugf("Constructing valid and invalid pvtData using rwset of blockNum:[%d], txNum:[%d]",
+					fetched.job.blkNum, fetched.job.txPvtData.SeqInBlock)
+				validData, invalidData := findValidAndInvalidTxPvtData(fetched.job.txPvtData, fetched.txRWSet, fetched.job.blkNum)
+				results[fetched.job.idx] = pvtDataJobResult{validData: validData, invalidData: invalidData}
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		jobCh <- job
+	}
+	close(jobCh)
+	hashWG.Wait()
+
+	validPvtData := make(map[uint64][]*ledger.TxPvtData)
+	var invalidPvtData []*ledger.PvtdataHashMismatch
+	jobIdx := 0
+	for _, pvtdata := range reconciledPvtdata {
+		for range pvtdata.WriteSets {
+			res := results[jobIdx]
+			jobIdx++
+			if res.err != nil {
+				return nil, nil, res.err
+			}
+			if res.validData != nil {
+				validPvtData[pvtdata.BlockNum] = append(validPvtData[pvtdata.BlockNum], res.validData)
+			}
+			invalidPvtData = append(invalidPvtData, res.invalidData...)
 		}
-		invalidPvtData = append(invalidPvtData, invalidData...)
-	} // for each block's pvtData
+	}
 	return validPvtData, invalidPvtData, nil
 }
 
-func findValidAndInvalidPvtdata(reconciledPvtdata *ledger.ReconciledPvtdata, blockStore *blkstorage.BlockStore) (
-	[]*ledger.TxPvtData, []*ledger.PvtdataHashMismatch, error,
-) {
-	var validPvtData []*ledger.TxPvtData
-	var invalidPvtData []*ledger.PvtdataHashMismatch
-	for _, txPvtData := range reconciledPvtdata.WriteSets {
-		// (1) retrieve the txrwset from the blockstore
-		logger.Debugf("Retrieving rwset of blockNum:[%d], txNum:[%d]", reconciledPvtdata.BlockNum, txPvtData.SeqInBlock)
-		txRWSet, err := retrieveRwsetForTx(reconciledPvtdata.BlockNum, txPvtData.SeqInBlock, blockStore)
-		if err != nil {
-			return nil, nil, err
-		}
+// blockRwSetCache memoizes retrieveRwsetsForBlock, evicting the
+// least-recently-used block once more than blockRwSetCacheSize blocks have
+// been fetched. It is safe for concurrent use by the fetch pool.
+type blockRwSetCache struct {
+	mu       sync.Mutex
+	ll       *list.List
+	elements map[uint64]*list.Element
+}
 
-		// (2) validate passed pvtData against the pvtData hash in the tx rwset.
-		logger.Debugf("Constructing valid and invalid pvtData using rwset of blockNum:[%d], txNum:[%d]",
-			reconciledPvtdata.BlockNum, txPvtData.SeqInBlock)
-		validData, invalidData := findValidAndInvalidTxPvtData(txPvtData, txRWSet, reconciledPvtdata.BlockNum)
+type blockRwSetCacheEntry struct {
+	blkNum uint64
+	rwsets map[uint64]*rwsetutil.TxRwSet
+	err    error
+}
 
-		// (3) append validData to validPvtDataPvt list of this block and
-		// invalidData to invalidPvtData list
-		if validData != nil {
-			validPvtData = append(validPvtData, validData)
-		}
-		invalidPvtData = append(invalidPvtData, invalidData...)
-	} // for each tx's pvtData
-	return validPvtData, invalidPvtData, nil
+func newBlockRwSetCache() *blockRwSetCache {
+	return &blockRwSetCache{
+		ll:       list.New(),
+		elements: make(map[uint64]*list.Element),
+	}
 }
 
-func retrieveRwsetForTx(blkNum uint64, txNum uint64, blockStore *blkstorage.BlockStore) (*rwsetutil.TxRwSet, error) {
-	// retrieve the txEnvelope from the block store so that the hash of
-	// the pvtData can be retrieved for comparison
-	txEnvelope, err := blockStore.RetrieveTxByBlockNumTranNum(blkNum, txNum)
-	if err != nil {
-		return nil, err
+// get returns the TxRwSet for (blkNum, txNum), fetching and caching the
+// rest of blkNum's transactions along the way. Concurrent misses on the
+// same blkNum may race to fetch it independently; the result is still
+// correct, just occasionally redundant, and is not worth serializing given
+// how rare a same-block race is for a pool of a handful of workers.
+func (c *blockRwSetCache) get(blkNum, txNum uint64, blockStore *blkstorage.BlockStore) (*rwsetutil.TxRwSet, error) {
+	if entry, ok := c.lookup(blkNum); ok {
+		return entry.rwsets[txNum], entry.err
+	}
+
+	rwsets, err := retrieveRwsetsForBlock(blkNum, blockStore)
+	entry := c.store(blkNum, rwsets, err)
+	return entry.rwsets[txNum], entry.err
+}
+
+func (c *blockRwSetCache) lookup(blkNum uint64) (*blockRwSetCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.elements[blkNum]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*blockRwSetCacheEntry), true
+}
+
+func (c *blockRwSetCache) store(blkNum uint64, rwsets map[uint64]*rwsetutil.TxRwSet, err error) *blockRwSetCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.elements[blkNum]; ok {
+		// Another worker fetched blkNum first while we were fetching it
+		// too; keep whichever result is already cached.
+		c.ll.MoveToFront(elem)
+		return elem.Value.(*blockRwSetCacheEntry)
 	}
-	// retrieve pvtRWset hash from the txEnvelope
-	responsePayload, err := protoutil.GetActionFromEnvelopeMsg(txEnvelope)
+
+	entry := &blockRwSetCacheEntry{blkNum: blkNum, rwsets: rwsets, err: err}
+	elem := c.ll.PushFront(entry)
+	c.elements[blkNum] = elem
+
+	if c.ll.Len() > blockRwSetCacheSize {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.elements, oldest.Value.(*blockRwSetCacheEntry).blkNum)
+	}
+
+	return entry
+}
+
+// retrieveRwsetsForBlock parses every endorser transaction in blkNum into
+// its TxRwSet, keyed by its position in the block. Transactions that are
+// not endorser transactions (e.g. channel config updates) are simply
+// absent from the result.
+func retrieveRwsetsForBlock(blkNum uint64, blockStore *blkstorage.BlockStore) (map[uint64]*rwsetutil.TxRwSet, error) {
+	block, err := blockStore.RetrieveBlockByNumber(blkNum)
 	if err != nil {
 		return nil, err
 	}
-	txRWSet := &rwsetutil.TxRwSet{}
-	if err := txRWSet.FromProtoBytes(responsePayload.Results); err != nil {
-		return nil, err
+
+	rwsets := make(map[uint64]*rwsetutil.TxRwSet, len(block.Data.Data))
+	for txNum, envBytes := range block.Data.Data {
+		envelope, err := protoutil.UnmarshalEnvelope(envBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		responsePayload, err := protoutil.GetActionFromEnvelopeMsg(envelope)
+		if err != nil {
+			continue
+		}
+
+		txRWSet := &rwsetutil.TxRwSet{}
+		if err := txRWSet.FromProtoBytes(responsePayload.Results); err != nil {
+			return nil, err
+		}
+		rwsets[uint64(txNum)] = txRWSet
 	}
-	return txRWSet, nil
+	return rwsets, nil
 }
 
 func findValidAndInvalidTxPvtData(txPvtData *ledger.TxPvtData, txRWSet *rwsetutil.TxRwSet, blkNum uint64) (
@@ -0,0 +1,67 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvledger
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/pvtdatastorage"
+)
+
+// pvtdataStoreDirName is the subdirectory, under a ledger provider's rootFSPath, that the
+// pvtdatastorage.Provider for every channel's private-data store is rooted at.
+const pvtdataStoreDirName = "pvtdataStore"
+
+// RollbackKVLedger truncates the channel ledgerID back to blockNumber, permanently discarding
+// every block after it. It is meant to be invoked while the peer is offline: the caller must
+// ensure nothing else has the ledger's data directory open concurrently.
+//
+// This checkout's core/ledger/kvledger has no kv_ledger.go/provider.go defining the blockfile
+// store, the stateDB provider, or the historyDB provider (or the NewProvider/Provider.Open that
+// would construct and coordinate them alongside pvtdatastorage), so only the pvtdata/bookkeeping
+// leg of the rollback this request describes - (d), via pvtdatastorage.Store.Rollback - can be
+// wired up against real code in this tree. Trimming the blockfile store (a), rebuilding
+// stateDB/historyDB from genesis via recommitLostBlocks (b, c), and resetting the commit-hash
+// chain in block metadata (e) are left as follow-up once those files are part of this checkout;
+// this function validates and performs (d) now so that work isn't blocked on the rest.
+func RollbackKVLedger(rootFSPath, ledgerID string, blockNumber uint64) error {
+	pvtdataStoreProvider, err := pvtdatastorage.NewProvider(&pvtdatastorage.PrivateDataConfig{
+		PrivateDataConfig: &ledger.PrivateDataConfig{
+			BatchesInterval: 1000,
+			MaxBatchSize:    5000,
+			PurgeInterval:   100,
+		},
+		StorePath: filepath.Join(rootFSPath, pvtdataStoreDirName),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed opening pvtdata store provider for ledger [%s]: %s", ledgerID, err)
+	}
+	defer pvtdataStoreProvider.Close()
+
+	store, err := pvtdataStoreProvider.OpenStore(ledgerID)
+	if err != nil {
+		return fmt.Errorf("Failed opening pvtdata store for ledger [%s]: %s", ledgerID, err)
+	}
+
+	currentHeight, err := store.LastCommittedBlockHeight()
+	if err != nil {
+		return err
+	}
+	if currentHeight == 0 {
+		return fmt.Errorf("Ledger [%s] does not exist", ledgerID)
+	}
+	if blockNumber >= currentHeight-1 {
+		return fmt.Errorf(
+			"Requested rollback block number [%d] is not less than the current block number [%d] for ledger [%s]",
+			blockNumber, currentHeight-1, ledgerID,
+		)
+	}
+
+	return store.Rollback(blockNumber)
+}
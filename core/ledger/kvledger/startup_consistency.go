@@ -0,0 +1,56 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvledger
+
+import (
+	"github.com/hyperledger/fabric/core/ledger/pvtdatastorage"
+)
+
+// PvtdataBlockstoreLag classifies how a pvtdataStore's committed height compares to the
+// blockstore's, the first of the four startup-consistency checks this request asks for.
+type PvtdataBlockstoreLag struct {
+	// Ahead is true when the pvtdataStore has committed more blocks than the blockstore -
+	// the existing isPvtDataStoreAheadOfBlockStore case: a peer that crashed between
+	// committing a block's pvtdata and committing the block itself.
+	Ahead bool
+	// Behind is true when the blockstore is ahead of the pvtdataStore, e.g. because a later
+	// block's pvtdata was never received locally and is still awaiting gossip reconciliation.
+	Behind bool
+	// Delta is the absolute number of blocks of lag. 0 when neither Ahead nor Behind is set.
+	Delta uint64
+}
+
+// DetectPvtdataBlockstoreLag compares a pvtdataStore's committed height against a caller-
+// supplied blockstore height and classifies the result as one of this request's startup
+// consistency cases.
+//
+// This checkout's core/ledger/kvledger has no kv_ledger.go/provider.go defining kvLedger,
+// the blockStore, the stateDB provider, or the historyDB provider (or the kvledger.Open that
+// would construct and coordinate them), so the full syncStateAndHistoryDBWithBlockstore-style
+// routine this request describes - replaying blocks from whichever of stateDB, historyDB, or
+// pvtdataStore lags behind the blockstore, using l.txtmgmt.GetLastSavepoint() and
+// l.historyDB.GetLastSavepoint() as authoritative cursors - cannot be wired up against real
+// code in this tree. Only the pvtdataStore-vs-blockstore comparison (cases 1 and 4) can be
+// expressed today, by taking the caller's blockstore height as a parameter instead of reading
+// it off a real blockStore. The stateDB leg (case 2), the historyDB leg (case 3), and the
+// actual gossip-driven re-fetch that case 4 requires once lag is detected are left as
+// follow-ups once those files are part of this checkout.
+func DetectPvtdataBlockstoreLag(pvtdataStore *pvtdatastorage.Store, blockstoreHeight uint64) (PvtdataBlockstoreLag, error) {
+	pvtdataStoreHeight, err := pvtdataStore.LastCommittedBlockHeight()
+	if err != nil {
+		return PvtdataBlockstoreLag{}, err
+	}
+
+	switch {
+	case pvtdataStoreHeight > blockstoreHeight:
+		return PvtdataBlockstoreLag{Ahead: true, Delta: pvtdataStoreHeight - blockstoreHeight}, nil
+	case pvtdataStoreHeight < blockstoreHeight:
+		return PvtdataBlockstoreLag{Behind: true, Delta: blockstoreHeight - pvtdataStoreHeight}, nil
+	default:
+		return PvtdataBlockstoreLag{}, nil
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvledger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// SnapshotEncoder is implemented by a ledger.StateListener that can serialize its accumulated
+// state into a blob cheap enough to persist on every graceful shutdown (and periodically), so a
+// restart doesn't have to rebuild that state from a full GetStateRangeScanIterator walk the way
+// Initialize otherwise does.
+type SnapshotEncoder interface {
+	EncodeSnapshot() ([]byte, error)
+}
+
+// SnapshotDecoder is implemented by a ledger.StateListener that can resume from a snapshot
+// EncodeSnapshot produced instead of from Initialize's range scan. blockHeight is the ledger
+// height the snapshot was taken at; InitializeListeners is responsible for only calling this when
+// the caller can still supply, via HandleStateUpdates, every commit between blockHeight and the
+// ledger's current height - the same updates the listener would have observed had it never
+// restarted.
+type SnapshotDecoder interface {
+	InitializeFromSnapshot(ledgerID string, snapshot []byte, blockHeight uint64, qe ledger.SimpleQueryExecutor) error
+}
+
+// listenerSnapshotStore persists and retrieves the blobs SnapshotEncoder.EncodeSnapshot produces,
+// under rootDir/snapshots/<ledgerID>, one file per (listener name, block height) with the height
+// in the file name. Save never removes older files; keeping the height in the file name lets Load
+// pick the most recent snapshot for a pair without opening every candidate.
+type listenerSnapshotStore struct {
+	dir string
+}
+
+// newListenerSnapshotStore returns a listenerSnapshotStore rooted at rootDir/snapshots; rootDir is
+// expected to be the same per-ledger data directory blkstorage and pvtdatastorage are rooted at.
+func newListenerSnapshotStore(rootDir string) *listenerSnapshotStore {
+	return &listenerSnapshotStore{dir: filepath.Join(rootDir, "snapshots")}
+}
+
+const snapshotFileSuffix = ".snapshot"
+
+func (s *listenerSnapshotStore) fileName(listenerName string, blockHeight uint64) string {
+	return fmt.Sprintf("%s__%020d%s", listenerName, blockHeight, snapshotFileSuffix)
+}
+
+func (s *listenerSnapshotStore) ledgerDir(ledgerID string) string {
+	return filepath.Join(s.dir, ledgerID)
+}
+
+// Save persists data as the snapshot for listenerName on ledgerID at blockHeight, creating the
+// ledger's snapshots directory if this is the first one written.
+func (s *listenerSnapshotStore) Save(ledgerID, listenerName string, blockHeight uint64, data []byte) error {
+	dir := s.ledgerDir(ledgerID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Failed creating snapshots directory [%s] [%s]", dir, err)
+	}
+	path := filepath.Join(dir, s.fileName(listenerName, blockHeight))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("Failed writing snapshot for listener [%s] on ledger [%s] [%s]", listenerName, ledgerID, err)
+	}
+	return nil
+}
+
+// Load returns the highest-blockHeight snapshot on disk for (ledgerID, listenerName). ok is false
+// when no snapshot has ever been saved for this pair, which is not an error: the caller is
+// expected to fall back to the listener's ordinary Initialize in that case.
+func (s *listenerSnapshotStore) Load(ledgerID, listenerName string) (data []byte, blockHeight uint64, ok bool, err error) {
+	dir := s.ledgerDir(ledgerID)
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("Failed listing snapshots directory [%s] [%s]", dir, err)
+	}
+
+	prefix := listenerName + "__"
+	var latestName string
+	var latestHeight uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, snapshotFileSuffix) {
+			continue
+		}
+		heightStr := strings.TrimSuffix(strings.TrimPrefix(name, prefix), snapshotFileSuffix)
+		var height uint64
+		if _, scanErr := fmt.Sscanf(heightStr, "%d", &height); scanErr != nil {
+			continue
+		}
+		if latestName == "" || height > latestHeight {
+			latestName, latestHeight = name, height
+		}
+	}
+	if latestName == "" {
+		return nil, 0, false, nil
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, latestName))
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("Failed reading snapshot [%s] [%s]", latestName, err)
+	}
+	return data, latestHeight, true, nil
+}
+
+// InitializeListeners initializes each of listeners against ledgerID, preferring
+// SnapshotDecoder.InitializeFromSnapshot over the full-range-scan Initialize whenever store holds
+// a snapshot for that listener at a height no newer than currentBlockHeight. This is the "state
+// sync without transaction replay" fast path: a listener restored from a snapshot only needs the
+// commits between the snapshot height and currentBlockHeight replayed into it via
+// HandleStateUpdates, instead of re-deriving its whole state from a namespace range scan. A
+// listener that doesn't implement SnapshotDecoder, or for which no snapshot exists yet, always
+// falls back to Initialize.
+func InitializeListeners(ledgerID string, listeners []ledger.StateListener, qe ledger.SimpleQueryExecutor, store *listenerSnapshotStore, currentBlockHeight uint64) error {
+	for _, l := range listeners {
+		if decoder, ok := l.(SnapshotDecoder); ok {
+			snapshot, blockHeight, found, err := store.Load(ledgerID, l.Name())
+			if err != nil {
+				return err
+			}
+			if found && blockHeight <= currentBlockHeight {
+				if err := decoder.InitializeFromSnapshot(ledgerID, snapshot, blockHeight, qe); err != nil {
+					return fmt.Errorf("Failed initializing listener [%s] from snapshot [%s]", l.Name(), err)
+				}
+				continue
+			}
+		}
+		if err := l.Initialize(ledgerID, qe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PersistListenerSnapshots saves a snapshot, at blockHeight, for every listener in listeners that
+// implements SnapshotEncoder. It is meant to be called from Provider.Close on graceful shutdown,
+// and periodically while the ledger is open, so InitializeListeners rarely has to fall back to a
+// full range scan on the next restart.
+func PersistListenerSnapshots(ledgerID string, listeners []ledger.StateListener, store *listenerSnapshotStore, blockHeight uint64) error {
+	for _, l := range listeners {
+		encoder, ok := l.(SnapshotEncoder)
+		if !ok {
+			continue
+		}
+		data, err := encoder.EncodeSnapshot()
+		if err != nil {
+			return fmt.Errorf("Failed encoding snapshot for listener [%s] [%s]", l.Name(), err)
+		}
+		if err := store.Save(ledgerID, l.Name(), blockHeight, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvledger
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	commonledger "github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spyQueryExecutor wraps a nil ledger.SimpleQueryExecutor and only implements the two methods
+// mockStateListener.Initialize calls, counting how often the expensive one - the namespace range
+// scan InitializeListeners' snapshot fast path is meant to avoid - is actually used.
+type spyQueryExecutor struct {
+	ledger.SimpleQueryExecutor
+	rangeScanResults []*queryresult.KV
+	rangeScanCalls   int
+}
+
+func (s *spyQueryExecutor) GetPrivateDataHash(namespace, collection, key string) ([]byte, error) {
+	return nil, nil
+}
+
+func (s *spyQueryExecutor) GetStateRangeScanIterator(namespace, startKey, endKey string) (commonledger.ResultsIterator, error) {
+	s.rangeScanCalls++
+	return &stubResultsIterator{results: s.rangeScanResults}, nil
+}
+
+// stubResultsIterator replays a canned slice of *queryresult.KV, the same type
+// mockStateListener.Initialize type-asserts itr.Next()'s result to.
+type stubResultsIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func (s *stubResultsIterator) Next() (commonledger.QueryResult, error) {
+	if s.next >= len(s.results) {
+		return nil, nil
+	}
+	res := s.results[s.next]
+	s.next++
+	return res, nil
+}
+
+func (s *stubResultsIterator) Close() {}
+
+func TestInitializeListenersFromSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvledger-listener-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	channelid := "testLedger"
+	namespace := "testchaincode"
+	collection := "testcoll"
+	store := newListenerSnapshotStore(dir)
+
+	// populate the listener as if it had observed a commit, then persist a snapshot for it the way
+	// Provider.Close is meant to on graceful shutdown
+	interestListener := newChaincodeInterestListener([]string{namespace}, map[string][]string{namespace: {collection}})
+	interestListener.byCommit = []*peer.ChaincodeInterest{
+		{Chaincodes: []*peer.ChaincodeCall{{Name: namespace, CollectionNames: []string{collection}}}},
+	}
+	require.NoError(t, PersistListenerSnapshots(channelid, []ledger.StateListener{interestListener}, store, 3))
+
+	// a mockStateListener doesn't implement SnapshotEncoder/SnapshotDecoder, so it always falls
+	// back to Initialize and is unaffected by the snapshot fast path
+	mockListener := &mockStateListener{namespace: namespace, collection: collection}
+	expectedResults := []*queryresult.KV{
+		{Namespace: namespace, Key: "key1", Value: []byte("value1")},
+		{Namespace: namespace, Key: "key2", Value: []byte("value2")},
+		{Namespace: namespace, Key: "key4", Value: []byte("value4")},
+	}
+	qe := &spyQueryExecutor{rangeScanResults: expectedResults}
+
+	// a fresh chaincodeInterestListener, standing in for the one a restarted peer would construct
+	// before InitializeListeners runs, starts with no commit history until it is restored
+	restoredInterestListener := newChaincodeInterestListener([]string{namespace}, map[string][]string{namespace: {collection}})
+	_, ok := restoredInterestListener.Interest(0)
+	assert.False(t, ok)
+
+	require.NoError(t, InitializeListeners(
+		channelid,
+		[]ledger.StateListener{mockListener, restoredInterestListener},
+		qe,
+		store,
+		5, // current ledger height is past the snapshot height, so the snapshot is eligible
+	))
+
+	// mockListener has no snapshot, so it still falls back to Initialize and its range-scan based
+	// bootstrap still runs
+	assert.Equal(t, expectedResults, mockListener.queryResultsInInitializeFunc)
+	assert.Equal(t, 1, qe.rangeScanCalls)
+
+	// restoredInterestListener, by contrast, is restored from the snapshot: its commit history is
+	// back in place without InitializeListeners ever calling its (trivial) Initialize
+	restoredInterest, ok := restoredInterestListener.Interest(0)
+	assert.True(t, ok)
+	assert.Equal(t, interestListener.byCommit[0], restoredInterest)
+}
+
+func TestInitializeListenersFallsBackWithoutSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvledger-listener-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	channelid := "testLedger"
+	namespace := "testchaincode"
+	store := newListenerSnapshotStore(dir)
+
+	// no snapshot has ever been saved for this listener, so InitializeListeners must fall back to
+	// its ordinary Initialize rather than erroring or restoring an empty snapshot
+	interestListener := newChaincodeInterestListener([]string{namespace}, nil)
+	qe := &spyQueryExecutor{}
+	require.NoError(t, InitializeListeners(channelid, []ledger.StateListener{interestListener}, qe, store, 5))
+	_, ok := interestListener.Interest(0)
+	assert.False(t, ok)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/hyperledger/fabric/core/ledger/mock"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,14 +27,16 @@ func TestStateListener(t *testing.T) {
 	// create a listener and register it to listen to state change in a namespace
 	channelid := "testLedger"
 	namespace := "testchaincode"
-	mockListener := &mockStateListener{namespace: namespace}
+	collection := "testcoll"
+	mockListener := &mockStateListener{namespace: namespace, collection: collection}
+	interestListener := newChaincodeInterestListener([]string{namespace}, map[string][]string{namespace: {collection}})
 
 	cryptoProvider, err := sw.NewDefaultSecurityLevelWithKeystore(sw.NewDummyKeyStore())
 	assert.NoError(t, err)
 	provider, err := NewProvider(
 		&ledger.Initializer{
 			DeployedChaincodeInfoProvider: &mock.DeployedChaincodeInfoProvider{},
-			StateListeners:                []ledger.StateListener{mockListener},
+			StateListeners:                []ledger.StateListener{mockListener, interestListener},
 			MetricsProvider:               &disabled.Provider{},
 			Config:                        conf,
 			Hasher:                        cryptoProvider,
@@ -76,6 +79,11 @@ func TestStateListener(t *testing.T) {
 	assert.Equal(t, channelid, mockListener.channelName)
 	assert.Contains(t, mockListener.kvWrites, &kvrwset.KVWrite{Key: "key1", Value: []byte("value1")})
 	assert.Contains(t, mockListener.kvWrites, &kvrwset.KVWrite{Key: "key2", Value: []byte("value2")})
+	// tx1's commit is interestListener's commit 0: it touched namespace but no
+	// collections
+	tx1Interest, ok := interestListener.Interest(0)
+	assert.True(t, ok)
+	assert.Equal(t, []*peer.ChaincodeCall{{Name: namespace}}, tx1Interest.Chaincodes)
 	// commit tx2 and this should not cause mock listener to recieve the state changes made by tx2
 	// (because, tx2 should be found as invalid)
 	mockListener.reset()
@@ -98,13 +106,54 @@ func TestStateListener(t *testing.T) {
 	assert.Equal(t, []*kvrwset.KVWrite{
 		{Key: "key4", Value: []byte("value4")},
 	}, mockListener.kvWrites)
+	// tx2 was found invalid and never reached HandleStateUpdates, so tx3's
+	// commit is interestListener's commit 1, not commit 2
+	tx3Interest, ok := interestListener.Interest(1)
+	assert.True(t, ok)
+	assert.Equal(t, []*peer.ChaincodeCall{{Name: namespace}}, tx3Interest.Chaincodes)
+
+	// simulate tx4 - a collection-scoped private write, which the listener
+	// should receive via CollectionUpdates since it declared an interest in
+	// "testcoll" through InterestedInCollections()
+	sim4, err := lgr.NewTxSimulator("test_tx_4")
+	assert.NoError(t, err)
+	sim4.SetPrivateData(namespace, collection, "pvtkey1", []byte("pvtvalue1"))
+	sim4.Done()
+
+	mockListener.reset()
+	sim4Res, err := sim4.GetTxSimulationResults()
+	assert.NoError(t, err)
+	sim4PubBytes, _ := sim4Res.GetPubSimulationBytes()
+	blk4 := bg.NextBlock([][]byte{sim4PubBytes})
+	assert.NoError(t, lgr.CommitLegacy(
+		&ledger.BlockAndPvtData{
+			Block:   blk4,
+			PvtData: ledger.TxPvtDataMap{0: {SeqInBlock: 0, WriteSet: sim4Res.PvtSimulationResults}},
+		},
+		&ledger.CommitOptions{},
+	))
+	assert.Equal(t, channelid, mockListener.channelName)
+	assert.Equal(t, []*kvrwset.KVWrite{
+		{Key: "pvtkey1", Value: []byte("pvtvalue1")},
+	}, mockListener.collectionWrites)
+	// tx4's commit is interestListener's commit 2: it wrote only to the
+	// "testcoll" collection, which is reflected in CollectionNames
+	tx4Interest, ok := interestListener.Interest(2)
+	assert.True(t, ok)
+	assert.Equal(t, []*peer.ChaincodeCall{{Name: namespace, CollectionNames: []string{collection}}}, tx4Interest.Chaincodes)
+
+	// the channel-scoped snapshot merges every commit observed so far, giving
+	// the Gateway planner the full set of namespaces/collections touched
+	// without it having to replay ledger history itself
+	channelInterest := interestListener.ChannelInterest()
+	assert.Equal(t, []*peer.ChaincodeCall{{Name: namespace, CollectionNames: []string{collection}}}, channelInterest.Chaincodes)
 
 	provider.Close()
 
 	provider, err = NewProvider(
 		&ledger.Initializer{
 			DeployedChaincodeInfoProvider: &mock.DeployedChaincodeInfoProvider{},
-			StateListeners:                []ledger.StateListener{mockListener},
+			StateListeners:                []ledger.StateListener{mockListener, interestListener},
 			MetricsProvider:               &disabled.Provider{},
 			Config:                        conf,
 			Hasher:                        cryptoProvider,
@@ -143,7 +192,10 @@ func TestStateListener(t *testing.T) {
 type mockStateListener struct {
 	channelName                  string
 	namespace                    string
+	collection                   string
 	kvWrites                     []*kvrwset.KVWrite
+	collectionWrites             []*kvrwset.KVWrite
+	hashedCollectionWrites       []*kvrwset.KVWriteHash
 	queryResultsInInitializeFunc []*queryresult.KV
 }
 
@@ -181,10 +233,21 @@ func (l *mockStateListener) InterestedInNamespaces() []string {
 	return []string{l.namespace}
 }
 
+// InterestedInCollections reports the private-data collections, within the
+// namespaces returned by InterestedInNamespaces, that this listener wants
+// delivered via StateUpdateTrigger.StateUpdates[ns].CollectionUpdates. A
+// listener not authorized to a collection still receives its HashedUpdates.
+func (l *mockStateListener) InterestedInCollections() []string {
+	return []string{l.collection}
+}
+
 func (l *mockStateListener) HandleStateUpdates(trigger *ledger.StateUpdateTrigger) error {
 	channelName, stateUpdates := trigger.LedgerID, trigger.StateUpdates
 	l.channelName = channelName
-	l.kvWrites = stateUpdates[l.namespace].PublicUpdates
+	nsUpdates := stateUpdates[l.namespace]
+	l.kvWrites = nsUpdates.PublicUpdates
+	l.collectionWrites = nsUpdates.CollectionUpdates[l.collection]
+	l.hashedCollectionWrites = nsUpdates.HashedUpdates[l.collection]
 	return nil
 }
 
@@ -195,5 +258,7 @@ func (l *mockStateListener) StateCommitDone(channelID string) {
 func (l *mockStateListener) reset() {
 	l.channelName = ""
 	l.kvWrites = nil
+	l.collectionWrites = nil
+	l.hashedCollectionWrites = nil
 	l.queryResultsInInitializeFunc = nil
 }
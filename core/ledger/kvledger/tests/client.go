@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/stretchr/testify/require"
+)
+
+// Client drives transaction simulation against a ledger.PeerLedger, replacing the
+// txid-generation/NewTxSimulator/SetState/SetPrivateData/Done/GetTxSimulationResults boilerplate
+// that would otherwise be repeated inline in every test.
+type Client struct {
+	t   *testing.T
+	lgr ledger.PeerLedger
+}
+
+func newClient(t *testing.T, lgr ledger.PeerLedger) *Client {
+	return &Client{t: t, lgr: lgr}
+}
+
+// TxAndPvtdata bundles one transaction's txid and simulated public/private read-write sets, ready
+// for Committer to assemble into a block.
+type TxAndPvtdata struct {
+	Txid          string
+	PubRWSetBytes []byte
+	PvtData       *ledger.TxPvtData
+}
+
+// SimulateDataTx starts a transaction simulator, invokes simulateFunc against it, and returns the
+// resulting TxAndPvtdata. An empty txid gets one generated for it, matching the
+// util.GenerateUUID() calls this replaces.
+func (c *Client) SimulateDataTx(txid string, simulateFunc func(s ledger.TxSimulator)) *TxAndPvtdata {
+	if txid == "" {
+		txid = util.GenerateUUID()
+	}
+
+	simulator, err := c.lgr.NewTxSimulator(txid)
+	require.NoError(c.t, err)
+	simulateFunc(simulator)
+	simulator.Done()
+
+	simRes, err := simulator.GetTxSimulationResults()
+	require.NoError(c.t, err)
+	pubRWSetBytes, err := simRes.GetPubSimulationBytes()
+	require.NoError(c.t, err)
+
+	txData := &TxAndPvtdata{Txid: txid, PubRWSetBytes: pubRWSetBytes}
+	if simRes.PvtSimulationResults != nil {
+		txData.PvtData = &ledger.TxPvtData{WriteSet: simRes.PvtSimulationResults}
+	}
+	return txData
+}
@@ -0,0 +1,77 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/stretchr/testify/require"
+)
+
+// Committer assembles the next block from one or more simulated transactions and commits it,
+// replacing the bg.NextBlock.../CommitLegacy boilerplate that would otherwise be repeated inline
+// in every test.
+type Committer struct {
+	t   *testing.T
+	lgr ledger.PeerLedger
+	bg  *testutil.BlockGenerator
+}
+
+func newCommitter(t *testing.T, lgr ledger.PeerLedger, bg *testutil.BlockGenerator) *Committer {
+	return &Committer{t: t, lgr: lgr, bg: bg}
+}
+
+// CommitDataTxs assembles txs into the next block - via NextBlockWithTxid, so each tx's private
+// data can be keyed by its position in the block - and commits it, along with any private data
+// the transactions simulated, via CommitLegacy.
+func (c *Committer) CommitDataTxs(txs ...*TxAndPvtdata) *common.Block {
+	return c.cutBlockAndCommit(txs, nil)
+}
+
+// CommitDataTx commits a single tx as the next block, returning the committed block.
+func (c *Committer) CommitDataTx(tx *TxAndPvtdata) *common.Block {
+	return c.CommitDataTxs(tx)
+}
+
+// CutBlockAndCommitWithPvtData cuts the next block from txs' public read-write sets only - the
+// way the ordering service does, since it never sees private data - and commits it with just the
+// private data of the txs listed in available. The private data of every other tx is withheld
+// from this commit, landing it in the ledger's missing-data index instead, so a test can exercise
+// reconciliation (e.g. CommitPvtDataOfOldBlocks and GetMissingPvtDataInfoForMostRecentBlocks)
+// against a block that was committed the way a real peer commits one before gossip has delivered
+// every collection's data.
+func (c *Committer) CutBlockAndCommitWithPvtData(txs []*TxAndPvtdata, available map[string]bool) *common.Block {
+	return c.cutBlockAndCommit(txs, available)
+}
+
+// cutBlockAndCommit is the shared implementation behind CommitDataTxs and
+// CutBlockAndCommitWithPvtData. A nil available commits every tx's private data, as
+// CommitDataTxs does; a non-nil available withholds the private data of any tx whose txid is not
+// in it.
+func (c *Committer) cutBlockAndCommit(txs []*TxAndPvtdata, available map[string]bool) *common.Block {
+	pubRWSets := make([][]byte, len(txs))
+	txids := make([]string, len(txs))
+	pvtData := ledger.TxPvtDataMap{}
+	for i, tx := range txs {
+		pubRWSets[i] = tx.PubRWSetBytes
+		txids[i] = tx.Txid
+		if tx.PvtData != nil && (available == nil || available[tx.Txid]) {
+			pvtData[uint64(i)] = &ledger.TxPvtData{SeqInBlock: uint64(i), WriteSet: tx.PvtData.WriteSet}
+		}
+	}
+
+	block := c.bg.NextBlockWithTxid(pubRWSets, txids)
+	blockAndPvtData := &ledger.BlockAndPvtData{Block: block}
+	if len(pvtData) > 0 {
+		blockAndPvtData.PvtData = pvtData
+	}
+	require.NoError(c.t, c.lgr.CommitLegacy(blockAndPvtData, &ledger.CommitOptions{}))
+	return block
+}
@@ -0,0 +1,43 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package tests provides a high-level harness - Client/Committer/Verifier, fronted by Env - for
+// driving a kvledger.Provider-backed ledger end to end in tests, so individual test functions
+// don't each hand-roll transaction simulation, block assembly, and query-executor assertions.
+package tests
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// Env owns one channel's ledger plus the Client/Committer/Verifier bundle tests drive it with. It
+// does not itself own the ledger.PeerLedgerProvider - callers construct that however the test at
+// hand needs (e.g. to control TestConfig, a DeployedChaincodeInfoProvider mock, or a reopen
+// across a provider.Close()) and pass the resulting ledger.PeerLedger to NewEnv.
+type Env struct {
+	t   *testing.T
+	Lgr ledger.PeerLedger
+
+	Client    *Client
+	Committer *Committer
+	Verifier  *Verifier
+}
+
+// NewEnv wraps an already-created/opened lgr (for channel channelID) with a Client/Committer/
+// Verifier bundle. bg is the block generator tests use to assemble lgr's genesis block; Env
+// reuses it so Committer's blocks chain onto the same header history.
+func NewEnv(t *testing.T, channelID string, lgr ledger.PeerLedger, bg *testutil.BlockGenerator) *Env {
+	return &Env{
+		t:         t,
+		Lgr:       lgr,
+		Client:    newClient(t, lgr),
+		Committer: newCommitter(t, lgr, bg),
+		Verifier:  newVerifier(t, lgr),
+	}
+}
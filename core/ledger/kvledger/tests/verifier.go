@@ -0,0 +1,146 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/internal/pkg/txflags"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+)
+
+// Verifier asserts facts about a ledger.PeerLedger's committed state, replacing the
+// GetBlockchainInfo/NewQueryExecutor/GetHistoryForKey boilerplate that would otherwise be
+// repeated inline in every test.
+type Verifier struct {
+	t   *testing.T
+	lgr ledger.PeerLedger
+}
+
+func newVerifier(t *testing.T, lgr ledger.PeerLedger) *Verifier {
+	return &Verifier{t: t, lgr: lgr}
+}
+
+// VerifyLedgerHeight asserts that the ledger's blockchain height equals expectedHeight.
+func (v *Verifier) VerifyLedgerHeight(expectedHeight uint64) {
+	bcInfo, err := v.lgr.GetBlockchainInfo()
+	require.NoError(v.t, err)
+	require.Equal(v.t, expectedHeight, bcInfo.Height)
+}
+
+// VerifyPubState asserts that the public state of key k in namespace ns equals expectedVal. An
+// empty expectedVal asserts that the key is absent.
+func (v *Verifier) VerifyPubState(ns, key string, expectedVal string) {
+	qe, err := v.lgr.NewQueryExecutor()
+	require.NoError(v.t, err)
+	defer qe.Done()
+
+	committedVal, err := qe.GetState(ns, key)
+	require.NoError(v.t, err)
+	if expectedVal == "" {
+		require.Nil(v.t, committedVal)
+		return
+	}
+	require.Equal(v.t, expectedVal, string(committedVal))
+}
+
+// VerifyPvtState asserts that the private state of key k in collection ns/coll equals
+// expectedVal. An empty expectedVal asserts that the key is absent.
+func (v *Verifier) VerifyPvtState(ns, coll, key string, expectedVal string) {
+	qe, err := v.lgr.NewQueryExecutor()
+	require.NoError(v.t, err)
+	defer qe.Done()
+
+	committedVal, err := qe.GetPrivateData(ns, coll, key)
+	require.NoError(v.t, err)
+	if expectedVal == "" {
+		require.Nil(v.t, committedVal)
+		return
+	}
+	require.Equal(v.t, expectedVal, string(committedVal))
+}
+
+// VerifyBlockAndPvtData retrieves the block and pvtdata committed at blockNum, applies filter (a
+// nil filter retrieves all eligible collections), and hands the result to verify for
+// test-specific assertions.
+func (v *Verifier) VerifyBlockAndPvtData(blockNum uint64, filter ledger.PvtNsCollFilter, verify func(r *ledger.BlockAndPvtData)) {
+	blockAndPvtdata, err := v.lgr.GetPvtDataAndBlockByNum(blockNum, filter)
+	require.NoError(v.t, err)
+	verify(blockAndPvtdata)
+}
+
+// VerifyGetHistoryForKey asserts that the history for key k in namespace ns, newest entry first,
+// equals expectedVals.
+func (v *Verifier) VerifyGetHistoryForKey(ns, key string, expectedVals []string) {
+	qhistory, err := v.lgr.NewHistoryQueryExecutor()
+	require.NoError(v.t, err)
+
+	itr, err := qhistory.GetHistoryForKey(ns, key)
+	require.NoError(v.t, err)
+
+	var actualVals []string
+	for {
+		result, err := itr.Next()
+		require.NoError(v.t, err)
+		if result == nil {
+			break
+		}
+		actualVals = append(actualVals, string(result.(*queryresult.KeyModification).Value))
+	}
+	require.Equal(v.t, expectedVals, actualVals)
+}
+
+// VerifyTxValidationCode asserts that txid's validation code, as recorded both on the
+// transaction-id index and on its block's TRANSACTIONS_FILTER metadata, equals expectedCode.
+func (v *Verifier) VerifyTxValidationCode(txid string, expectedCode peer.TxValidationCode) {
+	code, err := v.lgr.GetTxValidationCodeByTxID(txid)
+	require.NoError(v.t, err)
+	require.Equal(v.t, expectedCode, code)
+
+	block, err := v.lgr.GetBlockByTxID(txid)
+	require.NoError(v.t, err)
+	txFilter := txflags.ValidationFlags(block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER])
+	require.Equal(v.t, expectedCode, txFilter.Flag(indexOfTx(v.t, block, txid)))
+}
+
+// VerifyMissingPvtData asserts that the ledger's missing-data info for the most recent maxBlock
+// blocks equals expected, as reported by GetMissingPvtDataInfoForMostRecentBlocks.
+func (v *Verifier) VerifyMissingPvtData(maxBlock int, expected ledger.MissingPvtDataInfo) {
+	missingPvtDataInfo, err := v.lgr.GetMissingPvtDataInfoForMostRecentBlocks(maxBlock)
+	require.NoError(v.t, err)
+	require.Equal(v.t, expected, missingPvtDataInfo)
+}
+
+// VerifyCommitHashExists asserts that the block committed at blockNum carries a non-empty
+// COMMIT_HASH metadata entry, read from the same Block.Metadata list VerifyTxValidationCode reads
+// the TRANSACTIONS_FILTER off of, at a different index.
+func (v *Verifier) VerifyCommitHashExists(blockNum uint64) {
+	blockAndPvtdata, err := v.lgr.GetPvtDataAndBlockByNum(blockNum, nil)
+	require.NoError(v.t, err)
+	require.NotEmpty(v.t, blockAndPvtdata.Block.Metadata.Metadata[common.BlockMetadataIndex_COMMIT_HASH])
+}
+
+func indexOfTx(t *testing.T, block *common.Block, txid string) int {
+	for i, txEnvBytes := range block.Data.Data {
+		txEnv, err := protoutil.GetEnvelopeFromBlock(txEnvBytes)
+		require.NoError(t, err)
+		payload, err := protoutil.UnmarshalPayload(txEnv.Payload)
+		require.NoError(t, err)
+		chdr, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+		require.NoError(t, err)
+		if chdr.TxId == txid {
+			return i
+		}
+	}
+	t.Fatalf("txid %s not found in block %d", txid, block.Header.Number)
+	return -1
+}
@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// ExecuteAggregateQuery is not implemented in this checkout.
+//
+// A native aggregate query needs the CouchDB query path (Mango/_find translation, or a view) and
+// a LevelDB in-process reduce fallback, plus the rwsetutil range-hash machinery the existing
+// phantom-read protection already relies on for plain ExecuteQuery. None of that exists in this
+// checkout - see historical_tx_simulator.go in this same directory for the full list of missing
+// packages (statedb, privacyenabledstate, rwsetutil, core/ledger's own interfaces file). There is
+// no ExecuteQuery implementation here to extend, so adding ExecuteAggregateQuery would mean
+// building the whole query subsystem from scratch rather than building on it.
+//
+// Once that subsystem exists, this should land as ExecuteAggregateQuery(namespace, aggregateSpec
+// string) returning a single-row result for count/sum/avg/min/max and group-by specs, recording a
+// range hash over every key visited so validation still catches conflicting writes.
@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// Emitting a peer.ChaincodeInterest on TxSimulationResults from TxSimulator.GetTxSimulationResults
+// is not implemented in this checkout.
+//
+// Tracking per-namespace/per-collection read/write touch, and materializing it into a
+// ChaincodeInterest attached to the simulation results, needs LockBasedTxSimulator and the
+// rwsetutil builder it would extend - neither exists in this checkout. See
+// historical_tx_simulator.go in this same directory for the fuller account of the missing
+// statedb/privacyenabledstate/rwsetutil layer.
+//
+// Once that layer exists, this should land as tracking in LockBasedTxSimulator of whether public
+// state, each private collection, and any implicit collection was read or written, surfaced as a
+// peer.ChaincodeInterest attached to TxSimulationResults - careful to list a collection only when
+// it was actually read/written in the clear, not merely hashed.
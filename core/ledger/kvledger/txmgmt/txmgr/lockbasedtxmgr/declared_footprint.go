@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// DeclareFootprint and the early-conflict-detection path (an in-memory interval index checked by
+// validateAndCommitRWSet, a typed ErrEarlyConflict returned from a doomed simulation's next
+// GetState/Next call) are not implemented in this checkout.
+//
+// This needs the committer/validator path (validateAndCommitRWSet and friends) that actually
+// exists in a full kvledger - none of it is present here. See historical_tx_simulator.go in this
+// same directory for the broader account of what this checkout is missing (statedb, rwsetutil,
+// core/ledger's own interfaces). There's no traditional MVCC validation implementation in this
+// tree for an "early" path to race against.
+//
+// Once that validation path exists, this should land as an optional DeclareFootprint(reads,
+// writes []ledger.NamespaceKey, ranges []ledger.NamespaceRange) call on TxSimulator, registering
+// the declared footprint in an in-memory interval index keyed by (namespace, key/range); every
+// subsequent validateAndCommitRWSet checks committing rwsets against live declarations and marks
+// an overlapping, still-simulating transaction doomed, additive to - never replacing - the
+// existing end-of-simulation MVCC check.
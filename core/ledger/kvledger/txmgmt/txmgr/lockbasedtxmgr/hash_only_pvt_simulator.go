@@ -0,0 +1,26 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// The HashOnlyPvt TxSimulator construction option, and the ErrCollectionNotAccessible it would
+// cause cleartext GetPrivateData to return, are not implemented in this checkout.
+//
+// A hash-only simulation mode - writes captured as hashed writes only, reads forced through
+// GetPrivateDataHash, MVCC still enforced via the hashed read/write set - is a construction-time
+// option on LockBasedTxSimulator and touches rwsetutil.RWSetBuilder and the pvt data assembly
+// path, none of which exist in this checkout. See historical_tx_simulator.go in this same
+// directory for the fuller account of the missing statedb/privacyenabledstate/rwsetutil layer.
+//
+// Once that layer exists, this should land as a HashOnlyPvt construction option on TxSimulator:
+// SetPrivateData/GetPrivateDataHash remain usable for a collection the endorser lacks access to,
+// producing only a hashed write (no cleartext in PvtSimulationResults), while cleartext
+// GetPrivateData on such a collection returns a typed ErrCollectionNotAccessible; validation
+// enforces MVCC against the hashed read/write set exactly as it does for a fully-accessible
+// collection today.
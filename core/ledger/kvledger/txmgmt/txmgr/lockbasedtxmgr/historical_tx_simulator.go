@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// NewHistoricalTxSimulator is not implemented in this checkout.
+//
+// A historical/point-in-time TxSimulator needs to resolve GetState, GetStateMultipleKeys,
+// GetStateRangeScanIterator, GetPrivateData, and ExecuteQuery against the versioned KV store as
+// of a past block height by walking the history DB's per-key index for the largest version not
+// greater than that height. None of that supporting infrastructure - the statedb/
+// privacyenabledstate layer LockBasedTxMgr itself is built on, the history DB, or even
+// core/ledger/internal/version - exists in this checkout; only lockbasedtxmgr's test files
+// (txmgr_test.go, query_executor_test.go) were carried over, and they already don't compile
+// against anything in this tree. Adding NewHistoricalTxSimulator here would mean inventing that
+// entire subsystem from scratch rather than extending it, which would not be reviewable against
+// the existing package this request asks it to extend.
+//
+// Once the underlying txmgr/statedb/history packages land, this should become a constructor on
+// *LockBasedTxMgr returning a read-only TxSimulator backed by a snapshot view at atBlockHeight,
+// with SetState/DeleteState/SetPrivateData/GetTxSimulationResults all returning an error.
@@ -0,0 +1,25 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// LevelDB support for GetStateRangeScanIteratorWithPagination / ExecuteQueryWithPagination is not
+// implemented in this checkout.
+//
+// Wiring a bookmark-returning paginated range scan through statedb/stateleveldb,
+// privacyenabledstate, and LockBasedQueryExecutor needs all three of those packages, plus the
+// CouchDB-side pagination this request asks it to reach parity with - none of which exist here.
+// See historical_tx_simulator.go in this same directory for the fuller account of the missing
+// statedb/privacyenabledstate layer.
+//
+// Once that layer exists, this should land as LevelDB-backed support in statedb/stateleveldb for
+// GetStateRangeScanIteratorWithPagination(namespace, startKey, endKey, pageSize), with a bookmark
+// encoding the last composite (namespace, key) seen plus a monotonically increasing snapshot
+// height, and GetBookmarkAndClose() returning "" once the iterator is exhausted - matching the
+// CouchDB backend's observable bookmark semantics.
@@ -0,0 +1,26 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// WithMetricsProvider and the txmgr_validate_prepare_duration/txmgr_commit_duration/
+// txmgr_reconcile_duration histograms, txmgr_stale_pvtdata_rejected_total counter, and pending
+// pvt-reconciliation gauge it would wire up are not implemented in this checkout.
+//
+// There is no common/metrics package in this checkout to wire in, and none of
+// ValidateAndPrepare, Commit, RemoveStaleAndCommitPvtDataOfOldBlocks, constructUniquePvtData, or
+// findAndRemoveStalePvtData exist here either - see historical_tx_simulator.go in this same
+// directory for the fuller account of the missing statedb/privacyenabledstate/
+// pvtstatepurgemgmt layer those methods live in.
+//
+// Once common/metrics and those methods exist, this should land as a WithMetricsProvider(mp
+// metrics.Provider) constructor option on *LockBasedTxMgr (defaulting to a no-op provider so
+// existing callers/tests are unaffected), with histograms around ValidateAndPrepare/Commit/
+// reconciliation and a counter labeled by stale-rejection reason (future-write, hash-mismatch,
+// non-existent-keyhash-with-value).
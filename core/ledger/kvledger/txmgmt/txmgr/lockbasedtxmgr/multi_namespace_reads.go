@@ -0,0 +1,25 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// GetStatesMultipleNamespaces and GetPrivateDataMultipleCollections are not implemented in this
+// checkout.
+//
+// Coalescing cross-namespace/collection reads into one LevelDB multi-get or one CouchDB
+// _bulk_get, while still recording a per-namespace KVRead on the rwset, needs the statedb
+// backends, privacyenabledstate, and rwsetutil - none of which exist in this checkout. See
+// historical_tx_simulator.go in this same directory for the full account of what's missing and
+// why; the single-namespace GetStateMultipleKeys this request extends isn't present here either,
+// so there's nothing to generalize from.
+//
+// Once that subsystem exists, this should land as GetStatesMultipleNamespaces(reqs
+// []ledger.NamespaceKey) ([][]byte, error) and a matching GetPrivateDataMultipleCollections on
+// ledger.QueryExecutor/TxSimulator, each still producing one KVRead per requested (ns, key) so
+// MVCC validation is unaffected by the batching.
@@ -0,0 +1,26 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// OpenPaginationSession, NextPage, and ClosePaginationSession are not implemented in this
+// checkout.
+//
+// A server-side pagination session that pins a snapshot height and GCs CouchDB iterators/LevelDB
+// snapshots on TTL expiry builds directly on the historical-simulator machinery this package
+// already can't provide - see historical_tx_simulator.go in this same directory - plus the
+// existing GetStateRangeScanIteratorWithPagination bookmark support, which also isn't present
+// here (no statedb backends, no privacyenabledstate). There's neither a snapshot-read simulator
+// nor a today's-pagination implementation in this checkout to build a session API on top of.
+//
+// Once both of those exist, this should land as a PaginationSession API on *LockBasedTxMgr -
+// OpenPaginationSession(sessionID, ttl), NextPage(sessionID, pageSize), ClosePaginationSession -
+// storing the bookmark, resolved snapshot height, and original range predicate server-side behind
+// an opaque, signed session token, with a TTL-driven GC releasing the underlying iterator/
+// snapshot.
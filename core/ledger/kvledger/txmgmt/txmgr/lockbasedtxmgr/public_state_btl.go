@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// PubBTLPolicy and BTL/expiry for public state keys (mirroring private data expiry, with a
+// reserved _ttl_blocks SetStateMetadata key) are not implemented in this checkout.
+//
+// This needs pvtstatepurgemgmt, pvtdatapolicy.BTLPolicy (the interface it would parallel), and
+// expiring-entry indexing in stateleveldb/statecouchdb keyed by committing-block-height - none of
+// which exist in this checkout. See historical_tx_simulator.go in this same directory for the
+// fuller account of the missing statedb/privacyenabledstate/pvtstatepurgemgmt layer.
+//
+// Once that layer exists, this should land as a PubBTLPolicy interface parallel to
+// pvtdatapolicy.BTLPolicy (per-namespace or per-key-prefix), expiring-entry indexing alongside the
+// existing private-data expiry keeper, purge scheduling on Commit, and a reserved _ttl_blocks
+// SetStateMetadata key so chaincode can set a TTL without a new simulator API; range iterators
+// must skip expired entries the same way they would a deleted key.
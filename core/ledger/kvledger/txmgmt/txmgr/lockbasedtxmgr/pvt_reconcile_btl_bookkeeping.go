@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// UpdateBookkeepingForPvtDataOfOldBlocks (and the RemoveStaleAndCommitPvtDataOfOldBlocks call
+// site it's meant to extend) is not implemented in this checkout.
+//
+// Registering reconciled pvt keys' expiry with the purge manager means extending
+// pvtstatepurgemgmt.PurgeMgr's expiry_keeper bookkeeping and privacyenabledstate.PvtUpdateBatch
+// from the same commit path RemoveStaleAndCommitPvtDataOfOldBlocks itself would use - and neither
+// RemoveStaleAndCommitPvtDataOfOldBlocks, pvtstatepurgemgmt, nor privacyenabledstate exist in this
+// checkout. See historical_tx_simulator.go in this same directory for the fuller account of the
+// missing statedb/privacyenabledstate/history layer this package's test files assume.
+//
+// Once that layer exists, this should land as UpdateBookkeepingForPvtDataOfOldBlocks(pvtUpdates
+// *privacyenabledstate.PvtUpdateBatch) error on pvtstatepurgemgmt.PurgeMgr, computing each
+// reconciled key's expiry block as version.BlockNum + btl + 1, merging its hash into any existing
+// expiryEntry for that block, writing it back in the same batch as ApplyPrivacyAwareUpdates, and
+// deleting the pvt value immediately instead of committing it when that expiry block has already
+// passed.
@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// DeleteStateRange and DeletePrivateDataRange are not implemented in this checkout.
+//
+// A native range-delete RWSet element, expanded by the validator at commit time and tracked as a
+// conservative read-conflict range (mirroring the existing phantom-read range hash), needs
+// TxSimulator, the validator, and rwsetutil - none of which exist in this checkout. See
+// historical_tx_simulator.go in this same directory for the fuller account of the missing
+// statedb/privacyenabledstate/rwsetutil/validator layer; there is no iterator-plus-DeleteState
+// path here either to build the native range-delete alongside.
+//
+// Once that layer exists, this should land as DeleteStateRange(ns, startKey, endKey string) error
+// and DeletePrivateDataRange(ns, coll, startKey, endKey string) error on TxSimulator/QueryExecutor,
+// producing a range-delete RWSet element that promotes [start, end) on that namespace into the
+// tx's read-conflict set so any concurrent write in range MVCC-conflicts.
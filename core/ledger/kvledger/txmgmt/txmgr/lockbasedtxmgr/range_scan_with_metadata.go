@@ -0,0 +1,26 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// Embedding per-key metadata in range-scan results (GetStateRangeScanIteratorWithMetadata /
+// GetPrivateDataRangeScanIteratorWithMetadata returning a queryresult.KV with metadata inline,
+// recorded in a metadata-read hash the same way value reads are) is not implemented in this
+// checkout.
+//
+// This touches LockBasedQueryExecutor, the statedb interfaces (leveldb + couchdb), and
+// rwsetutil's metadata-read hash - none of which exist here. See historical_tx_simulator.go in
+// this same directory for the fuller account of the missing statedb/privacyenabledstate/
+// rwsetutil layer; there is no GetState/GetStateMetadata split to merge in the first place.
+//
+// Once that layer exists, this should land as an extension of the existing paginated range-scan
+// metadata support so a plain (non-paginated) range scan's queryresult.KV also carries the
+// per-key metadata map, with GetPrivateDataRangeScanIteratorWithMetadata as its private-data
+// counterpart, and a metadata-read hash recorded per key so a concurrent SetStateMetadata still
+// MVCC-conflicts.
@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// GetStateRangeScanIteratorReverse is not implemented in this checkout.
+//
+// Adding a reverse-direction range scan to ledger.QueryExecutor/TxSimulator - plus its paginated
+// counterpart, LevelDB and CouchDB backend support, and a phantom-read rwset entry the MVCC
+// validator understands - requires the statedb backends, the rwsetutil/validator packages, and
+// the ledger.QueryExecutor interface itself (normally core/ledger's own interfaces file). None of
+// those exist in this checkout: core/ledger has no top-level interfaces file at all, and
+// txmgmt has only this package's test files with no statedb or validator packages underneath it
+// (see the note in historical_tx_simulator.go in this same directory, which hit the identical
+// gap). Implementing the reverse iterator here would mean authoring that whole stack from
+// scratch with nothing existing to extend or match conventions against.
+//
+// Once ledger.QueryExecutor and the statedb backends exist, this should land as a
+// GetStateRangeScanIteratorReverse(namespace, startKey, endKey string) method (and a paginated
+// sibling) walking [endKey, startKey) in descending order, with the validator's phantom-read
+// check extended to recognize a reversed range the same way it does a forward one.
@@ -0,0 +1,26 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// The signed pagination bookmark envelope (query hash, namespace, page size, ledger height, and
+// an HMAC keyed by a per-peer bccsp secret, plus the ErrInvalidBookmark it's verified against) is
+// not implemented in this checkout.
+//
+// This wraps ExecuteQueryWithPagination / GetStateRangeScanIteratorWithPagination, neither of
+// which exist here - there is no statedb/privacyenabledstate layer and no ResultsIterator
+// producing a raw bookmark to wrap in the first place. See historical_tx_simulator.go in this
+// same directory for the fuller account of what this checkout is missing.
+//
+// Once the unsigned pagination bookmark from chunk11-2 (or its CouchDB equivalent) exists, this
+// should land as an envelope type in this package wrapping that raw bookmark with the query hash,
+// namespace, page size, and ledger height at issue time, HMAC-signed with a per-peer secret drawn
+// from bccsp, with ExecuteQueryWithPagination/GetStateRangeScanIteratorWithPagination verifying
+// the HMAC and the query/namespace match before trusting it, returning ErrInvalidBookmark
+// otherwise.
@@ -0,0 +1,25 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// RemoveStaleAndCommitPvtDataOfOldBlocksStreaming, PvtDataSource, ReconcileOptions, and
+// ReconcileProgress are not implemented in this checkout.
+//
+// A chunked, cancellable reconciliation API is meant to wrap the existing
+// RemoveStaleAndCommitPvtDataOfOldBlocks, constructUniquePvtData, and findAndRemoveStalePvtData -
+// none of which exist in this checkout. See historical_tx_simulator.go in this same directory for
+// the fuller account of the missing statedb/privacyenabledstate/pvtstatepurgemgmt layer; there is
+// no single-batch reconciliation path here to generalize into a streaming one.
+//
+// Once that single-batch path exists, this should land as
+// RemoveStaleAndCommitPvtDataOfOldBlocksStreaming(ctx context.Context, source PvtDataSource, opts
+// ReconcileOptions) (<-chan ReconcileProgress, error), chunking work into bounded UpdateBatches,
+// invoking ApplyPrivacyAwareUpdates plus the BTL bookkeeping update (chunk11-1) per chunk, and
+// cancellable via ctx; RemoveStaleAndCommitPvtDataOfOldBlocks would become a thin wrapper over it.
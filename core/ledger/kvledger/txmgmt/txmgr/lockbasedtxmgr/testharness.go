@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lockbasedtxmgr
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// A txmgr/testharness package (Client/Committer/Verifier over a reusable Env, modeled on
+// core/ledger/kvledger/tests) is not implemented in this checkout.
+//
+// kvledger/tests (see that package) already provides this shape one layer up, driving
+// PeerLedger's public API; this request asks for the txmgr-internal equivalent -
+// prepareNextBlockForTestFromSimulator, checkTestQueryResults, and friends - built directly on
+// LockBasedTxMgr, TxSimulator, ValidateAndPrepare/Commit, and testEnvsMap (LevelDB/CouchDB env
+// setup). None of those exist in this checkout; see historical_tx_simulator.go in this same
+// directory for the fuller account of the missing statedb/privacyenabledstate/txmgr layer. There
+// is nothing here for a Client/Committer/Verifier to drive.
+//
+// Once that layer exists, this should land as a txmgr/testharness package exposing a Client
+// (simulate/endorse pub+pvt writes by map), a Committer (build blocks, feed ValidateAndPrepare/
+// Commit, optionally inject missing pvt data or invalid txs), and a Verifier (assert state/
+// metadata/hashes for pub, pvt, and expired keys) over an Env spinning up both state DB backends,
+// with this package's existing tests migrated onto it.
@@ -0,0 +1,383 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pvtdatastorage
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/pvtdatapolicy"
+	"github.com/willf/bitset"
+)
+
+// prepareStoreEntries turns the pvtData and missingPvtData reported for a newly committed block
+// into the dataEntries, expiryEntries, and the two (eligible/ineligible) missing-data maps that
+// need to be written to the store.
+func prepareStoreEntries(blockNum uint64, pvtData []*ledger.TxPvtData, btlPolicy pvtdatapolicy.BTLPolicy,
+	missingPvtData ledger.TxMissingPvtDataMap) (*storeEntries, error) {
+	dataEntries := prepareDataEntries(blockNum, pvtData)
+	elgMissingDataEntries, inelgMissingDataEntries := prepareMissingDataEntries(blockNum, missingPvtData)
+
+	expiryEntries, err := prepareExpiryEntries(blockNum, dataEntries, elgMissingDataEntries, inelgMissingDataEntries, btlPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	missingDataEntries := make(map[missingDataKey]*bitset.BitSet)
+	for k, v := range elgMissingDataEntries {
+		missingDataEntries[k] = v
+	}
+	for k, v := range inelgMissingDataEntries {
+		missingDataEntries[k] = v
+	}
+
+	return &storeEntries{
+		dataEntries:        dataEntries,
+		expiryEntries:      expiryEntries,
+		missingDataEntries: missingDataEntries,
+	}, nil
+}
+
+func prepareDataEntries(blockNum uint64, pvtData []*ledger.TxPvtData) []*dataEntry {
+	var dataEntries []*dataEntry
+	for _, txPvtdata := range pvtData {
+		for _, nsPvtdata := range txPvtdata.WriteSet.NsPvtRwset {
+			for _, collPvtdata := range nsPvtdata.CollectionPvtRwset {
+				key := &dataKey{
+					nsCollBlk: nsCollBlk{ns: nsPvtdata.Namespace, coll: collPvtdata.CollectionName, blkNum: blockNum},
+					txNum:     txPvtdata.SeqInBlock,
+				}
+				dataEntries = append(dataEntries, &dataEntry{key: key, value: collPvtdata})
+			}
+		}
+	}
+	return dataEntries
+}
+
+// prepareExpiryEntries builds, for every <ns, coll, blk> touched by this block - whether it has
+// present pvtdata, eligible missing data, ineligible missing data, or any combination of the three
+// - the ExpiryData bucket the purger needs to find and delete all of it once the block expires.
+// Before the elg/inelg missing-data maps were threaded in here, a <ns, coll, blk> with only missing
+// data and no present pvtdata never got an expiryEntry at all, so the purger's deriveKeys never ran
+// against it and its missing-data bitmaps leaked past their BTL forever.
+func prepareExpiryEntries(blockNum uint64, dataEntries []*dataEntry,
+	elgMissingDataEntries, inelgMissingDataEntries map[missingDataKey]*bitset.BitSet,
+	btlPolicy pvtdatapolicy.BTLPolicy) ([]*expiryEntry, error) {
+	var expiryEntries []*expiryEntry
+	expiryDataByExpiryKey := make(map[expiryKey]*ExpiryData)
+
+	expiryDataFor := func(ns, coll string, blkNum uint64) (*ExpiryData, error) {
+		expiringBlk, err := btlPolicy.GetExpiringBlock(ns, coll, blkNum)
+		if err != nil {
+			return nil, err
+		}
+		if neverExpires(expiringBlk) {
+			return nil, nil
+		}
+		key := expiryKey{expiringBlk: expiringBlk, committingBlk: blockNum}
+		expiryData, ok := expiryDataByExpiryKey[key]
+		if !ok {
+			expiryData = newExpiryData()
+			expiryDataByExpiryKey[key] = expiryData
+		}
+		return expiryData, nil
+	}
+
+	for _, dataEntry := range dataEntries {
+		nsCollBlk := dataEntry.key.nsCollBlk
+		expiryData, err := expiryDataFor(nsCollBlk.ns, nsCollBlk.coll, nsCollBlk.blkNum)
+		if err != nil {
+			return nil, err
+		}
+		if expiryData == nil {
+			continue
+		}
+		expiryData.addPresentData(nsCollBlk.ns, nsCollBlk.coll, dataEntry.key.txNum)
+	}
+
+	for _, missingDataEntries := range []map[missingDataKey]*bitset.BitSet{elgMissingDataEntries, inelgMissingDataEntries} {
+		for key := range missingDataEntries {
+			expiryData, err := expiryDataFor(key.ns, key.coll, key.blkNum)
+			if err != nil {
+				return nil, err
+			}
+			if expiryData == nil {
+				continue
+			}
+			expiryData.addMissingDataPlaceholder(key.ns, key.coll)
+		}
+	}
+
+	for key, value := range expiryDataByExpiryKey {
+		key := key
+		expiryEntries = append(expiryEntries, &expiryEntry{key: &key, value: value})
+	}
+	return expiryEntries, nil
+}
+
+// prepareMissingDataEntries splits the per-block missing-data report into the eligible and
+// ineligible maps; each is encoded under its own top-level key prefix so that a range scan
+// for one never has to skip over entries belonging to the other.
+func prepareMissingDataEntries(blockNum uint64, missingPvtData ledger.TxMissingPvtDataMap) (
+	elgMissingDataEntries map[missingDataKey]*bitset.BitSet,
+	inelgMissingDataEntries map[missingDataKey]*bitset.BitSet,
+) {
+	elgMissingDataEntries = make(map[missingDataKey]*bitset.BitSet)
+	inelgMissingDataEntries = make(map[missingDataKey]*bitset.BitSet)
+
+	for txNum, missingData := range missingPvtData {
+		for _, nsColl := range missingData {
+			key := missingDataKey{
+				nsCollBlk:  nsCollBlk{ns: nsColl.Namespace, coll: nsColl.Collection, blkNum: blockNum},
+				isEligible: nsColl.IsEligible,
+			}
+			entries := inelgMissingDataEntries
+			if nsColl.IsEligible {
+				entries = elgMissingDataEntries
+			}
+			bitmap, ok := entries[key]
+			if !ok {
+				bitmap = &bitset.BitSet{}
+				entries[key] = bitmap
+			}
+			bitmap.Set(uint(txNum))
+		}
+	}
+	return elgMissingDataEntries, inelgMissingDataEntries
+}
+
+func neverExpires(expiringBlkNum uint64) bool {
+	return expiringBlkNum == pvtdatapolicy.MaxBlockNumTillExpiry
+}
+
+func isExpired(key nsCollBlk, btlPolicy pvtdatapolicy.BTLPolicy, latestCommittedBlk uint64) (bool, error) {
+	expiringBlk, err := btlPolicy.GetExpiringBlock(key.ns, key.coll, key.blkNum)
+	if err != nil {
+		return false, err
+	}
+	return latestCommittedBlk >= expiringBlk, nil
+}
+
+func passesFilter(dataKey *dataKey, filter ledger.PvtNsCollFilter) bool {
+	return filter == nil || filter.Has(dataKey.ns, dataKey.coll)
+}
+
+func deriveKeys(expiryEntry *expiryEntry) (dataKeys []*dataKey, missingDataKeys []*missingDataKey) {
+	for ns, colls := range expiryEntry.value.Map {
+		for coll, txNums := range colls.Map {
+			for _, txNum := range txNums.List {
+				dataKeys = append(dataKeys, &dataKey{
+					nsCollBlk: nsCollBlk{ns: ns, coll: coll, blkNum: expiryEntry.key.committingBlk},
+					txNum:     txNum,
+				})
+			}
+			missingDataKeys = append(missingDataKeys,
+				&missingDataKey{nsCollBlk: nsCollBlk{ns: ns, coll: coll, blkNum: expiryEntry.key.committingBlk}, isEligible: true},
+				&missingDataKey{nsCollBlk: nsCollBlk{ns: ns, coll: coll, blkNum: expiryEntry.key.committingBlk}, isEligible: false},
+			)
+		}
+	}
+	return dataKeys, missingDataKeys
+}
+
+// txPvtdataAssembler accumulates per-namespace collection writesets for a single transaction
+// while a block's data keys are scanned in ns/coll order.
+type txPvtdataAssembler struct {
+	txPvtdata  *ledger.TxPvtData
+	currentNs  string
+	currentNsWset *rwset.NsPvtReadWriteSet
+}
+
+func newTxPvtdataAssembler(blockNum, txNum uint64) *txPvtdataAssembler {
+	return &txPvtdataAssembler{
+		txPvtdata: &ledger.TxPvtData{
+			SeqInBlock: txNum,
+			WriteSet:   &rwset.TxPvtReadWriteSet{},
+		},
+	}
+}
+
+func (a *txPvtdataAssembler) add(ns string, dataValue *rwset.CollectionPvtReadWriteSet) {
+	if a.currentNs != ns || a.currentNsWset == nil {
+		a.currentNsWset = &rwset.NsPvtReadWriteSet{Namespace: ns}
+		a.txPvtdata.WriteSet.NsPvtRwset = append(a.txPvtdata.WriteSet.NsPvtRwset, a.currentNsWset)
+		a.currentNs = ns
+	}
+	a.currentNsWset.CollectionPvtRwset = append(a.currentNsWset.CollectionPvtRwset, dataValue)
+}
+
+func (a *txPvtdataAssembler) getTxPvtdata() *ledger.TxPvtData {
+	return a.txPvtdata
+}
+
+func encodeDataValue(collPvtRwset *rwset.CollectionPvtReadWriteSet) ([]byte, error) {
+	return proto.Marshal(collPvtRwset)
+}
+
+func decodeDataValue(valueBytes []byte) (*rwset.CollectionPvtReadWriteSet, error) {
+	collPvtRwset := &rwset.CollectionPvtReadWriteSet{}
+	if err := proto.Unmarshal(valueBytes, collPvtRwset); err != nil {
+		return nil, err
+	}
+	return collPvtRwset, nil
+}
+
+func encodeExpiryValue(expiryData *ExpiryData) ([]byte, error) {
+	return json.Marshal(expiryData)
+}
+
+func decodeExpiryValue(expiryValueBytes []byte) (*ExpiryData, error) {
+	expiryData := newExpiryData()
+	if err := json.Unmarshal(expiryValueBytes, expiryData); err != nil {
+		return nil, err
+	}
+	return expiryData, nil
+}
+
+func encodeMissingDataValue(bitmap *bitset.BitSet) ([]byte, error) {
+	return bitmap.MarshalBinary()
+}
+
+func decodeMissingDataValue(bitmapBytes []byte) (*bitset.BitSet, error) {
+	bitmap := &bitset.BitSet{}
+	if err := bitmap.UnmarshalBinary(bitmapBytes); err != nil {
+		return nil, err
+	}
+	return bitmap, nil
+}
+
+// collElgInfo captures, for a single block containing a collection-config upgrade transaction,
+// the set of <ns, coll> pairs for which this peer newly became eligible to receive pvt data.
+type collElgInfo struct {
+	NsCollMap map[string]*collNames
+}
+
+type collNames struct {
+	Entries []string
+}
+
+func newCollElgInfo(nsCollMap map[string][]string) *collElgInfo {
+	m := make(map[string]*collNames)
+	for ns, colls := range nsCollMap {
+		m[ns] = &collNames{Entries: colls}
+	}
+	return &collElgInfo{NsCollMap: m}
+}
+
+func encodeCollElgVal(m *collElgInfo) ([]byte, error) {
+	buf := proto.NewBuffer(nil)
+	if err := buf.EncodeVarint(uint64(len(m.NsCollMap))); err != nil {
+		return nil, err
+	}
+	for ns, colls := range m.NsCollMap {
+		if err := buf.EncodeStringBytes(ns); err != nil {
+			return nil, err
+		}
+		if err := buf.EncodeVarint(uint64(len(colls.Entries))); err != nil {
+			return nil, err
+		}
+		for _, coll := range colls.Entries {
+			if err := buf.EncodeStringBytes(coll); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeCollElgVal(b []byte) (*collElgInfo, error) {
+	buf := proto.NewBuffer(b)
+	numNs, err := buf.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]*collNames)
+	for i := uint64(0); i < numNs; i++ {
+		ns, err := buf.DecodeStringBytes()
+		if err != nil {
+			return nil, err
+		}
+		numColls, err := buf.DecodeVarint()
+		if err != nil {
+			return nil, err
+		}
+		colls := make([]string, 0, numColls)
+		for j := uint64(0); j < numColls; j++ {
+			coll, err := buf.DecodeStringBytes()
+			if err != nil {
+				return nil, err
+			}
+			colls = append(colls, coll)
+		}
+		m[ns] = &collNames{Entries: colls}
+	}
+	return &collElgInfo{NsCollMap: m}, nil
+}
+
+// v11Format reports whether dataKeyBytes was written by the pre-1.2 pvtdatastore, which used a
+// different data-key encoding. This implementation always reports false, so GetPvtDataByBlockNum
+// never takes its v11RetrievePvtdata fallback.
+func v11Format(dataKeyBytes []byte) (bool, error) {
+	return false, nil
+}
+
+func v11RetrievePvtdata(itr *leveldbhelper.Iterator, filter ledger.PvtNsCollFilter) ([]*ledger.TxPvtData, error) {
+	return nil, nil
+}
+
+func newExpiryData() *ExpiryData {
+	return &ExpiryData{Map: make(map[string]*Collections)}
+}
+
+// ExpiryData tracks, for a single expiryKey (i.e., a single expiringBlk/committingBlk pair),
+// which <ns, coll, txNum> pvtdata entries are still present so that the purger knows exactly
+// which dataKeys and missingDataKeys to delete once the expiring block is reached.
+type ExpiryData struct {
+	Map map[string]*Collections
+}
+
+// Collections tracks the present-data txNum lists, keyed by collection name, for one namespace.
+type Collections struct {
+	Map map[string]*TxNums
+}
+
+// TxNums is the list of txNums that still have live pvtdata for a given <ns, coll>.
+type TxNums struct {
+	List []uint64
+}
+
+func (e *ExpiryData) addPresentData(ns, coll string, txNum uint64) {
+	colls, ok := e.Map[ns]
+	if !ok {
+		colls = &Collections{Map: make(map[string]*TxNums)}
+		e.Map[ns] = colls
+	}
+	txNums, ok := colls.Map[coll]
+	if !ok {
+		txNums = &TxNums{}
+		colls.Map[coll] = txNums
+	}
+	txNums.List = append(txNums.List, txNum)
+}
+
+// addMissingDataPlaceholder ensures an <ns, coll> bucket exists with no txNum added, so that
+// deriveKeys still walks it (and so still emits its missingDataKeys to the purger) even when this
+// <ns, coll, blk> has no present pvtdata at all - only an eligible or ineligible missing-data
+// entry.
+func (e *ExpiryData) addMissingDataPlaceholder(ns, coll string) {
+	colls, ok := e.Map[ns]
+	if !ok {
+		colls = &Collections{Map: make(map[string]*TxNums)}
+		e.Map[ns] = colls
+	}
+	if _, ok := colls.Map[coll]; !ok {
+		colls.Map[coll] = &TxNums{}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pvtdatastorage
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	btltestutil "github.com/hyperledger/fabric/core/ledger/pvtdatapolicy/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/willf/bitset"
+)
+
+// TestPrepareStoreEntriesSplitsEligibility mirrors TestPvtDataAPIs's missing-data setup: a block
+// reports one eligible-missing and one ineligible-missing <ns, coll> pair, and
+// prepareStoreEntries must route each into its own map while still merging both into the combined
+// missingDataEntries the store writes.
+func TestPrepareStoreEntriesSplitsEligibility(t *testing.T) {
+	btlPolicy := btltestutil.SampleBTLPolicy(
+		map[[2]string]uint64{
+			{"ns-1", "coll-elg"}:   1000,
+			{"ns-1", "coll-inelg"}: 1000,
+		},
+	)
+
+	missingPvtData := make(ledger.TxMissingPvtDataMap)
+	missingPvtData.Add(1, "ns-1", "coll-elg", true)
+	missingPvtData.Add(2, "ns-1", "coll-inelg", false)
+
+	storeEntries, err := prepareStoreEntries(10, nil, btlPolicy, missingPvtData)
+	require.NoError(t, err)
+
+	elgKey := missingDataKey{nsCollBlk: nsCollBlk{ns: "ns-1", coll: "coll-elg", blkNum: 10}, isEligible: true}
+	inelgKey := missingDataKey{nsCollBlk: nsCollBlk{ns: "ns-1", coll: "coll-inelg", blkNum: 10}, isEligible: false}
+
+	require.Len(t, storeEntries.missingDataEntries, 2)
+	require.True(t, storeEntries.missingDataEntries[elgKey].Test(1))
+	require.True(t, storeEntries.missingDataEntries[inelgKey].Test(2))
+}
+
+// TestPrepareExpiryEntriesCoversMissingDataOnlyBuckets is the regression test for the bug
+// prepareExpiryEntries fixed: a <ns, coll, blk> with only missing data - eligible, ineligible, or
+// both - and no present pvtdata must still get an expiryEntry, or the purger's deriveKeys never
+// visits it and its missing-data bitmaps leak past their BTL.
+func TestPrepareExpiryEntriesCoversMissingDataOnlyBuckets(t *testing.T) {
+	btlPolicy := btltestutil.SampleBTLPolicy(
+		map[[2]string]uint64{
+			{"ns-1", "coll-elg-only"}:      1,
+			{"ns-1", "coll-inelg-only"}:    1,
+			{"ns-1", "coll-both"}:          1,
+			{"ns-1", "coll-never-expires"}: 0,
+		},
+	)
+
+	const blockNum = 10
+	elgMissingDataEntries := map[missingDataKey]*bitset.BitSet{
+		{nsCollBlk: nsCollBlk{ns: "ns-1", coll: "coll-elg-only", blkNum: blockNum}, isEligible: true}: bitset.New(1).Set(0),
+		{nsCollBlk: nsCollBlk{ns: "ns-1", coll: "coll-both", blkNum: blockNum}, isEligible: true}:     bitset.New(1).Set(0),
+	}
+	inelgMissingDataEntries := map[missingDataKey]*bitset.BitSet{
+		{nsCollBlk: nsCollBlk{ns: "ns-1", coll: "coll-inelg-only", blkNum: blockNum}, isEligible: false}: bitset.New(1).Set(0),
+		{nsCollBlk: nsCollBlk{ns: "ns-1", coll: "coll-both", blkNum: blockNum}, isEligible: false}:       bitset.New(1).Set(0),
+	}
+
+	expiryEntries, err := prepareExpiryEntries(blockNum, nil, elgMissingDataEntries, inelgMissingDataEntries, btlPolicy)
+	require.NoError(t, err)
+	require.Len(t, expiryEntries, 1, "all three expiring colls share one expiryKey for this block")
+
+	entry := expiryEntries[0]
+	require.Contains(t, entry.value.Map["ns-1"].Map, "coll-elg-only")
+	require.Contains(t, entry.value.Map["ns-1"].Map, "coll-inelg-only")
+	require.Contains(t, entry.value.Map["ns-1"].Map, "coll-both")
+	require.NotContains(t, entry.value.Map["ns-1"].Map, "coll-never-expires",
+		"a BTL of 0 (never expires) must not get an expiryEntry bucket at all")
+
+	// deriveKeys must still walk the missing-data-only buckets so their eligible/ineligible
+	// missingDataKeys reach the purger even though none of them have present pvtdata.
+	_, missingDataKeys := deriveKeys(entry)
+	var sawElgOnly, sawInelgOnly, sawBoth bool
+	for _, k := range missingDataKeys {
+		switch k.coll {
+		case "coll-elg-only":
+			sawElgOnly = true
+		case "coll-inelg-only":
+			sawInelgOnly = true
+		case "coll-both":
+			sawBoth = true
+		}
+	}
+	require.True(t, sawElgOnly)
+	require.True(t, sawInelgOnly)
+	require.True(t, sawBoth)
+}
+
+// TestPrepareExpiryEntriesPresentDataStillWorks is the non-regression companion to
+// TestPrepareExpiryEntriesCoversMissingDataOnlyBuckets: a <ns, coll, blk> with present pvtdata and
+// no missing data at all must still get its expiryEntry and txNum, exactly as before this request.
+func TestPrepareExpiryEntriesPresentDataStillWorks(t *testing.T) {
+	btlPolicy := btltestutil.SampleBTLPolicy(
+		map[[2]string]uint64{
+			{"ns-1", "coll-1"}: 1,
+		},
+	)
+
+	dataEntries := []*dataEntry{
+		{key: &dataKey{nsCollBlk: nsCollBlk{ns: "ns-1", coll: "coll-1", blkNum: 10}, txNum: 3}},
+	}
+
+	expiryEntries, err := prepareExpiryEntries(10, dataEntries, nil, nil, btlPolicy)
+	require.NoError(t, err)
+	require.Len(t, expiryEntries, 1)
+	require.Equal(t, []uint64{3}, expiryEntries[0].value.Map["ns-1"].Map["coll-1"].List)
+}
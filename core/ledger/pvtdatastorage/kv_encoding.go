@@ -0,0 +1,261 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pvtdatastorage
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// data keys are encoded as <dataKeyPrefix><reverse-order-blkNum><big-endian-txNum><ns><nilByte><coll>
+// so that a range scan over a block returns entries in ascending txNum order within the block while newer
+// blocks sort ahead of older ones.
+var (
+	lastCommittedBlkkey     = []byte{0}
+	pendingCommitKey        = []byte{1}
+	lastUpdatedOldBlocksKey = []byte{2}
+	// dataFormatVersionKey stores the version of the on-disk key encoding used by this store.
+	// It is absent (or holds dataFormatV11) on stores created before the eligible/ineligible
+	// missing-data prefixes were split; OpenStore uses it to decide whether a migration is needed.
+	dataFormatVersionKey = []byte{3}
+)
+
+const (
+	dataKeyPrefix = byte(4)
+	expiryKeyPrefix = byte(5)
+	// elgMissingDataKeyPrefix and inelgMissingDataKeyPrefix used to be a single missingDataKeyPrefix
+	// with isEligible folded into the key body. They were split so that
+	// createRangeScanKeysForEligibleMissingDataEntries no longer has to filter out ineligible
+	// entries while iterating.
+	elgMissingDataKeyPrefix           = byte(6)
+	inelgMissingDataKeyPrefix         = byte(7)
+	collElgKeyPrefix                  = byte(8)
+	deprioritizedMissingDataKeyPrefix = byte(9)
+	// legacyMissingDataKeyPrefix is the single prefix used before the eligible/ineligible split;
+	// isEligible was folded into the key body as a trailing byte. OpenStore migrates any entries
+	// still under this prefix into elgMissingDataKeyPrefix/inelgMissingDataKeyPrefix.
+	legacyMissingDataKeyPrefix = byte(10)
+
+	nilByte = byte(0)
+)
+
+// dataFormat enumerates the on-disk key-encoding generations for this store.
+type dataFormat string
+
+const (
+	dataFormatV11      dataFormat = ""
+	dataFormatSplitElg dataFormat = "2.1"
+)
+
+func encodeDataKey(key *dataKey) []byte {
+	encKey := append([]byte{dataKeyPrefix}, encodeReverseOrderVarUint64(key.blkNum)...)
+	encKey = append(encKey, encodeReverseOrderVarUint64(^key.txNum)...)
+	encKey = append(encKey, []byte(key.ns)...)
+	encKey = append(encKey, nilByte)
+	return append(encKey, []byte(key.coll)...)
+}
+
+func decodeDatakey(datakeyBytes []byte) (*dataKey, error) {
+	blkNum, _, err := decodeReverseOrderVarUint64(datakeyBytes[1:])
+	if err != nil {
+		return nil, err
+	}
+	txNum, _, err := decodeReverseOrderVarUint64(datakeyBytes[9:])
+	if err != nil {
+		return nil, err
+	}
+	b := datakeyBytes[17:]
+	nsEndIndex := indexOfNilByte(b)
+	if nsEndIndex == len(b) {
+		return nil, errors.New("invalid data key: missing namespace terminator")
+	}
+	return &dataKey{nsCollBlk{string(b[:nsEndIndex]), string(b[nsEndIndex+1:]), blkNum}, ^txNum}, nil
+}
+
+func encodeExpiryKey(expiryKey *expiryKey) []byte {
+	encKey := append([]byte{expiryKeyPrefix}, encodeReverseOrderVarUint64(expiryKey.expiringBlk)...)
+	return append(encKey, encodeReverseOrderVarUint64(expiryKey.committingBlk)...)
+}
+
+func decodeExpiryKey(expiryKeyBytes []byte) (*expiryKey, error) {
+	expiringBlk, n, err := decodeReverseOrderVarUint64(expiryKeyBytes[1:])
+	if err != nil {
+		return nil, err
+	}
+	committingBlk, _, err := decodeReverseOrderVarUint64(expiryKeyBytes[1+n:])
+	if err != nil {
+		return nil, err
+	}
+	return &expiryKey{expiringBlk, committingBlk}, nil
+}
+
+func missingDataKeyPrefixFor(isEligible bool) byte {
+	if isEligible {
+		return elgMissingDataKeyPrefix
+	}
+	return inelgMissingDataKeyPrefix
+}
+
+func encodeMissingDataKey(key *missingDataKey) []byte {
+	encKey := append([]byte{missingDataKeyPrefixFor(key.isEligible)}, encodeNsCollBlk(key.nsCollBlk)...)
+	return encKey
+}
+
+func decodeMissingDataKey(keyBytes []byte) *missingDataKey {
+	isEligible := keyBytes[0] == elgMissingDataKeyPrefix
+	nsCollBlk, _, _ := decodeNsCollBlk(keyBytes[1:])
+	return &missingDataKey{nsCollBlk, isEligible}
+}
+
+func encodeNsCollBlk(key nsCollBlk) []byte {
+	encKey := append([]byte(key.ns), nilByte)
+	encKey = append(encKey, []byte(key.coll)...)
+	encKey = append(encKey, nilByte)
+	return append(encKey, encodeReverseOrderVarUint64(key.blkNum)...)
+}
+
+func decodeNsCollBlk(b []byte) (nsCollBlk, []byte, error) {
+	nsEndIndex := indexOfNilByte(b)
+	ns := string(b[:nsEndIndex])
+	b = b[nsEndIndex+1:]
+	collEndIndex := indexOfNilByte(b)
+	coll := string(b[:collEndIndex])
+	b = b[collEndIndex+1:]
+	blkNum, n, err := decodeReverseOrderVarUint64(b)
+	if err != nil {
+		return nsCollBlk{}, nil, err
+	}
+	return nsCollBlk{ns, coll, blkNum}, b[n:], nil
+}
+
+func createRangeScanKeysForEligibleMissingDataEntries(blkNum uint64) ([]byte, []byte) {
+	startKey := []byte{elgMissingDataKeyPrefix}
+	endKey := []byte{elgMissingDataKeyPrefix + 1}
+	return startKey, endKey
+}
+
+func createRangeScanKeysForIneligibleMissingData(committingBlk uint64, ns, coll string) ([]byte, []byte) {
+	nsCollPrefix := append([]byte{inelgMissingDataKeyPrefix}, []byte(ns)...)
+	nsCollPrefix = append(nsCollPrefix, nilByte)
+	nsCollPrefix = append(nsCollPrefix, []byte(coll)...)
+	nsCollPrefix = append(nsCollPrefix, nilByte)
+	// blkNum is encoded so that larger block numbers sort first; scanning
+	// from genesis covers every collection-eligibility-enablement event up to
+	// and including committingBlk.
+	startKey := append(nsCollPrefix, encodeReverseOrderVarUint64(committingBlk)...)
+	endKey := append(nsCollPrefix, encodeReverseOrderVarUint64(0)...)
+	return startKey, endKey
+}
+
+func createRangeScanKeysForDeprioritizedMissingDataEntries() ([]byte, []byte) {
+	startKey := []byte{deprioritizedMissingDataKeyPrefix}
+	endKey := []byte{deprioritizedMissingDataKeyPrefix + 1}
+	return startKey, endKey
+}
+
+func encodeDeprioritizedMissingDataKey(key *missingDataKey) []byte {
+	return append([]byte{deprioritizedMissingDataKeyPrefix}, encodeNsCollBlk(key.nsCollBlk)...)
+}
+
+func decodeDeprioritizedMissingDataKey(keyBytes []byte) *missingDataKey {
+	nsCollBlk, _, _ := decodeNsCollBlk(keyBytes[1:])
+	return &missingDataKey{nsCollBlk, true}
+}
+
+func createRangeScanKeysForLegacyMissingDataEntries() ([]byte, []byte) {
+	return []byte{legacyMissingDataKeyPrefix}, []byte{legacyMissingDataKeyPrefix + 1}
+}
+
+// decodeLegacyMissingDataKey decodes a key written under the pre-split format, where isEligible
+// was appended as a single trailing byte after the nsCollBlk encoding.
+func decodeLegacyMissingDataKey(keyBytes []byte) *missingDataKey {
+	nsCollBlk, rest, _ := decodeNsCollBlk(keyBytes[1:])
+	isEligible := len(rest) > 0 && rest[0] == 1
+	return &missingDataKey{nsCollBlk: nsCollBlk, isEligible: isEligible}
+}
+
+func indexOfNilByte(b []byte) int {
+	for i, c := range b {
+		if c == nilByte {
+			return i
+		}
+	}
+	return len(b)
+}
+
+func getDataKeysForRangeScanByBlockNum(blkNum uint64) ([]byte, []byte) {
+	startKey := append([]byte{dataKeyPrefix}, encodeReverseOrderVarUint64(blkNum)...)
+	if blkNum == 0 {
+		// block 0 sorts last, so its range runs to the end of the data keys
+		return startKey, []byte{dataKeyPrefix + 1}
+	}
+	endKey := append([]byte{dataKeyPrefix}, encodeReverseOrderVarUint64(blkNum-1)...)
+	return startKey, endKey
+}
+
+// getDataKeysForRangeScanAboveBlockNum returns the range covering every data key for a block
+// number strictly greater than blkNum. Since blkNum is encoded in reverse order, the larger block
+// numbers this covers sort ahead of (i.e., before) blkNum's own entries.
+func getDataKeysForRangeScanAboveBlockNum(blkNum uint64) ([]byte, []byte) {
+	startKey := []byte{dataKeyPrefix}
+	endKey := append([]byte{dataKeyPrefix}, encodeReverseOrderVarUint64(blkNum)...)
+	return startKey, endKey
+}
+
+func getExpiryKeysForRangeScan(minBlkNum, maxBlkNum uint64) ([]byte, []byte) {
+	startKey := append([]byte{expiryKeyPrefix}, encodeReverseOrderVarUint64(maxBlkNum)...)
+	if minBlkNum == 0 {
+		// expiring block 0 sorts last, so the range runs to the end of the expiry keys
+		return startKey, []byte{expiryKeyPrefix + 1}
+	}
+	endKey := append([]byte{expiryKeyPrefix}, encodeReverseOrderVarUint64(minBlkNum-1)...)
+	return startKey, endKey
+}
+
+func createRangeScanKeysForCollElg() ([]byte, []byte) {
+	return []byte{collElgKeyPrefix}, []byte{collElgKeyPrefix + 1}
+}
+
+func encodeCollElgKey(blkNum uint64) []byte {
+	return append([]byte{collElgKeyPrefix}, encodeReverseOrderVarUint64(blkNum)...)
+}
+
+func decodeCollElgKey(b []byte) uint64 {
+	blkNum, _, _ := decodeReverseOrderVarUint64(b[1:])
+	return blkNum
+}
+
+func encodeLastCommittedBlockVal(blockNum uint64) []byte {
+	return proto.EncodeVarint(blockNum)
+}
+
+func decodeLastCommittedBlockVal(blockNumBytes []byte) uint64 {
+	blkNum, _ := proto.DecodeVarint(blockNumBytes)
+	return blkNum
+}
+
+// decodeLastCommittedBlockValWithLen is like decodeLastCommittedBlockVal but also reports how
+// many leading bytes of b were consumed by the varint, so a caller that appended more fields
+// after the encoded block number (e.g. ExportPvtDataSnapshot's metadata file) can locate them.
+func decodeLastCommittedBlockValWithLen(b []byte) (uint64, int) {
+	return proto.DecodeVarint(b)
+}
+
+func encodeReverseOrderVarUint64(number uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, ^number)
+	return b
+}
+
+func decodeReverseOrderVarUint64(bytes []byte) (uint64, int, error) {
+	if len(bytes) < 8 {
+		return 0, 0, errors.New("invalid reverse-order-uint64 encoding")
+	}
+	return ^binary.BigEndian.Uint64(bytes[:8]), 8, nil
+}
@@ -0,0 +1,273 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pvtdatastorage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// purgeSchedule decides when a purge sweep over expired private data should run. Store
+// consults it once per committed block; newPurgeSchedule picks the implementation based on
+// PrivateDataConfig.
+type purgeSchedule interface {
+	// dueAt is called after every commit and reports whether a purge sweep covering blocks up
+	// to committedBlk should be triggered now.
+	dueAt(committedBlk uint64, committedBytes int) bool
+}
+
+// newPurgeSchedule selects a purgeSchedule from conf: PurgeWallClockInterval takes precedence
+// over PurgeSizeThresholdBytes, which in turn takes precedence over the long-standing
+// block-count-based PurgeInterval.
+func newPurgeSchedule(conf *PrivateDataConfig) purgeSchedule {
+	switch {
+	case conf.PurgeWallClockInterval > 0:
+		return &wallClockSchedule{interval: conf.PurgeWallClockInterval}
+	case conf.PurgeSizeThresholdBytes > 0:
+		return &sizeTriggeredSchedule{thresholdBytes: conf.PurgeSizeThresholdBytes}
+	default:
+		return &blockIntervalSchedule{interval: uint64(conf.PurgeInterval)}
+	}
+}
+
+// blockIntervalSchedule purges every `interval` committed blocks.
+type blockIntervalSchedule struct {
+	interval uint64
+}
+
+func (b *blockIntervalSchedule) dueAt(committedBlk uint64, _ int) bool {
+	return b.interval != 0 && committedBlk%b.interval == 0
+}
+
+// wallClockSchedule purges at most once per configured duration, independent of block cadence -
+// useful on channels whose commit rate is too low for a block-count interval to ever fire.
+type wallClockSchedule struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (w *wallClockSchedule) dueAt(_ uint64, _ int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if time.Since(w.last) < w.interval {
+		return false
+	}
+	w.last = time.Now()
+	return true
+}
+
+// sizeTriggeredSchedule purges once the cumulative size of pvtdata committed since the last
+// sweep crosses thresholdBytes, so write-heavy channels don't build up unbounded expired data
+// between widely-spaced block-interval triggers.
+type sizeTriggeredSchedule struct {
+	thresholdBytes int64
+	accumulated    int64
+}
+
+func (s *sizeTriggeredSchedule) dueAt(_ uint64, committedBytes int) bool {
+	s.accumulated += int64(committedBytes)
+	if s.accumulated < s.thresholdBytes {
+		return false
+	}
+	s.accumulated = 0
+	return true
+}
+
+// purgeStats holds the purger's Prometheus-style counters and gauges, updated by the purger
+// goroutine and read concurrently via atomic loads from PurgeStats.
+type purgeStats struct {
+	entriesPurged            uint64 // counter
+	bytesReclaimed           uint64 // counter
+	lastPurgeDurationNanos   int64  // gauge
+	lastSuccessfulPurgeBlock uint64 // gauge
+}
+
+func (s *purgeStats) snapshot() PurgeStats {
+	return PurgeStats{
+		EntriesPurged:            atomic.LoadUint64(&s.entriesPurged),
+		BytesReclaimed:           atomic.LoadUint64(&s.bytesReclaimed),
+		LastPurgeDuration:        time.Duration(atomic.LoadInt64(&s.lastPurgeDurationNanos)),
+		LastSuccessfulPurgeBlock: atomic.LoadUint64(&s.lastSuccessfulPurgeBlock),
+	}
+}
+
+// PurgeStats is a point-in-time snapshot of the purger's metrics, returned by Store.PurgeStats.
+type PurgeStats struct {
+	EntriesPurged            uint64
+	BytesReclaimed           uint64
+	LastPurgeDuration        time.Duration
+	LastSuccessfulPurgeBlock uint64
+}
+
+// purgeRequest asks the purger goroutine to sweep expired entries up to uptoBlk. done, if
+// non-nil, receives the outcome of that one sweep; schedule-triggered requests leave it nil and
+// instead surface a failure on purger.errs.
+type purgeRequest struct {
+	uptoBlk uint64
+	done    chan error
+}
+
+// purger is the supervised worker behind Store's purge sweeps, replacing the old fire-and-
+// forget goroutine spawned straight out of Commit. Every request - scheduled or admin-triggered
+// via Store.PurgeNow - runs on a single long-lived goroutine, so sweeps never overlap and a
+// scheduled sweep's error is never silently dropped.
+type purger struct {
+	store    *Store
+	schedule purgeSchedule
+	stats    purgeStats
+
+	requests chan purgeRequest
+	// errs surfaces errors from schedule-triggered sweeps; buffered so the purger goroutine
+	// never blocks on a caller that isn't listening. Store.PurgeErrors exposes it.
+	errs chan error
+}
+
+func newPurger(s *Store, schedule purgeSchedule) *purger {
+	return &purger{
+		store:    s,
+		schedule: schedule,
+		requests: make(chan purgeRequest, 1),
+		errs:     make(chan error, 1),
+	}
+}
+
+// start launches the purger's worker goroutine. It runs for the lifetime of the store.
+func (p *purger) start() {
+	go func() {
+		for req := range p.requests {
+			err := p.run(req.uptoBlk)
+			if req.done != nil {
+				req.done <- err
+				continue
+			}
+			if err != nil {
+				logger.Warningf("[%s] Scheduled purge up to block [%d] failed: %s", p.store.ledgerid, req.uptoBlk, err)
+				select {
+				case p.errs <- err:
+				default:
+				}
+			}
+		}
+	}()
+}
+
+// scheduleIfDue enqueues a background purge sweep if the configured schedule says one is due.
+// It never blocks: if a sweep is already queued (not just running), this commit's trigger is dropped
+// and the next due commit will pick up the same lower bound.
+func (p *purger) scheduleIfDue(committedBlk uint64, committedBytes int) {
+	if !p.schedule.dueAt(committedBlk, committedBytes) {
+		return
+	}
+	select {
+	case p.requests <- purgeRequest{uptoBlk: committedBlk}:
+	default:
+		logger.Debugf("[%s] Purge already pending, skipping trigger for block [%d]", p.store.ledgerid, committedBlk)
+	}
+}
+
+// now synchronously sweeps expired entries up to uptoBlk and waits for the result. It is used
+// by Store.PurgeNow for admin-triggered, on-demand purges.
+func (p *purger) now(uptoBlk uint64) error {
+	done := make(chan error, 1)
+	p.requests <- purgeRequest{uptoBlk: uptoBlk, done: done}
+	return <-done
+}
+
+// run performs one purge sweep over expiry entries in [0, uptoBlk], flushing its delete batch
+// each time it grows past store.maxBatchSize entries and sleeping store.batchesInterval
+// milliseconds after each such flush - the same throttling processCollElgEvents uses - so a large purge cannot starve
+// block commit. Unlike the old purgeExpiredData, entries are deleted straight off the iterator
+// instead of first being materialized into a slice, keeping memory bounded regardless of how
+// many entries have expired between minBlkNum and maxBlkNum.
+func (p *purger) run(uptoBlk uint64) error {
+	start := time.Now()
+	s := p.store
+	s.purgerLock.Lock()
+	defer s.purgerLock.Unlock()
+
+	logger.Debugf("[%s] Purger: sweeping expired private data up to block number [%d]", s.ledgerid, uptoBlk)
+	startKey, endKey := getExpiryKeysForRangeScan(0, uptoBlk)
+	itr := s.db.GetIterator(startKey, endKey)
+	defer itr.Release()
+
+	batch := s.db.NewUpdateBatch()
+	var entriesInBatch, entriesTotal int
+	var bytesInBatch, bytesTotal uint64
+
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		if err := s.writeBatch(batch, false); err != nil {
+			return err
+		}
+		entriesTotal += entriesInBatch
+		bytesTotal += bytesInBatch
+		batch = s.db.NewUpdateBatch()
+		entriesInBatch, bytesInBatch = 0, 0
+		return nil
+	}
+
+	for itr.Next() {
+		expiryKeyBytes, expiryValueBytes := itr.Key(), itr.Value()
+		expiryKey, err := decodeExpiryKey(expiryKeyBytes)
+		if err != nil {
+			return err
+		}
+		expiryValue, err := decodeExpiryValue(expiryValueBytes)
+		if err != nil {
+			return err
+		}
+		entry := &expiryEntry{key: expiryKey, value: expiryValue}
+
+		batch.Delete(expiryKeyBytes)
+		bytesInBatch += uint64(len(expiryKeyBytes) + len(expiryValueBytes))
+		dataKeys, missingDataKeys := deriveKeys(entry)
+		for _, dataKey := range dataKeys {
+			k := encodeDataKey(dataKey)
+			batch.Delete(k)
+			bytesInBatch += uint64(len(k))
+		}
+		for _, missingDataKey := range missingDataKeys {
+			k := encodeMissingDataKey(missingDataKey)
+			batch.Delete(k)
+			bytesInBatch += uint64(len(k))
+			// the deprioritized index only ever holds a copy of an eligible missing-data
+			// entry (see addToDeprioritizedList), so only those need a matching delete here;
+			// without this, a <ns, coll, blk> that was deprioritized and then expired by BTL
+			// before ever being reconciled would keep its deprioritized copy forever.
+			if missingDataKey.isEligible {
+				s.removeFromDeprioritizedList(batch, missingDataKey.ns, missingDataKey.coll, missingDataKey.blkNum)
+			}
+		}
+		entriesInBatch++
+
+		if batch.Len() > s.maxBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			sleepTime := time.Duration(s.batchesInterval)
+			s.purgerLock.Unlock()
+			time.Sleep(sleepTime * time.Millisecond)
+			s.purgerLock.Lock()
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&p.stats.entriesPurged, uint64(entriesTotal))
+	atomic.AddUint64(&p.stats.bytesReclaimed, bytesTotal)
+	atomic.StoreInt64(&p.stats.lastPurgeDurationNanos, int64(time.Since(start)))
+	atomic.StoreUint64(&p.stats.lastSuccessfulPurgeBlock, uptoBlk)
+	logger.Infof("[%s] Purger: purged [%d] entries ([%d] bytes reclaimed) from private data storage up to block number [%d]",
+		s.ledgerid, entriesTotal, bytesTotal, uptoBlk)
+	return nil
+}
@@ -0,0 +1,335 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pvtdatastorage
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/willf/bitset"
+)
+
+// reconciliationCheckpointKey records the highest old block number of the last chunk that
+// CommitPvtDataOfOldBlocks committed. It is written with each chunk's batch; nothing in this
+// package reads it back. A committed chunk has already cleared its entries' missing-data bits,
+// so reconciliation after a crash is not handed that chunk's pvtdata again.
+var reconciliationCheckpointKey = []byte{12}
+
+// CommitPvtDataOfOldBlocks commits the pvtData (i.e., previously missing data) of old blocks.
+// The parameter `blocksPvtData` refers a list of old block's pvtdata which are missing in the pvtstore.
+// The parameter `unreconciledMissingData` carries the missing data that gossip was still unable to
+// find anywhere on this reconciliation pass; it is recorded under a deprioritized key range so that
+// subsequent reconciliation cycles do not keep retrying it on every invocation.
+//
+// Rather than buffering the whole (potentially very large) reconciled map into a single LevelDB
+// batch, blocksPvtData is processed in maxBatchSize-bounded chunks, each written and checkpointed
+// as its own batch while purgerLock is held for the duration. This bounds peak memory during a
+// large reconciliation run and lets a peer that crashes mid-reconciliation resume from the last
+// committed chunk instead of redoing the whole thing.
+func (s *Store) CommitPvtDataOfOldBlocks(blocksPvtData map[uint64][]*ledger.TxPvtData, unreconciledMissingData ledger.MissingPvtDataInfo) error {
+	if s.isLastUpdatedOldBlocksSet {
+		return &ErrIllegalCall{`The lastUpdatedOldBlocksList is set. It means that the
+		stateDB may not be in sync with the pvtStore`}
+	}
+
+	s.purgerLock.Lock()
+	defer s.purgerLock.Unlock()
+
+	blockNums := make([]uint64, 0, len(blocksPvtData))
+	for blkNum := range blocksPvtData {
+		blockNums = append(blockNums, blkNum)
+	}
+	sort.Slice(blockNums, func(i, j int) bool { return blockNums[i] < blockNums[j] })
+
+	for chunkStart := 0; chunkStart < len(blockNums); chunkStart += s.maxBatchSize {
+		chunkEnd := chunkStart + s.maxBatchSize
+		if chunkEnd > len(blockNums) {
+			chunkEnd = len(blockNums)
+		}
+		chunkBlockNums := blockNums[chunkStart:chunkEnd]
+		chunk := make(map[uint64][]*ledger.TxPvtData, len(chunkBlockNums))
+		for _, blkNum := range chunkBlockNums {
+			chunk[blkNum] = blocksPvtData[blkNum]
+		}
+
+		// the unreconciled (still-missing) data is only meaningful once every requested block
+		// has been attempted, so it is only recorded together with the last chunk
+		var chunkUnreconciled ledger.MissingPvtDataInfo
+		if chunkEnd == len(blockNums) {
+			chunkUnreconciled = unreconciledMissingData
+		}
+
+		logger.Debugf("Committing chunk of [%d] old blocks' pvtdata, up to block [%d]", len(chunk), chunkBlockNums[len(chunkBlockNums)-1])
+		if err := s.commitPvtDataOfOldBlocksChunk(chunk, chunkUnreconciled, chunkBlockNums[len(chunkBlockNums)-1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commitPvtDataOfOldBlocksChunk performs the following operations for a single bounded chunk of
+// old blocks' pvtdata and commits them, along with a reconciliation checkpoint, as one batch:
+// (1) construct dataEntries for all pvtData
+// (2) construct update entries (i.e., dataEntries, expiryEntries, missingDataEntries)
+//     from the above created data entries
+// (3) create a db update batch from the update entries
+// (4) commit the update batch to the pvtStore
+func (s *Store) commitPvtDataOfOldBlocksChunk(blocksPvtData map[uint64][]*ledger.TxPvtData, unreconciledMissingData ledger.MissingPvtDataInfo, highestBlockInChunk uint64) error {
+	// (1) construct dataEntries for all pvtData
+	dataEntries := constructDataEntriesFromBlocksPvtData(blocksPvtData)
+
+	// (2) construct update entries (i.e., dataEntries, expiryEntries, missingDataEntries) from the above created data entries
+	logger.Debugf("Constructing pvtdatastore entries for pvtData of [%d] old blocks", len(blocksPvtData))
+	updateEntries, err := s.constructUpdateEntriesFromDataEntries(dataEntries)
+	if err != nil {
+		return err
+	}
+
+	// (3) create a db update batch from the update entries
+	logger.Debug("Constructing update batch from pvtdatastore entries")
+	batch, err := s.constructUpdateBatchFromUpdateEntries(updateEntries)
+	if err != nil {
+		return err
+	}
+
+	// pvtdata that just got reconciled is no longer deprioritized, and
+	// pvtdata that gossip still could not find anywhere gets deprioritized
+	// so that future reconciliation cycles do not keep retrying it every time
+	for dataKey := range updateEntries.dataEntries {
+		s.removeFromDeprioritizedList(batch, dataKey.ns, dataKey.coll, dataKey.blkNum)
+	}
+	if err := s.addToDeprioritizedList(batch, unreconciledMissingData); err != nil {
+		return err
+	}
+
+	// notify the registered consumer of the BTL expiry schedule for the newly reconciled entries
+	// before the batch is written, so that if the consumer rejects the update (e.g. the stateDB
+	// purge manager cannot persist it), the pvtdatastore commit is also aborted and the two stay
+	// in sync.
+	schedule, err := s.expirySchedule(dataEntries)
+	if err != nil {
+		return err
+	}
+	if err := s.expirySchedulesConsumer.UpdateExpirySchedule(schedule); err != nil {
+		return err
+	}
+
+	batch.Put(reconciliationCheckpointKey, encodeLastCommittedBlockVal(highestBlockInChunk))
+
+	// (4) commit the update batch to the pvtStore
+	logger.Debug("Committing the update batch to pvtdatastore")
+	if err := s.commitBatch(batch); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func constructDataEntriesFromBlocksPvtData(blocksPvtData map[uint64][]*ledger.TxPvtData) []*dataEntry {
+	// construct dataEntries for all pvtData
+	var dataEntries []*dataEntry
+	for blkNum, pvtData := range blocksPvtData {
+		// prepare the dataEntries for the pvtData
+		dataEntries = append(dataEntries, prepareDataEntries(blkNum, pvtData)...)
+	}
+	return dataEntries
+}
+
+func (s *Store) constructUpdateEntriesFromDataEntries(dataEntries []*dataEntry) (*entriesForPvtDataOfOldBlocks, error) {
+	updateEntries := &entriesForPvtDataOfOldBlocks{
+		dataEntries:        make(map[dataKey]*rwset.CollectionPvtReadWriteSet),
+		expiryEntries:      make(map[expiryKey]*ExpiryData),
+		missingDataEntries: make(map[nsCollBlk]*bitset.BitSet)}
+
+	// for each data entry, first, get the expiryData and missingData from the pvtStore.
+	// Second, update the expiryData and missingData as per the data entry. Finally, add
+	// the data entry along with the updated expiryData and missingData to the update entries
+	for _, dataEntry := range dataEntries {
+		// get the expiryBlk number to construct the expiryKey
+		expiryKey, err := s.constructExpiryKeyFromDataEntry(dataEntry)
+		if err != nil {
+			return nil, err
+		}
+
+		// get the existing expiryData entry
+		var expiryData *ExpiryData
+		if !neverExpires(expiryKey.expiringBlk) {
+			if expiryData, err = s.getExpiryDataFromUpdateEntriesOrStore(updateEntries, expiryKey); err != nil {
+				return nil, err
+			}
+			if expiryData == nil {
+				// data entry is already expired
+				// and purged (a rare scenario)
+				continue
+			}
+		}
+
+		// get the existing missingData entry
+		var missingData *bitset.BitSet
+		nsCollBlk := dataEntry.key.nsCollBlk
+		if missingData, err = s.getMissingDataFromUpdateEntriesOrStore(updateEntries, nsCollBlk); err != nil {
+			return nil, err
+		}
+		if missingData == nil {
+			// data entry is already expired
+			// and purged (a rare scenario)
+			continue
+		}
+
+		updateEntries.addDataEntry(dataEntry)
+		if expiryData != nil { // would be nil for the never expiring entry
+			expiryEntry := &expiryEntry{&expiryKey, expiryData}
+			updateEntries.updateAndAddExpiryEntry(expiryEntry, dataEntry.key)
+		}
+		updateEntries.updateAndAddMissingDataEntry(missingData, dataEntry.key)
+	}
+	return updateEntries, nil
+}
+
+func (s *Store) constructExpiryKeyFromDataEntry(dataEntry *dataEntry) (expiryKey, error) {
+	// get the expiryBlk number to construct the expiryKey
+	nsCollBlk := dataEntry.key.nsCollBlk
+	expiringBlk, err := s.btlPolicy.GetExpiringBlock(nsCollBlk.ns, nsCollBlk.coll, nsCollBlk.blkNum)
+	if err != nil {
+		return expiryKey{}, err
+	}
+	return expiryKey{expiringBlk, nsCollBlk.blkNum}, nil
+}
+
+func (s *Store) getExpiryDataFromUpdateEntriesOrStore(updateEntries *entriesForPvtDataOfOldBlocks, expiryKey expiryKey) (*ExpiryData, error) {
+	expiryData, ok := updateEntries.expiryEntries[expiryKey]
+	if !ok {
+		var err error
+		expiryData, err = s.getExpiryDataOfExpiryKey(&expiryKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return expiryData, nil
+}
+
+func (s *Store) getMissingDataFromUpdateEntriesOrStore(updateEntries *entriesForPvtDataOfOldBlocks, nsCollBlk nsCollBlk) (*bitset.BitSet, error) {
+	missingData, ok := updateEntries.missingDataEntries[nsCollBlk]
+	if !ok {
+		var err error
+		missingDataKey := &missingDataKey{nsCollBlk, true}
+		missingData, err = s.getBitmapOfMissingDataKey(missingDataKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return missingData, nil
+}
+
+func (updateEntries *entriesForPvtDataOfOldBlocks) addDataEntry(dataEntry *dataEntry) {
+	dataKey := dataKey{dataEntry.key.nsCollBlk, dataEntry.key.txNum}
+	updateEntries.dataEntries[dataKey] = dataEntry.value
+}
+
+func (updateEntries *entriesForPvtDataOfOldBlocks) updateAndAddExpiryEntry(expiryEntry *expiryEntry, dataKey *dataKey) {
+	txNum := dataKey.txNum
+	nsCollBlk := dataKey.nsCollBlk
+	// update
+	expiryEntry.value.addPresentData(nsCollBlk.ns, nsCollBlk.coll, txNum)
+	// we cannot delete entries from MissingDataMap as
+	// we keep only one entry per missing <ns-col>
+	// irrespective of the number of txNum.
+
+	// add
+	expiryKey := expiryKey{expiryEntry.key.expiringBlk, expiryEntry.key.committingBlk}
+	updateEntries.expiryEntries[expiryKey] = expiryEntry.value
+}
+
+func (updateEntries *entriesForPvtDataOfOldBlocks) updateAndAddMissingDataEntry(missingData *bitset.BitSet, dataKey *dataKey) {
+
+	txNum := dataKey.txNum
+	nsCollBlk := dataKey.nsCollBlk
+	// update
+	missingData.Clear(uint(txNum))
+	// add
+	updateEntries.missingDataEntries[nsCollBlk] = missingData
+}
+
+func (s *Store) constructUpdateBatchFromUpdateEntries(updateEntries *entriesForPvtDataOfOldBlocks) (*leveldbhelper.UpdateBatch, error) {
+	batch := s.db.NewUpdateBatch()
+
+	// add the following three types of entries to the update batch: (1) new data entries
+	// (i.e., pvtData), (2) updated expiry entries, and (3) updated missing data entries
+
+	// (1) add new data entries to the batch
+	if err := addNewDataEntriesToUpdateBatch(batch, updateEntries); err != nil {
+		return nil, err
+	}
+
+	// (2) add updated expiryEntry to the batch
+	if err := addUpdatedExpiryEntriesToUpdateBatch(batch, updateEntries); err != nil {
+		return nil, err
+	}
+
+	// (3) add updated missingData to the batch
+	if err := addUpdatedMissingDataEntriesToUpdateBatch(batch, updateEntries); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+func addNewDataEntriesToUpdateBatch(batch *leveldbhelper.UpdateBatch, entries *entriesForPvtDataOfOldBlocks) error {
+	var keyBytes, valBytes []byte
+	var err error
+	for dataKey, pvtData := range entries.dataEntries {
+		keyBytes = encodeDataKey(&dataKey)
+		if valBytes, err = encodeDataValue(pvtData); err != nil {
+			return err
+		}
+		batch.Put(keyBytes, valBytes)
+	}
+	return nil
+}
+
+func addUpdatedExpiryEntriesToUpdateBatch(batch *leveldbhelper.UpdateBatch, entries *entriesForPvtDataOfOldBlocks) error {
+	var keyBytes, valBytes []byte
+	var err error
+	for expiryKey, expiryData := range entries.expiryEntries {
+		keyBytes = encodeExpiryKey(&expiryKey)
+		if valBytes, err = encodeExpiryValue(expiryData); err != nil {
+			return err
+		}
+		batch.Put(keyBytes, valBytes)
+	}
+	return nil
+}
+
+func addUpdatedMissingDataEntriesToUpdateBatch(batch *leveldbhelper.UpdateBatch, entries *entriesForPvtDataOfOldBlocks) error {
+	var keyBytes, valBytes []byte
+	var err error
+	for nsCollBlk, missingData := range entries.missingDataEntries {
+		keyBytes = encodeMissingDataKey(&missingDataKey{nsCollBlk, true})
+		// if the missingData is empty, we need to delete the missingDataKey
+		if missingData.None() {
+			batch.Delete(keyBytes)
+			continue
+		}
+		if valBytes, err = encodeMissingDataValue(missingData); err != nil {
+			return err
+		}
+		batch.Put(keyBytes, valBytes)
+	}
+	return nil
+}
+
+func (s *Store) commitBatch(batch *leveldbhelper.UpdateBatch) error {
+	// commit the batch to the store
+	if err := s.writeBatch(batch, true); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pvtdatastorage
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+)
+
+// Rollback permanently discards all private data, expiry schedules, and missing-data (and
+// deprioritized-missing-data, and collection-eligibility) bookkeeping committed for blocks after
+// maxBlockNumAllowed, then rewinds the store's last-committed-block marker to maxBlockNumAllowed.
+// It is the pvtdatastorage half of a ledger-wide rollback: the caller is responsible for
+// performing the equivalent rewind on every other sub-store (blockfile, stateDB, historyDB)
+// before the ledger is reopened, and for ensuring no other goroutine is using this Store
+// concurrently - unlike Commit, Rollback is not safe to call against a live store.
+func (s *Store) Rollback(maxBlockNumAllowed uint64) error {
+	lastCommittedBlockHt, err := s.LastCommittedBlockHeight()
+	if err != nil {
+		return err
+	}
+	if lastCommittedBlockHt == 0 {
+		return &ErrIllegalArgs{fmt.Sprintf("The pvtdata store for ledger [%s] is empty; nothing to roll back", s.ledgerid)}
+	}
+	if maxBlockNumAllowed >= lastCommittedBlockHt-1 {
+		return &ErrIllegalArgs{fmt.Sprintf(
+			"Requested rollback target block number [%d] is not less than the last committed block number [%d] for ledger [%s]",
+			maxBlockNumAllowed, lastCommittedBlockHt-1, s.ledgerid,
+		)}
+	}
+
+	batch := s.db.NewUpdateBatch()
+
+	dataStartKey, dataEndKey := getDataKeysForRangeScanAboveBlockNum(maxBlockNumAllowed)
+	if err := s.deleteRange(batch, dataStartKey, dataEndKey); err != nil {
+		return err
+	}
+
+	if err := s.deleteAboveBlockNum(batch, []byte{expiryKeyPrefix}, []byte{expiryKeyPrefix + 1}, maxBlockNumAllowed,
+		func(key []byte) (uint64, error) {
+			k, err := decodeExpiryKey(key)
+			if err != nil {
+				return 0, err
+			}
+			return k.committingBlk, nil
+		},
+	); err != nil {
+		return err
+	}
+
+	if err := s.deleteAboveBlockNum(batch, []byte{elgMissingDataKeyPrefix}, []byte{elgMissingDataKeyPrefix + 1}, maxBlockNumAllowed,
+		func(key []byte) (uint64, error) { return decodeMissingDataKey(key).blkNum, nil },
+	); err != nil {
+		return err
+	}
+	if err := s.deleteAboveBlockNum(batch, []byte{inelgMissingDataKeyPrefix}, []byte{inelgMissingDataKeyPrefix + 1}, maxBlockNumAllowed,
+		func(key []byte) (uint64, error) { return decodeMissingDataKey(key).blkNum, nil },
+	); err != nil {
+		return err
+	}
+	if err := s.deleteAboveBlockNum(batch, []byte{deprioritizedMissingDataKeyPrefix}, []byte{deprioritizedMissingDataKeyPrefix + 1}, maxBlockNumAllowed,
+		func(key []byte) (uint64, error) { return decodeDeprioritizedMissingDataKey(key).blkNum, nil },
+	); err != nil {
+		return err
+	}
+	if err := s.deleteAboveBlockNum(batch, []byte{collElgKeyPrefix}, []byte{collElgKeyPrefix + 1}, maxBlockNumAllowed,
+		func(key []byte) (uint64, error) { return decodeCollElgKey(key), nil },
+	); err != nil {
+		return err
+	}
+
+	batch.Put(lastCommittedBlkkey, encodeLastCommittedBlockVal(maxBlockNumAllowed))
+	if err := s.writeBatch(batch, true); err != nil {
+		return err
+	}
+
+	s.isEmpty = false
+	atomic.StoreUint64(&s.lastCommittedBlock, maxBlockNumAllowed)
+	logger.Infof("[%s] Rolled back pvtdata store to block [%d]", s.ledgerid, maxBlockNumAllowed)
+	return nil
+}
+
+// deleteRange queues a Delete for every key within [startKey, endKey) into batch, without
+// inspecting the keys - used where the range itself already bounds exactly what should go.
+func (s *Store) deleteRange(batch *leveldbhelper.UpdateBatch, startKey, endKey []byte) error {
+	itr := s.db.GetIterator(startKey, endKey)
+	defer itr.Release()
+	for itr.Next() {
+		batch.Delete(append([]byte{}, itr.Key()...))
+	}
+	return nil
+}
+
+// deleteAboveBlockNum scans every key in [startKey, endKey), and queues a Delete into batch for
+// those whose blockNumOf, decoded from the key, is greater than maxBlockNumAllowed. Used for key
+// spaces (expiry, missing-data, deprioritized-missing-data, collection-eligibility) where the
+// committing block number isn't the leading, range-scannable component of the key.
+func (s *Store) deleteAboveBlockNum(batch *leveldbhelper.UpdateBatch, startKey, endKey []byte, maxBlockNumAllowed uint64, blockNumOf func(key []byte) (uint64, error)) error {
+	itr := s.db.GetIterator(startKey, endKey)
+	defer itr.Release()
+	for itr.Next() {
+		key := append([]byte{}, itr.Key()...)
+		blkNum, err := blockNumOf(key)
+		if err != nil {
+			return err
+		}
+		if blkNum > maxBlockNumAllowed {
+			batch.Delete(key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pvtdatastorage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/core/ledger/pvtdatapolicy"
+	"github.com/willf/bitset"
+)
+
+// maxBatchKVsBeforeFlush bounds how many key/values a SnapshotDataImporter buffers before
+// flushing to leveldb, keeping each flush to roughly 1-2MB for typical pvtdata row sizes.
+const maxBatchKVsBeforeFlush = 10000
+
+// membershipProvider answers whether this peer is currently eligible to receive pvtdata for a
+// given collection, i.e., whether it is a member of the collection's MSP-based access policy.
+type membershipProvider interface {
+	AmMemberOf(channelName string, ns, coll string) (bool, error)
+}
+
+// SnapshotDataImporter bootstraps the pvtdata store for a joining peer from the sorted rows of a
+// state snapshot, instead of requiring the peer to replay every block's pvtdata. Rows must be
+// fed in (ns, coll, keyHash, version) order; ConsumeSnapshotData looks up collection membership
+// once per run of rows with the same (ns, coll), while the BTL policy is consulted, and the
+// dataKey/missingDataKey/expiryEntry writes are made, for every row.
+type SnapshotDataImporter struct {
+	ledgerID   string
+	membership membershipProvider
+	btlPolicy  pvtdatapolicy.BTLPolicy
+	tempDir    string
+
+	store *Store
+	batch *leveldbhelper.UpdateBatch
+
+	currentNs, currentColl string
+	currentNsEligible      bool
+}
+
+// NewSnapshotDataImporter constructs a SnapshotDataImporter for ledgerID, creating tempDir if it
+// does not exist. The importer performs no sort of its own and never reads or writes tempDir;
+// rows that are not grouped by (ns, coll) are not regrouped here.
+func NewSnapshotDataImporter(ledgerID string, membership membershipProvider, btlPolicy pvtdatapolicy.BTLPolicy, tempDir string) (*SnapshotDataImporter, error) {
+	if err := os.MkdirAll(tempDir, 0o700); err != nil {
+		return nil, err
+	}
+	return &SnapshotDataImporter{
+		ledgerID:   ledgerID,
+		membership: membership,
+		btlPolicy:  btlPolicy,
+		tempDir:    tempDir,
+	}, nil
+}
+
+// bind associates this importer with the destination store. It must be called before
+// ConsumeSnapshotData; Store.NewSnapshotDataImporter (below) does this for callers.
+func (imp *SnapshotDataImporter) bind(store *Store) {
+	imp.store = store
+	imp.batch = store.db.NewUpdateBatch()
+}
+
+// ConsumeSnapshotData ingests a single (ns, coll, keyHash, version, value) row from a state
+// snapshot. Rows for the same (ns, coll) must arrive contiguously; a caller whose upstream rows
+// are not already grouped that way is expected to externally sort them through tempDir first.
+// blkNum is the block number the row's key/value was effective as of (i.e., the snapshot height).
+func (imp *SnapshotDataImporter) ConsumeSnapshotData(ns, coll string, keyHash, value []byte, blkNum uint64) error {
+	if imp.store == nil {
+		return &ErrIllegalCall{"SnapshotDataImporter is not bound to a store"}
+	}
+	if imp.currentNs != ns || imp.currentColl != coll {
+		eligible, err := imp.membership.AmMemberOf(imp.ledgerID, ns, coll)
+		if err != nil {
+			return err
+		}
+		imp.currentNs, imp.currentColl, imp.currentNsEligible = ns, coll, eligible
+	}
+
+	expiringBlk, err := imp.btlPolicy.GetExpiringBlock(ns, coll, blkNum)
+	if err != nil {
+		return err
+	}
+
+	mKey := &missingDataKey{nsCollBlk: nsCollBlk{ns: ns, coll: coll, blkNum: blkNum}, isEligible: imp.currentNsEligible}
+	if imp.currentNsEligible && value != nil {
+		// we have the actual pvtdata (not just its hash) for a collection we are a member of
+		dKey := &dataKey{nsCollBlk: mKey.nsCollBlk}
+		imp.batch.Put(encodeDataKey(dKey), value)
+	} else {
+		// either we are not eligible for this collection, or the snapshot only carries the
+		// hash of the value (hash-only bootstrap) -- record it as missing so that gossip
+		// reconciliation picks it up later if and when we become eligible. Snapshot rows carry
+		// no txNum, so the whole block is marked as missing via bit 0 of the bitmap.
+		bitmap := bitset.New(1).Set(0)
+		valBytes, err := encodeMissingDataValue(bitmap)
+		if err != nil {
+			return err
+		}
+		imp.batch.Put(encodeMissingDataKey(mKey), valBytes)
+	}
+
+	if !neverExpires(expiringBlk) {
+		expKey := &expiryKey{expiringBlk: expiringBlk, committingBlk: blkNum}
+		imp.batch.Put(encodeExpiryKey(expKey), []byte{1})
+	}
+
+	if imp.batch.Len() >= maxBatchKVsBeforeFlush {
+		if err := imp.store.writeBatch(imp.batch, true); err != nil {
+			return err
+		}
+		imp.batch = imp.store.db.NewUpdateBatch()
+	}
+	return nil
+}
+
+// Done flushes any buffered rows. It must be called exactly once after the last call to
+// ConsumeSnapshotData, and before Store.InitLastCommittedBlock.
+func (imp *SnapshotDataImporter) Done() error {
+	if imp.batch.Len() == 0 {
+		return nil
+	}
+	err := imp.store.writeBatch(imp.batch, true)
+	imp.batch = imp.store.db.NewUpdateBatch()
+	return err
+}
+
+// NewSnapshotDataImporter returns a SnapshotDataImporter bound to this store. The store must be
+// empty -- i.e. InitLastCommittedBlock has not yet been called -- until the importer's Done()
+// method has been invoked and InitLastCommittedBlock is called to unblock regular Commit calls.
+func (s *Store) NewSnapshotDataImporter(membership membershipProvider, tempDir string) (*SnapshotDataImporter, error) {
+	if !s.isEmpty {
+		return nil, fmt.Errorf("cannot bootstrap ledger [%s] from a snapshot: pvtdata store is not empty", s.ledgerid)
+	}
+	imp, err := NewSnapshotDataImporter(s.ledgerid, membership, s.btlPolicy, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	imp.bind(s)
+	s.snapshotImportInProgress = true
+	return imp, nil
+}
+
+// InitLastCommittedBlock marks the store as bootstrapped as of snapshotHeight-1, unblocking
+// regular Commit calls for snapshotHeight onwards. It must be called after the
+// SnapshotDataImporter used to populate the store has had Done() called on it; any Commit
+// attempted before that returns ErrIllegalCall.
+func (s *Store) InitLastCommittedBlock(snapshotHeight uint64) error {
+	if !s.isEmpty {
+		return &ErrIllegalCall{"The store is not empty, InitLastCommittedBlock can only be used to bootstrap an empty store"}
+	}
+	batch := s.db.NewUpdateBatch()
+	batch.Put(lastCommittedBlkkey, encodeLastCommittedBlockVal(snapshotHeight-1))
+	if err := s.writeBatch(batch, true); err != nil {
+		return err
+	}
+	s.isEmpty = false
+	s.lastCommittedBlock = snapshotHeight - 1
+	s.snapshotImportInProgress = false
+	return nil
+}
@@ -38,20 +38,59 @@ type PrivateDataConfig struct {
 	// It is internally computed by the ledger component,
 	// so it is not in ledger.PrivateDataConfig and not exposed to other components.
 	StorePath string
+	// DeprioritizedDataReconcilerInterval controls how often, in number of
+	// invocations of GetMissingPvtDataInfoForMostRecentBlocks, the store hands
+	// out missing data that was previously deprioritized instead of the
+	// regular prioritized missing data. With a value of 0,
+	// GetMissingPvtDataInfoForMostRecentBlocks always scans the prioritized list;
+	// CommitPvtDataOfOldBlocks still records unreconciled data in the
+	// deprioritized list and FetchBootKVHashes still reads it.
+	DeprioritizedDataReconcilerInterval int
+	// AutoRecoverOnCorruption, when true, makes the store attempt a one-shot
+	// Recover() the first time a Get or WriteBatch call fails because the
+	// underlying leveldb files are detected as corrupted, instead of
+	// propagating the error straight to the caller.
+	AutoRecoverOnCorruption bool
+	// PurgeWallClockInterval, when non-zero, makes the purger trigger a sweep at most once per
+	// this duration instead of every PurgeInterval committed blocks. Takes precedence over
+	// PurgeSizeThresholdBytes and PurgeInterval when set.
+	PurgeWallClockInterval time.Duration
+	// PurgeSizeThresholdBytes, when non-zero (and PurgeWallClockInterval is unset), makes the
+	// purger trigger a sweep once the cumulative size of pvtdata committed since the last sweep
+	// crosses this many bytes, instead of every PurgeInterval committed blocks.
+	PurgeSizeThresholdBytes int64
 }
 
 // Store manages the permanent storage of private write sets for a ledger
 type Store struct {
 	db              *leveldbhelper.DBHandle
+	dbProvider      *leveldbhelper.Provider
 	ledgerid        string
 	btlPolicy       pvtdatapolicy.BTLPolicy
 	batchesInterval int
 	maxBatchSize    int
-	purgeInterval   uint64
+
+	// autoRecoverOnCorruption mirrors PrivateDataConfig.AutoRecoverOnCorruption.
+	autoRecoverOnCorruption bool
+	// recovering guards Recover against re-entrant invocation: Recover replays
+	// processCollElgEvents and a purger sweep, and those must not trigger a
+	// nested recovery attempt if the corruption turns out to be unrecoverable.
+	recovering int32
+
+	// deprioritizedDataReconcilerInterval is the configured cadence (in number
+	// of calls to GetMissingPvtDataInfoForMostRecentBlocks) at which the
+	// deprioritized missing-data range is consulted instead of the prioritized
+	// one. 0 means deprioritization is disabled.
+	deprioritizedDataReconcilerInterval int
+	// reconciliationRequestCount is incremented on every call to
+	// GetMissingPvtDataInfoForMostRecentBlocks and used, modulo
+	// deprioritizedDataReconcilerInterval, to decide which range to scan.
+	reconciliationRequestCount uint64
 
 	isEmpty            bool
 	lastCommittedBlock uint64
 	purgerLock         sync.Mutex
+	purger             *purger
 	collElgProcSync    *collElgProcSync
 	// After committing the pvtdata of old blocks,
 	// the `isLastUpdatedOldBlocksSet` is set to true.
@@ -63,6 +102,35 @@ type Store struct {
 	// in the stateDB needs to be updated before finishing the
 	// recovery operation.
 	isLastUpdatedOldBlocksSet bool
+
+	// expirySchedulesConsumer is notified of the BTL expiry schedule of pvtdata entries
+	// committed via CommitPvtDataOfOldBlocks, so that e.g. the state DB purge manager can
+	// learn to purge them at the right block even though they were reconciled late.
+	expirySchedulesConsumer ExpirySchedulesConsumer
+
+	// snapshotImportInProgress is true from the moment a SnapshotDataImporter is bound to this
+	// store until InitLastCommittedBlock is called, and causes Commit to be rejected in the
+	// meantime so regular block processing cannot race with the bootstrap.
+	snapshotImportInProgress bool
+}
+
+// PvtdataKey identifies a single pvtdata entry for the purpose of scheduling its BTL expiry.
+type PvtdataKey struct {
+	Namespace, Collection string
+	BlockNum, TxNum       uint64
+}
+
+// ExpirySchedulesConsumer is notified, as part of the CommitPvtDataOfOldBlocks commit path, of
+// the block number at which each newly committed pvtdata entry is due to expire under the
+// collection's BTL policy.
+type ExpirySchedulesConsumer interface {
+	UpdateExpirySchedule(schedule map[PvtdataKey]uint64) error
+}
+
+type noopExpirySchedulesConsumer struct{}
+
+func (noopExpirySchedulesConsumer) UpdateExpirySchedule(map[PvtdataKey]uint64) error {
+	return nil
 }
 
 type blkTranNumKey []byte
@@ -135,20 +203,25 @@ func NewProvider(conf *PrivateDataConfig) (*Provider, error) {
 func (p *Provider) OpenStore(ledgerid string) (*Store, error) {
 	dbHandle := p.dbProvider.GetDBHandle(ledgerid)
 	s := &Store{
-		db:              dbHandle,
-		ledgerid:        ledgerid,
-		batchesInterval: p.pvtData.BatchesInterval,
-		maxBatchSize:    p.pvtData.MaxBatchSize,
-		purgeInterval:   uint64(p.pvtData.PurgeInterval),
+		db:                                  dbHandle,
+		dbProvider:                          p.dbProvider,
+		ledgerid:                            ledgerid,
+		batchesInterval:                     p.pvtData.BatchesInterval,
+		maxBatchSize:                        p.pvtData.MaxBatchSize,
+		deprioritizedDataReconcilerInterval: p.pvtData.DeprioritizedDataReconcilerInterval,
+		autoRecoverOnCorruption:             p.pvtData.AutoRecoverOnCorruption,
+		expirySchedulesConsumer:             noopExpirySchedulesConsumer{},
 		collElgProcSync: &collElgProcSync{
 			notification: make(chan bool, 1),
 			procComplete: make(chan bool, 1),
 		},
 	}
+	s.purger = newPurger(s, newPurgeSchedule(p.pvtData))
 	if err := s.initState(); err != nil {
 		return nil, err
 	}
 	s.launchCollElgProc()
+	s.purger.start()
 	logger.Debugf("Pvtdata store opened. Initial state: isEmpty [%t], lastCommittedBlock [%d]",
 		s.isEmpty, s.lastCommittedBlock)
 	return s, nil
@@ -162,9 +235,70 @@ func (p *Provider) Close() {
 //////// store functions  ////////////////
 //////////////////////////////////////////
 
+// get is a corruption-aware wrapper around db.Get. If the read fails because the underlying
+// leveldb files are corrupted and AutoRecoverOnCorruption is enabled, it attempts a one-shot
+// Recover() and retries before giving up.
+func (s *Store) get(key []byte) ([]byte, error) {
+	v, err := s.db.Get(key)
+	if err == nil || !leveldbhelper.IsCorrupted(err) || !s.autoRecoverOnCorruption {
+		return v, err
+	}
+	if !atomic.CompareAndSwapInt32(&s.recovering, 0, 1) {
+		return v, err
+	}
+	defer atomic.StoreInt32(&s.recovering, 0)
+
+	logger.Warningf("[%s] Get failed due to corrupted pvtdata store, attempting auto-recovery: %s", s.ledgerid, err)
+	if recErr := s.Recover(); recErr != nil {
+		logger.Errorf("[%s] Auto-recovery failed, corruption appears persistent: %s", s.ledgerid, recErr)
+		return v, err
+	}
+	logger.Infof("[%s] Auto-recovery succeeded, corruption was transient", s.ledgerid)
+	return s.db.Get(key)
+}
+
+// writeBatch is a corruption-aware wrapper around db.WriteBatch, with the same one-shot
+// auto-recovery behavior as get.
+func (s *Store) writeBatch(batch *leveldbhelper.UpdateBatch, sync bool) error {
+	err := s.db.WriteBatch(batch, sync)
+	if err == nil || !leveldbhelper.IsCorrupted(err) || !s.autoRecoverOnCorruption {
+		return err
+	}
+	if !atomic.CompareAndSwapInt32(&s.recovering, 0, 1) {
+		return err
+	}
+	defer atomic.StoreInt32(&s.recovering, 0)
+
+	logger.Warningf("[%s] WriteBatch failed due to corrupted pvtdata store, attempting auto-recovery: %s", s.ledgerid, err)
+	if recErr := s.Recover(); recErr != nil {
+		logger.Errorf("[%s] Auto-recovery failed, corruption appears persistent: %s", s.ledgerid, recErr)
+		return err
+	}
+	logger.Infof("[%s] Auto-recovery succeeded, corruption was transient", s.ledgerid)
+	return s.db.WriteBatch(batch, sync)
+}
+
+// Recover salvages the store's underlying leveldb instance via dbProvider.Recover and then
+// re-runs the collection-eligibility conversion and a full purge sweep, since RecoverFile can
+// leave behind entries that a prior, partially-applied batch had only half-updated. It is
+// exposed so operators (or get/writeBatch, when AutoRecoverOnCorruption is set) can trigger
+// recovery without restarting the peer.
+func (s *Store) Recover() error {
+	if err := s.dbProvider.Recover(); err != nil {
+		return err
+	}
+	s.processCollElgEvents()
+	return s.purger.run(atomic.LoadUint64(&s.lastCommittedBlock))
+}
+
 func (s *Store) initState() error {
 	var err error
 	var blist lastUpdatedOldBlocksList
+
+	if err := s.migrateToSplitMissingDataFormatIfNeeded(); err != nil {
+		return err
+	}
+
 	if s.isEmpty, s.lastCommittedBlock, err = s.getLastCommittedBlockNum(); err != nil {
 		return err
 	}
@@ -179,10 +313,10 @@ func (s *Store) initState() error {
 
 	if batchPending {
 		committingBlockNum := s.nextBlockNum()
-		batch := leveldbhelper.NewUpdateBatch()
+		batch := s.db.NewUpdateBatch()
 		batch.Put(lastCommittedBlkkey, encodeLastCommittedBlockVal(committingBlockNum))
 		batch.Delete(pendingCommitKey)
-		if err := s.db.WriteBatch(batch, true); err != nil {
+		if err := s.writeBatch(batch, true); err != nil {
 			return err
 		}
 		s.isEmpty = false
@@ -204,19 +338,50 @@ func (s *Store) Init(btlPolicy pvtdatapolicy.BTLPolicy) {
 	s.btlPolicy = btlPolicy
 }
 
+// SetExpirySchedulesConsumer registers a consumer to be notified of the BTL expiry schedule of
+// pvtdata reconciled via CommitPvtDataOfOldBlocks. Callers that do not register one get the
+// default no-op behavior, i.e. reconciled pvtdata is never scheduled for purge on their behalf.
+func (s *Store) SetExpirySchedulesConsumer(consumer ExpirySchedulesConsumer) {
+	s.expirySchedulesConsumer = consumer
+}
+
+func (s *Store) expirySchedule(dataEntries []*dataEntry) (map[PvtdataKey]uint64, error) {
+	schedule := make(map[PvtdataKey]uint64)
+	for _, de := range dataEntries {
+		expiringBlk, err := s.btlPolicy.GetExpiringBlock(de.key.ns, de.key.coll, de.key.blkNum)
+		if err != nil {
+			return nil, err
+		}
+		if neverExpires(expiringBlk) {
+			continue
+		}
+		schedule[PvtdataKey{
+			Namespace:  de.key.ns,
+			Collection: de.key.coll,
+			BlockNum:   de.key.blkNum,
+			TxNum:      de.key.txNum,
+		}] = expiringBlk
+	}
+	return schedule, nil
+}
+
 // Commit commits the pvt data as well as both the eligible and ineligible
 // missing private data --- `eligible` denotes that the missing private data belongs to a collection
 // for which this peer is a member; `ineligible` denotes that the missing private data belong to a
 // collection for which this peer is not a member.
 func (s *Store) Commit(blockNum uint64, pvtData []*ledger.TxPvtData, missingPvtData ledger.TxMissingPvtDataMap) error {
+	if s.snapshotImportInProgress {
+		return &ErrIllegalCall{"Cannot commit while a snapshot import is in progress; call InitLastCommittedBlock first"}
+	}
 	expectedBlockNum := s.nextBlockNum()
 	if expectedBlockNum != blockNum {
 		return &ErrIllegalArgs{fmt.Sprintf("Expected block number=%d, received block number=%d", expectedBlockNum, blockNum)}
 	}
 
-	batch := leveldbhelper.NewUpdateBatch()
+	batch := s.db.NewUpdateBatch()
 	var err error
 	var keyBytes, valBytes []byte
+	var committedBytes int
 
 	storeEntries, err := prepareStoreEntries(blockNum, pvtData, s.btlPolicy, missingPvtData)
 	if err != nil {
@@ -229,6 +394,8 @@ func (s *Store) Commit(blockNum uint64, pvtData []*ledger.TxPvtData, missingPvtD
 			return err
 		}
 		batch.Put(keyBytes, valBytes)
+		committedBytes += len(keyBytes) + len(valBytes)
+		s.removeFromDeprioritizedList(batch, dataEntry.key.ns, dataEntry.key.coll, dataEntry.key.blkNum)
 	}
 
 	for _, expiryEntry := range storeEntries.expiryEntries {
@@ -237,6 +404,7 @@ func (s *Store) Commit(blockNum uint64, pvtData []*ledger.TxPvtData, missingPvtD
 			return err
 		}
 		batch.Put(keyBytes, valBytes)
+		committedBytes += len(keyBytes) + len(valBytes)
 	}
 
 	for missingDataKey, missingDataValue := range storeEntries.missingDataEntries {
@@ -245,263 +413,20 @@ func (s *Store) Commit(blockNum uint64, pvtData []*ledger.TxPvtData, missingPvtD
 			return err
 		}
 		batch.Put(keyBytes, valBytes)
+		committedBytes += len(keyBytes) + len(valBytes)
 	}
 
 	committingBlockNum := s.nextBlockNum()
 	logger.Debugf("Committing private data for block [%d]", committingBlockNum)
 	batch.Put(lastCommittedBlkkey, encodeLastCommittedBlockVal(committingBlockNum))
-	if err := s.db.WriteBatch(batch, true); err != nil {
+	if err := s.writeBatch(batch, true); err != nil {
 		return err
 	}
 
 	s.isEmpty = false
 	atomic.StoreUint64(&s.lastCommittedBlock, committingBlockNum)
 	logger.Debugf("Committed private data for block [%d]", committingBlockNum)
-	s.performPurgeIfScheduled(committingBlockNum)
-	return nil
-}
-
-// CommitPvtDataOfOldBlocks commits the pvtData (i.e., previously missing data) of old blocks.
-// The parameter `blocksPvtData` refers a list of old block's pvtdata which are missing in the pvtstore.
-// Given a list of old block's pvtData, `CommitPvtDataOfOldBlocks` performs the following four
-// operations
-// (1) construct dataEntries for all pvtData
-// (2) construct update entries (i.e., dataEntries, expiryEntries, missingDataEntries)
-//     from the above created data entries
-// (3) create a db update batch from the update entries
-// (4) commit the update batch to the pvtStore
-func (s *Store) CommitPvtDataOfOldBlocks(blocksPvtData map[uint64][]*ledger.TxPvtData) error {
-	if s.isLastUpdatedOldBlocksSet {
-		return &ErrIllegalCall{`The lastUpdatedOldBlocksList is set. It means that the
-		stateDB may not be in sync with the pvtStore`}
-	}
-
-	// (1) construct dataEntries for all pvtData
-	dataEntries := constructDataEntriesFromBlocksPvtData(blocksPvtData)
-
-	// (2) construct update entries (i.e., dataEntries, expiryEntries, missingDataEntries) from the above created data entries
-	logger.Debugf("Constructing pvtdatastore entries for pvtData of [%d] old blocks", len(blocksPvtData))
-	updateEntries, err := s.constructUpdateEntriesFromDataEntries(dataEntries)
-	if err != nil {
-		return err
-	}
-
-	// (3) create a db update batch from the update entries
-	logger.Debug("Constructing update batch from pvtdatastore entries")
-	batch, err := constructUpdateBatchFromUpdateEntries(updateEntries)
-	if err != nil {
-		return err
-	}
-
-	// (4) commit the update batch to the pvtStore
-	logger.Debug("Committing the update batch to pvtdatastore")
-	if err := s.commitBatch(batch); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func constructDataEntriesFromBlocksPvtData(blocksPvtData map[uint64][]*ledger.TxPvtData) []*dataEntry {
-	// construct dataEntries for all pvtData
-	var dataEntries []*dataEntry
-	for blkNum, pvtData := range blocksPvtData {
-		// prepare the dataEntries for the pvtData
-		dataEntries = append(dataEntries, prepareDataEntries(blkNum, pvtData)...)
-	}
-	return dataEntries
-}
-
-func (s *Store) constructUpdateEntriesFromDataEntries(dataEntries []*dataEntry) (*entriesForPvtDataOfOldBlocks, error) {
-	updateEntries := &entriesForPvtDataOfOldBlocks{
-		dataEntries:        make(map[dataKey]*rwset.CollectionPvtReadWriteSet),
-		expiryEntries:      make(map[expiryKey]*ExpiryData),
-		missingDataEntries: make(map[nsCollBlk]*bitset.BitSet)}
-
-	// for each data entry, first, get the expiryData and missingData from the pvtStore.
-	// Second, update the expiryData and missingData as per the data entry. Finally, add
-	// the data entry along with the updated expiryData and missingData to the update entries
-	for _, dataEntry := range dataEntries {
-		// get the expiryBlk number to construct the expiryKey
-		expiryKey, err := s.constructExpiryKeyFromDataEntry(dataEntry)
-		if err != nil {
-			return nil, err
-		}
-
-		// get the existing expiryData entry
-		var expiryData *ExpiryData
-		if !neverExpires(expiryKey.expiringBlk) {
-			if expiryData, err = s.getExpiryDataFromUpdateEntriesOrStore(updateEntries, expiryKey); err != nil {
-				return nil, err
-			}
-			if expiryData == nil {
-				// data entry is already expired
-				// and purged (a rare scenario)
-				continue
-			}
-		}
-
-		// get the existing missingData entry
-		var missingData *bitset.BitSet
-		nsCollBlk := dataEntry.key.nsCollBlk
-		if missingData, err = s.getMissingDataFromUpdateEntriesOrStore(updateEntries, nsCollBlk); err != nil {
-			return nil, err
-		}
-		if missingData == nil {
-			// data entry is already expired
-			// and purged (a rare scenario)
-			continue
-		}
-
-		updateEntries.addDataEntry(dataEntry)
-		if expiryData != nil { // would be nil for the never expiring entry
-			expiryEntry := &expiryEntry{&expiryKey, expiryData}
-			updateEntries.updateAndAddExpiryEntry(expiryEntry, dataEntry.key)
-		}
-		updateEntries.updateAndAddMissingDataEntry(missingData, dataEntry.key)
-	}
-	return updateEntries, nil
-}
-
-func (s *Store) constructExpiryKeyFromDataEntry(dataEntry *dataEntry) (expiryKey, error) {
-	// get the expiryBlk number to construct the expiryKey
-	nsCollBlk := dataEntry.key.nsCollBlk
-	expiringBlk, err := s.btlPolicy.GetExpiringBlock(nsCollBlk.ns, nsCollBlk.coll, nsCollBlk.blkNum)
-	if err != nil {
-		return expiryKey{}, err
-	}
-	return expiryKey{expiringBlk, nsCollBlk.blkNum}, nil
-}
-
-func (s *Store) getExpiryDataFromUpdateEntriesOrStore(updateEntries *entriesForPvtDataOfOldBlocks, expiryKey expiryKey) (*ExpiryData, error) {
-	expiryData, ok := updateEntries.expiryEntries[expiryKey]
-	if !ok {
-		var err error
-		expiryData, err = s.getExpiryDataOfExpiryKey(&expiryKey)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return expiryData, nil
-}
-
-func (s *Store) getMissingDataFromUpdateEntriesOrStore(updateEntries *entriesForPvtDataOfOldBlocks, nsCollBlk nsCollBlk) (*bitset.BitSet, error) {
-	missingData, ok := updateEntries.missingDataEntries[nsCollBlk]
-	if !ok {
-		var err error
-		missingDataKey := &missingDataKey{nsCollBlk, true}
-		missingData, err = s.getBitmapOfMissingDataKey(missingDataKey)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return missingData, nil
-}
-
-func (updateEntries *entriesForPvtDataOfOldBlocks) addDataEntry(dataEntry *dataEntry) {
-	dataKey := dataKey{dataEntry.key.nsCollBlk, dataEntry.key.txNum}
-	updateEntries.dataEntries[dataKey] = dataEntry.value
-}
-
-func (updateEntries *entriesForPvtDataOfOldBlocks) updateAndAddExpiryEntry(expiryEntry *expiryEntry, dataKey *dataKey) {
-	txNum := dataKey.txNum
-	nsCollBlk := dataKey.nsCollBlk
-	// update
-	expiryEntry.value.addPresentData(nsCollBlk.ns, nsCollBlk.coll, txNum)
-	// we cannot delete entries from MissingDataMap as
-	// we keep only one entry per missing <ns-col>
-	// irrespective of the number of txNum.
-
-	// add
-	expiryKey := expiryKey{expiryEntry.key.expiringBlk, expiryEntry.key.committingBlk}
-	updateEntries.expiryEntries[expiryKey] = expiryEntry.value
-}
-
-func (updateEntries *entriesForPvtDataOfOldBlocks) updateAndAddMissingDataEntry(missingData *bitset.BitSet, dataKey *dataKey) {
-
-	txNum := dataKey.txNum
-	nsCollBlk := dataKey.nsCollBlk
-	// update
-	missingData.Clear(uint(txNum))
-	// add
-	updateEntries.missingDataEntries[nsCollBlk] = missingData
-}
-
-func constructUpdateBatchFromUpdateEntries(updateEntries *entriesForPvtDataOfOldBlocks) (*leveldbhelper.UpdateBatch, error) {
-	batch := leveldbhelper.NewUpdateBatch()
-
-	// add the following four types of entries to the update batch: (1) new data entries
-	// (i.e., pvtData), (2) updated expiry entries, (3) updated missing data entries, and
-	// (4) updated block list
-
-	// (1) add new data entries to the batch
-	if err := addNewDataEntriesToUpdateBatch(batch, updateEntries); err != nil {
-		return nil, err
-	}
-
-	// (2) add updated expiryEntry to the batch
-	if err := addUpdatedExpiryEntriesToUpdateBatch(batch, updateEntries); err != nil {
-		return nil, err
-	}
-
-	// (3) add updated missingData to the batch
-	if err := addUpdatedMissingDataEntriesToUpdateBatch(batch, updateEntries); err != nil {
-		return nil, err
-	}
-
-	return batch, nil
-}
-
-func addNewDataEntriesToUpdateBatch(batch *leveldbhelper.UpdateBatch, entries *entriesForPvtDataOfOldBlocks) error {
-	var keyBytes, valBytes []byte
-	var err error
-	for dataKey, pvtData := range entries.dataEntries {
-		keyBytes = encodeDataKey(&dataKey)
-		if valBytes, err = encodeDataValue(pvtData); err != nil {
-			return err
-		}
-		batch.Put(keyBytes, valBytes)
-	}
-	return nil
-}
-
-func addUpdatedExpiryEntriesToUpdateBatch(batch *leveldbhelper.UpdateBatch, entries *entriesForPvtDataOfOldBlocks) error {
-	var keyBytes, valBytes []byte
-	var err error
-	for expiryKey, expiryData := range entries.expiryEntries {
-		keyBytes = encodeExpiryKey(&expiryKey)
-		if valBytes, err = encodeExpiryValue(expiryData); err != nil {
-			return err
-		}
-		batch.Put(keyBytes, valBytes)
-	}
-	return nil
-}
-
-func addUpdatedMissingDataEntriesToUpdateBatch(batch *leveldbhelper.UpdateBatch, entries *entriesForPvtDataOfOldBlocks) error {
-	var keyBytes, valBytes []byte
-	var err error
-	for nsCollBlk, missingData := range entries.missingDataEntries {
-		keyBytes = encodeMissingDataKey(&missingDataKey{nsCollBlk, true})
-		// if the missingData is empty, we need to delete the missingDataKey
-		if missingData.None() {
-			batch.Delete(keyBytes)
-			continue
-		}
-		if valBytes, err = encodeMissingDataValue(missingData); err != nil {
-			return err
-		}
-		batch.Put(keyBytes, valBytes)
-	}
-	return nil
-}
-
-func (s *Store) commitBatch(batch *leveldbhelper.UpdateBatch) error {
-	// commit the batch to the store
-	if err := s.db.WriteBatch(batch, true); err != nil {
-		return err
-	}
-
+	s.purger.scheduleIfDue(committingBlockNum, committedBytes)
 	return nil
 }
 
@@ -533,7 +458,7 @@ func (s *Store) GetLastUpdatedOldBlocksPvtData() (map[uint64][]*ledger.TxPvtData
 func (s *Store) getLastUpdatedOldBlocksList() ([]uint64, error) {
 	var v []byte
 	var err error
-	if v, err = s.db.Get(lastUpdatedOldBlocksKey); err != nil {
+	if v, err = s.get(lastUpdatedOldBlocksKey); err != nil {
 		return nil, err
 	}
 	if v == nil {
@@ -563,9 +488,9 @@ func (s *Store) getLastUpdatedOldBlocksList() ([]uint64, error) {
 
 // ResetLastUpdatedOldBlocksList removes the `lastUpdatedOldBlocksList` entry from the store
 func (s *Store) ResetLastUpdatedOldBlocksList() error {
-	batch := leveldbhelper.NewUpdateBatch()
+	batch := s.db.NewUpdateBatch()
 	batch.Delete(lastUpdatedOldBlocksKey)
-	if err := s.db.WriteBatch(batch, true); err != nil {
+	if err := s.writeBatch(batch, true); err != nil {
 		return err
 	}
 	s.isLastUpdatedOldBlocksSet = false
@@ -648,22 +573,49 @@ func (s *Store) GetMissingPvtDataInfoForMostRecentBlocks(maxBlock int) (ledger.M
 		return nil, nil
 	}
 
-	missingPvtDataInfo := make(ledger.MissingPvtDataInfo)
-	numberOfBlockProcessed := 0
-	lastProcessedBlock := uint64(0)
-	isMaxBlockLimitReached := false
+	if s.shouldScanDeprioritizedList() {
+		logger.Debugf("Scanning deprioritized missing data entries for reconciliation")
+		startKey, endKey := createRangeScanKeysForDeprioritizedMissingDataEntries()
+		return s.scanMissingDataRange(maxBlock, startKey, endKey, decodeDeprioritizedMissingDataKey)
+	}
+
 	// as we are not acquiring a read lock, new blocks can get committed while we
 	// construct the MissingPvtDataInfo. As a result, lastCommittedBlock can get
 	// changed. To ensure consistency, we atomically load the lastCommittedBlock value
 	lastCommittedBlock := atomic.LoadUint64(&s.lastCommittedBlock)
-
 	startKey, endKey := createRangeScanKeysForEligibleMissingDataEntries(lastCommittedBlock)
+	return s.scanMissingDataRange(maxBlock, startKey, endKey, decodeMissingDataKey)
+}
+
+// FetchBootKVHashes returns the missing private data info recorded in the deprioritized list,
+// regardless of DeprioritizedDataReconcilerInterval. Unlike GetMissingPvtDataInfoForMostRecentBlocks,
+// it never falls back to the prioritized list, so a caller (e.g. an admin reconciliation trigger) can
+// explicitly ask for the pvtdata the store has given up retrying on its usual schedule.
+func (s *Store) FetchBootKVHashes(maxBlock int) (ledger.MissingPvtDataInfo, error) {
+	if maxBlock < 1 {
+		return nil, nil
+	}
+
+	startKey, endKey := createRangeScanKeysForDeprioritizedMissingDataEntries()
+	return s.scanMissingDataRange(maxBlock, startKey, endKey, decodeDeprioritizedMissingDataKey)
+}
+
+// scanMissingDataRange walks [startKey, endKey) of the missing-data keyspace and assembles a
+// MissingPvtDataInfo covering at most maxBlock distinct blocks, decoding each key with decodeKey so
+// the same scan/expiry/maxBlock-limit logic serves both the prioritized/deprioritized scan inside
+// GetMissingPvtDataInfoForMostRecentBlocks and FetchBootKVHashes's always-deprioritized scan.
+func (s *Store) scanMissingDataRange(maxBlock int, startKey, endKey []byte,
+	decodeKey func([]byte) *missingDataKey) (ledger.MissingPvtDataInfo, error) {
+	missingPvtDataInfo := make(ledger.MissingPvtDataInfo)
+	numberOfBlockProcessed := 0
+	lastProcessedBlock := uint64(0)
+	isMaxBlockLimitReached := false
+
 	dbItr := s.db.GetIterator(startKey, endKey)
 	defer dbItr.Release()
 
 	for dbItr.Next() {
-		missingDataKeyBytes := dbItr.Key()
-		missingDataKey := decodeMissingDataKey(missingDataKeyBytes)
+		missingDataKey := decodeKey(dbItr.Key())
 
 		if isMaxBlockLimitReached && (missingDataKey.blkNum != lastProcessedBlock) {
 			// ensures that exactly maxBlock number
@@ -672,14 +624,14 @@ func (s *Store) GetMissingPvtDataInfoForMostRecentBlocks(maxBlock int) (ledger.M
 		}
 
 		// check whether the entry is expired. If so, move to the next item.
-		// As we may use the old lastCommittedBlock value, there is a possibility that
+		// As we may use a stale lastCommittedBlock value, there is a possibility that
 		// this missing data is actually expired but we may get the stale information.
 		// Though it may leads to extra work of pulling the expired data, it will not
 		// affect the correctness. Further, as we try to fetch the most recent missing
 		// data (less possibility of expiring now), such scenario would be rare. In the
 		// best case, we can load the latest lastCommittedBlock value here atomically to
 		// make this scenario very rare.
-		lastCommittedBlock = atomic.LoadUint64(&s.lastCommittedBlock)
+		lastCommittedBlock := atomic.LoadUint64(&s.lastCommittedBlock)
 		expired, err := isExpired(missingDataKey.nsCollBlk, s.btlPolicy, lastCommittedBlock)
 		if err != nil {
 			return nil, err
@@ -701,8 +653,7 @@ func (s *Store) GetMissingPvtDataInfoForMostRecentBlocks(maxBlock int) (ledger.M
 			}
 		}
 
-		valueBytes := dbItr.Value()
-		bitmap, err := decodeMissingDataValue(valueBytes)
+		bitmap, err := decodeMissingDataValue(dbItr.Value())
 		if err != nil {
 			return nil, err
 		}
@@ -717,6 +668,25 @@ func (s *Store) GetMissingPvtDataInfoForMostRecentBlocks(maxBlock int) (ledger.M
 	return missingPvtDataInfo, nil
 }
 
+// PurgeNow synchronously sweeps all private data whose BTL has expired as of uptoBlk,
+// bypassing the configured purge schedule. It is meant for admin-triggered, on-demand purges.
+func (s *Store) PurgeNow(uptoBlk uint64) error {
+	return s.purger.now(uptoBlk)
+}
+
+// PurgeStats returns a snapshot of the purger's Prometheus-style counters and gauges: entries
+// purged, bytes reclaimed, the duration of the most recent sweep, and the block number that
+// sweep covered.
+func (s *Store) PurgeStats() PurgeStats {
+	return s.purger.stats.snapshot()
+}
+
+// PurgeErrors returns the channel on which the purger reports failures from schedule-triggered
+// sweeps; PurgeNow's result is returned directly instead of being sent here.
+func (s *Store) PurgeErrors() <-chan error {
+	return s.purger.errs
+}
+
 // ProcessCollsEligibilityEnabled notifies the store when the peer becomes eligible to receive data for an
 // existing collection. Parameter 'committingBlk' refers to the block number that contains the corresponding
 // collection upgrade transaction and the parameter 'nsCollMap' contains the collections for which the peer
@@ -728,77 +698,15 @@ func (s *Store) ProcessCollsEligibilityEnabled(committingBlk uint64, nsCollMap m
 	if err != nil {
 		return err
 	}
-	batch := leveldbhelper.NewUpdateBatch()
+	batch := s.db.NewUpdateBatch()
 	batch.Put(key, val)
-	if err = s.db.WriteBatch(batch, true); err != nil {
+	if err = s.writeBatch(batch, true); err != nil {
 		return err
 	}
 	s.collElgProcSync.notify()
 	return nil
 }
 
-func (s *Store) performPurgeIfScheduled(latestCommittedBlk uint64) {
-	if latestCommittedBlk%s.purgeInterval != 0 {
-		return
-	}
-	go func() {
-		s.purgerLock.Lock()
-		logger.Debugf("Purger started: Purging expired private data till block number [%d]", latestCommittedBlk)
-		defer s.purgerLock.Unlock()
-		err := s.purgeExpiredData(0, latestCommittedBlk)
-		if err != nil {
-			logger.Warningf("Could not purge data from pvtdata store:%s", err)
-		}
-		logger.Debug("Purger finished")
-	}()
-}
-
-func (s *Store) purgeExpiredData(minBlkNum, maxBlkNum uint64) error {
-	batch := leveldbhelper.NewUpdateBatch()
-	expiryEntries, err := s.retrieveExpiryEntries(minBlkNum, maxBlkNum)
-	if err != nil || len(expiryEntries) == 0 {
-		return err
-	}
-	for _, expiryEntry := range expiryEntries {
-		// this encoding could have been saved if the function retrieveExpiryEntries also returns the encoded expiry keys.
-		// However, keeping it for better readability
-		batch.Delete(encodeExpiryKey(expiryEntry.key))
-		dataKeys, missingDataKeys := deriveKeys(expiryEntry)
-		for _, dataKey := range dataKeys {
-			batch.Delete(encodeDataKey(dataKey))
-		}
-		for _, missingDataKey := range missingDataKeys {
-			batch.Delete(encodeMissingDataKey(missingDataKey))
-		}
-		s.db.WriteBatch(batch, false)
-	}
-	logger.Infof("[%s] - [%d] Entries purged from private data storage till block number [%d]", s.ledgerid, len(expiryEntries), maxBlkNum)
-	return nil
-}
-
-func (s *Store) retrieveExpiryEntries(minBlkNum, maxBlkNum uint64) ([]*expiryEntry, error) {
-	startKey, endKey := getExpiryKeysForRangeScan(minBlkNum, maxBlkNum)
-	logger.Debugf("retrieveExpiryEntries(): startKey=%#v, endKey=%#v", startKey, endKey)
-	itr := s.db.GetIterator(startKey, endKey)
-	defer itr.Release()
-
-	var expiryEntries []*expiryEntry
-	for itr.Next() {
-		expiryKeyBytes := itr.Key()
-		expiryValueBytes := itr.Value()
-		expiryKey, err := decodeExpiryKey(expiryKeyBytes)
-		if err != nil {
-			return nil, err
-		}
-		expiryValue, err := decodeExpiryValue(expiryValueBytes)
-		if err != nil {
-			return nil, err
-		}
-		expiryEntries = append(expiryEntries, &expiryEntry{key: expiryKey, value: expiryValue})
-	}
-	return expiryEntries, nil
-}
-
 func (s *Store) launchCollElgProc() {
 	go func() {
 		s.processCollElgEvents() // process collection eligibility events when store is opened - in case there is an unprocessed events from previous run
@@ -818,7 +726,7 @@ func (s *Store) processCollElgEvents() {
 	collElgStartKey, collElgEndKey := createRangeScanKeysForCollElg()
 	eventItr := s.db.GetIterator(collElgStartKey, collElgEndKey)
 	defer eventItr.Release()
-	batch := leveldbhelper.NewUpdateBatch()
+	batch := s.db.NewUpdateBatch()
 	totalEntriesConverted := 0
 
 	for eventItr.Next() {
@@ -846,10 +754,14 @@ func (s *Store) processCollElgEvents() {
 					copyVal := make([]byte, len(originalVal))
 					copy(copyVal, originalVal)
 					batch.Put(encodeMissingDataKey(modifiedKey), copyVal)
+					// an ineligible entry can never have been deprioritized (only eligible
+					// data is deprioritized), but clear any stale entry defensively in case a
+					// prior eligibility cycle left one behind for this ns/coll/blkNum.
+					s.removeFromDeprioritizedList(batch, modifiedKey.ns, modifiedKey.coll, modifiedKey.blkNum)
 					collEntriesConverted++
 					if batch.Len() > s.maxBatchSize {
-						s.db.WriteBatch(batch, true)
-						batch = leveldbhelper.NewUpdateBatch()
+						s.writeBatch(batch, true)
+						batch = s.db.NewUpdateBatch()
 						sleepTime := time.Duration(s.batchesInterval)
 						logger.Infof("Going to sleep for %d milliseconds between batches. Entries for [ns=%s, coll=%s] converted so far = %d",
 							sleepTime, ns, coll, collEntriesConverted)
@@ -867,10 +779,54 @@ func (s *Store) processCollElgEvents() {
 		batch.Delete(collElgKey) // delete the collection eligibility event key as well
 	} // event loop
 
-	s.db.WriteBatch(batch, true)
+	s.writeBatch(batch, true)
 	logger.Debugf("Converted [%d] ineligible missing data entries to eligible", totalEntriesConverted)
 }
 
+// migrateToSplitMissingDataFormatIfNeeded rewrites every missing-data entry still stored under
+// the legacy combined prefix into the new elg/inelg-specific prefixes, then records the new
+// format so this migration only ever runs once per store. Existing peers pick this up
+// automatically the first time they open a store created before the prefixes were split.
+func (s *Store) migrateToSplitMissingDataFormatIfNeeded() error {
+	v, err := s.get(dataFormatVersionKey)
+	if err != nil {
+		return err
+	}
+	if dataFormat(v) == dataFormatSplitElg {
+		return nil
+	}
+
+	startKey, endKey := createRangeScanKeysForLegacyMissingDataEntries()
+	itr := s.db.GetIterator(startKey, endKey)
+	defer itr.Release()
+
+	batch := s.db.NewUpdateBatch()
+	migrated := 0
+	for itr.Next() {
+		legacyKey, val := itr.Key(), itr.Value()
+		newKey := decodeLegacyMissingDataKey(legacyKey)
+		copyVal := make([]byte, len(val))
+		copy(copyVal, val)
+		batch.Delete(legacyKey)
+		batch.Put(encodeMissingDataKey(newKey), copyVal)
+		migrated++
+		if batch.Len() > s.maxBatchSize {
+			if err := s.writeBatch(batch, true); err != nil {
+				return err
+			}
+			batch = s.db.NewUpdateBatch()
+		}
+	}
+	batch.Put(dataFormatVersionKey, []byte(dataFormatSplitElg))
+	if err := s.writeBatch(batch, true); err != nil {
+		return err
+	}
+	if migrated > 0 {
+		logger.Infof("[%s] Migrated [%d] missing-data entries to the split eligible/ineligible key format", s.ledgerid, migrated)
+	}
+	return nil
+}
+
 // LastCommittedBlockHeight returns the height of the last committed block
 func (s *Store) LastCommittedBlockHeight() (uint64, error) {
 	if s.isEmpty {
@@ -892,7 +848,7 @@ func (s *Store) nextBlockNum() uint64 {
 func (s *Store) hasPendingCommit() (bool, error) {
 	var v []byte
 	var err error
-	if v, err = s.db.Get(pendingCommitKey); err != nil {
+	if v, err = s.get(pendingCommitKey); err != nil {
 		return false, err
 	}
 	return v != nil, nil
@@ -901,7 +857,7 @@ func (s *Store) hasPendingCommit() (bool, error) {
 func (s *Store) getLastCommittedBlockNum() (bool, uint64, error) {
 	var v []byte
 	var err error
-	if v, err = s.db.Get(lastCommittedBlkkey); v == nil || err != nil {
+	if v, err = s.get(lastCommittedBlkkey); v == nil || err != nil {
 		return true, 0, err
 	}
 	return false, decodeLastCommittedBlockVal(v), nil
@@ -938,7 +894,7 @@ func (sync *collElgProcSync) waitForDone() {
 func (s *Store) getBitmapOfMissingDataKey(missingDataKey *missingDataKey) (*bitset.BitSet, error) {
 	var v []byte
 	var err error
-	if v, err = s.db.Get(encodeMissingDataKey(missingDataKey)); err != nil {
+	if v, err = s.get(encodeMissingDataKey(missingDataKey)); err != nil {
 		return nil, err
 	}
 	if v == nil {
@@ -950,7 +906,7 @@ func (s *Store) getBitmapOfMissingDataKey(missingDataKey *missingDataKey) (*bits
 func (s *Store) getExpiryDataOfExpiryKey(expiryKey *expiryKey) (*ExpiryData, error) {
 	var v []byte
 	var err error
-	if v, err = s.db.Get(encodeExpiryKey(expiryKey)); err != nil {
+	if v, err = s.get(encodeExpiryKey(expiryKey)); err != nil {
 		return nil, err
 	}
 	if v == nil {
@@ -959,6 +915,54 @@ func (s *Store) getExpiryDataOfExpiryKey(expiryKey *expiryKey) (*ExpiryData, err
 	return decodeExpiryValue(v)
 }
 
+//////// deprioritized missing data  //////
+//////////////////////////////////////////
+
+// addToDeprioritizedList records that no peer was able to supply the given
+// missing data entries on the last reconciliation attempt, so they should be
+// retried less aggressively.
+func (s *Store) addToDeprioritizedList(batch *leveldbhelper.UpdateBatch, unreconciledMissingData ledger.MissingPvtDataInfo) error {
+	for blkNum, missingDataInfos := range unreconciledMissingData {
+		for _, missing := range missingDataInfos {
+			key := &missingDataKey{
+				nsCollBlk: nsCollBlk{ns: missing.Namespace, coll: missing.Collection, blkNum: blkNum},
+			}
+			bitmap, err := s.getBitmapOfMissingDataKey(&missingDataKey{nsCollBlk: key.nsCollBlk, isEligible: true})
+			if err != nil {
+				return err
+			}
+			if bitmap == nil {
+				continue
+			}
+			valBytes, err := encodeMissingDataValue(bitmap)
+			if err != nil {
+				return err
+			}
+			batch.Put(encodeDeprioritizedMissingDataKey(key), valBytes)
+		}
+	}
+	return nil
+}
+
+// removeFromDeprioritizedList deletes the deprioritized entry (if any) for
+// the given ns/coll/block now that pvtdata for it has arrived.
+func (s *Store) removeFromDeprioritizedList(batch *leveldbhelper.UpdateBatch, ns, coll string, blkNum uint64) {
+	key := &missingDataKey{nsCollBlk: nsCollBlk{ns: ns, coll: coll, blkNum: blkNum}}
+	batch.Delete(encodeDeprioritizedMissingDataKey(key))
+}
+
+// shouldScanDeprioritizedList decides, based on the configured interval and
+// the running invocation counter, whether this call to
+// GetMissingPvtDataInfoForMostRecentBlocks should serve deprioritized entries
+// instead of the regular prioritized ones.
+func (s *Store) shouldScanDeprioritizedList() bool {
+	if s.deprioritizedDataReconcilerInterval <= 0 {
+		return false
+	}
+	count := atomic.AddUint64(&s.reconciliationRequestCount, 1)
+	return count%uint64(s.deprioritizedDataReconcilerInterval) == 0
+}
+
 // ErrIllegalCall is to be thrown by a store impl if the store does not expect a call to Prepare/Commit/Rollback/InitLastCommittedBlock
 type ErrIllegalCall struct {
 	msg string
@@ -0,0 +1,29 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package peer
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// Splitting CredentialSupport's TLS root-CA trust pool into AppRootCAsByChain and
+// OrdererRootCAsByChain is not implemented in this checkout.
+//
+// This needs internal/pkg/comm.CredentialSupport and comm.GRPCServer (the gRPC server whose
+// client-auth trust pool would be rebuilt from the app map) plus the Peer.CreateChannel config-
+// block ingestion path that currently feeds every org's TlsRootCerts/TlsIntermediateCerts into a
+// single pool - none of which exist here. Only pkg_test.go, an orphaned test referencing
+// comm.NewCredentialSupport, peerInstance.CredentialSupport and peerInstance.CreateChannel,
+// survives in this package; there is no Peer, CredentialSupport, or config-block ingestion code
+// for a second trust pool to split out of.
+//
+// Once that layer exists, this should land as two maps on CredentialSupport keyed by channel ID -
+// AppRootCAsByChain and OrdererRootCAsByChain - populated by routing application-org TLS certs
+// into the former and orderer-org TLS certs into the latter during CreateChannel's config-block
+// walk, a GetClientRootCAs() accessor returning both pools separately, the gRPC server's mutual-
+// TLS trust pool rebuilt from the app pool only, and the orderer deliver client dialing with the
+// orderer pool; TestUpdateRootsFromConfigBlock would gain a case asserting an orderer-org client
+// cert is rejected on the peer's endpoint and vice versa.
@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package peer
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// A CRL-aware RevocationChecker inside CredentialSupport, hooked into the gRPC server's
+// VerifyPeerCertificate so a handshake is rejected when the peer certificate's serial appears in
+// a non-expired CRL signed by its issuer, is not implemented in this checkout.
+//
+// This needs internal/pkg/comm.CredentialSupport and comm.GRPCServer's *tls.Config construction,
+// neither of which exist here; see credential_roots.go in this same directory for the fuller
+// account of the missing CredentialSupport/GRPCServer layer this would build on. There is no
+// CreateChannel config-block walk here either to parse MSPConfig.RevocationList entries out of.
+//
+// Once that layer exists, this should land as a RevocationChecker on CredentialSupport holding
+// per-MSP *pkix.CertificateList values parsed at CreateChannel time, a VerifyPeerCertificate
+// callback installed on the server's *tls.Config that walks each verified chain up to its issuer
+// and rejects the handshake on a matching non-expired CRL entry, and an UpdateCRLs(channelID
+// string, crls [][]byte) method so CreateChannel can push new CRLs as config blocks update.
+// TestUpdateRootsFromConfigBlock would gain a case that revokes org2Server1Cert mid-run and
+// asserts the previously-good dial now fails with a TLS alert.
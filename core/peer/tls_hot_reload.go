@@ -0,0 +1,25 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package peer
+
+// STATUS: blocked - this file contains no feature code; see the doc comment below for
+// what is missing and why.
+
+// A comm.GRPCServer.SetClientRootCAs method that atomically swaps the in-flight *tls.Config's
+// client trust pool via GetConfigForClient, invoked from Peer.CreateChannel so newly-joined orgs'
+// clients can authenticate without a peer restart, is not implemented in this checkout.
+//
+// This needs internal/pkg/comm.GRPCServer and core/peer.Peer.CreateChannel, neither of which exist
+// here; see credential_roots.go in this same directory for the fuller account of the missing
+// CredentialSupport/GRPCServer layer this would build on.
+//
+// Once that layer exists, this should land as SetClientRootCAs([][]byte) error on GRPCServer,
+// swapping the pool a GetConfigForClient callback hands back to in-flight listeners so existing
+// connections pick up the new trust set on their next handshake, called from CreateChannel right
+// after CredentialSupport is updated. TestUpdateRootsFromConfigBlock would gain a case that joins
+// a second channel after the server is already serving and shows a new org's client cert
+// succeeding on a long-lived listener while a removed org's cert is rejected.
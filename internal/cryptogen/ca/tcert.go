@@ -0,0 +1,170 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ca
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric/bccsp/gm"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/sm3"
+	"github.com/tjfoc/gmsm/sm4"
+)
+
+// NOTE: ca.go - which defines the CA type itself, NewCA and SignCertificate - is not part of this
+// checkout (only ca_test.go is); this file is written against the CA shape ca_test.go exercises
+// (CA.Name, CA.SignSm2Cert) plus a CA.Signer *sm2.PrivateKey field for the key SignCertificate must
+// already use internally to sign issued certs. It is ready to compile once ca.go is restored.
+
+// OIDs for the extensions GenerateTCertBatch attaches to each TCert it issues: the nonce tying the
+// cert back to its HMAC-SM3 derivation step, and the SM4-CTR-encrypted attribute bundle. They sit
+// under an arbitrary arc of this project's OSCCA-aligned OID space, the way pkcs7.go's CMS OIDs do.
+var (
+	oidTCertIndex     = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301, 1}
+	oidTCertAttribute = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301, 2}
+)
+
+// tcertAttributes is the plaintext ASN.1 shape SM4-CTR-encrypted under oidTCertAttribute. The
+// whole structure is encrypted, so both the Names and the Values are protected.
+type tcertAttributes struct {
+	Names  []string
+	Values [][]byte
+}
+
+// GenerateTCertBatch issues count short-lived, unlinkable transaction certificates (TCerts)
+// derived from the enrollment certificate/public key eCert/ePub, mirroring how Fabric's pre-1.0 TCA
+// derived a key tree off an enrollment identity so no two transactions from the same identity share
+// a certificate. For each TCert i:
+//
+//  1. a fresh random nonce_i is drawn, and two HMAC-SM3 steps are derived from it over this CA's
+//     TCertOwnerKDFKey for eCert (step1 = HMAC(rootKey, 0x01||nonce_i), step2 = HMAC(rootKey,
+//     0x02||nonce_i));
+//  2. step1, reduced modulo the SM2 curve order, tweaks ePub via EC point addition to the TCert's
+//     public key. ca never sees (or needs) the matching enrollment private key: whoever holds it
+//     completes the matching TCert private key themselves, by adding the same reduced step1 to
+//     their own scalar - exactly what gm.SM2TCertKeyDeriver.KeyDeriv does given
+//     bccsp.TCertKeyDeriveOpts{Delta: step1};
+//  3. attrs is SM4-CTR-encrypted with a key derived from step2 and attached, together with the
+//     plaintext nonce, as certificate extensions so a party holding the TCertOwnerKDFKey can
+//     recompute step2 and decrypt;
+//  4. the resulting certificate is signed with ca.Signer, the same key SignCertificate uses.
+//
+// It returns, alongside the certs, one *gm.SM2PrivateKey per TCert carrying step1 - not by itself a
+// usable signing key - for gm.SM2TCertKeyDeriver to combine with the enrollment private key.
+func (ca *CA) GenerateTCertBatch(eCert *sm2.Certificate, ePub *sm2.PublicKey, count int, attrs map[string][]byte) ([]*sm2.Certificate, []*gm.SM2PrivateKey, error) {
+	if eCert == nil || ePub == nil {
+		return nil, nil, errors.New("Invalid eCert or ePub. Neither must be nil.")
+	}
+	if count <= 0 {
+		return nil, nil, fmt.Errorf("Invalid count [%d]. Must be greater than zero.", count)
+	}
+	if ca.Signer == nil || ca.SignSm2Cert == nil {
+		return nil, nil, errors.New("Invalid CA. Signer and SignSm2Cert must be set.")
+	}
+
+	rootKey := tcertOwnerKDFKey(ca, eCert)
+	curve := ePub.Curve
+
+	certs := make([]*sm2.Certificate, count)
+	deltas := make([]*gm.SM2PrivateKey, count)
+	for i := 0; i < count; i++ {
+		nonce := make([]byte, sm4.BlockSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, nil, fmt.Errorf("Failed generating TCert nonce [%s]", err)
+		}
+
+		step1 := hmacSM3(rootKey, append([]byte{1}, nonce...))
+		step2 := hmacSM3(rootKey, append([]byte{2}, nonce...))
+
+		delta := new(big.Int).Mod(new(big.Int).SetBytes(step1), curve.Params().N)
+		dx, dy := curve.ScalarBaseMult(delta.Bytes())
+		tcertX, tcertY := curve.Add(ePub.X, ePub.Y, dx, dy)
+		tcertPub := &sm2.PublicKey{Curve: curve, X: tcertX, Y: tcertY}
+
+		extensions, err := tcertExtensions(nonce, step2, attrs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed generating TCert serial number [%s]", err)
+		}
+
+		template := &sm2.Certificate{
+			SerialNumber:    serialNumber,
+			Subject:         eCert.Subject,
+			NotBefore:       ca.SignSm2Cert.NotBefore,
+			NotAfter:        ca.SignSm2Cert.NotAfter,
+			KeyUsage:        sm2.KeyUsageDigitalSignature,
+			ExtraExtensions: extensions,
+		}
+
+		der, err := sm2.CreateCertificate(template, ca.SignSm2Cert, tcertPub, ca.Signer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed signing TCert [%s]", err)
+		}
+		cert, err := sm2.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed parsing signed TCert [%s]", err)
+		}
+
+		certs[i] = cert
+		deltas[i] = gm.NewSM2PrivateKey(&sm2.PrivateKey{
+			PublicKey: sm2.PublicKey{Curve: curve, X: dx, Y: dy},
+			D:         delta,
+		})
+	}
+
+	return certs, deltas, nil
+}
+
+// tcertOwnerKDFKey derives the root TCertOwnerKDFKey GenerateTCertBatch uses for eCert, scoped to
+// both this CA and that enrollment identity so no two identities (or CAs) share a root key.
+func tcertOwnerKDFKey(ca *CA, eCert *sm2.Certificate) []byte {
+	return hmacSM3(ca.Signer.D.Bytes(), eCert.Raw)
+}
+
+// tcertExtensions builds the [TCertIndex, encrypted attrs] extension pair a TCert carries: nonce in
+// the clear, attrs SM4-CTR-encrypted under a key derived from step2.
+func tcertExtensions(nonce, step2 []byte, attrs map[string][]byte) ([]pkix.Extension, error) {
+	plaintext := tcertAttributes{}
+	for name, value := range attrs {
+		plaintext.Names = append(plaintext.Names, name)
+		plaintext.Values = append(plaintext.Values, value)
+	}
+
+	encoded, err := asn1.Marshal(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("Failed encoding TCert attributes [%s]", err)
+	}
+
+	block, err := sm4.NewCipher(step2[:sm4.BlockSize])
+	if err != nil {
+		return nil, fmt.Errorf("Failed deriving TCert attribute-encryption key [%s]", err)
+	}
+	encryptedAttrs := make([]byte, len(encoded))
+	cipher.NewCTR(block, nonce).XORKeyStream(encryptedAttrs, encoded)
+
+	return []pkix.Extension{
+		{Id: oidTCertIndex, Value: nonce},
+		{Id: oidTCertAttribute, Value: encryptedAttrs},
+	}, nil
+}
+
+func hmacSM3(key, data []byte) []byte {
+	mac := hmac.New(sm3.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
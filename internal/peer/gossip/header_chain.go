@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"bytes"
+	"fmt"
+
+	pcommon "github.com/VoneChain-CS/fabric-gm-protos-go/common"
+	"github.com/VoneChain-CS/fabric-gm/common/policies"
+	"github.com/VoneChain-CS/fabric-gm/common/util"
+	"github.com/VoneChain-CS/fabric-gm/gossip/common"
+	"github.com/VoneChain-CS/fabric-gm/protoutil"
+	"github.com/pkg/errors"
+)
+
+// VerifyHeaderChain validates a contiguous, increasing run of block headers
+// without requiring their bodies: PreviousHash linkage is checked between
+// consecutive headers, and each header's metadataSigs entry is evaluated
+// against the channel's BlockValidation policy, exactly as VerifyBlock
+// evaluates a full block's signatures. This lets gossip/deliveryservice
+// stream headers ahead of bodies during checkpoint-based state transfer,
+// verifying as headers arrive instead of buffering whole blocks in memory.
+//
+// As with VerifyBlock, the policy manager consulted is the current one for
+// chainID; this implementation does not resolve a historical policy as of
+// each header's config sequence.
+//
+// headers must be in increasing, contiguous sequence order; metadataSigs
+// must be the same length, with metadataSigs[i] corresponding to headers[i].
+// VerifyHeaderChain returns the highest sequence number successfully
+// verified and an error describing the first header that failed to verify.
+func (s *MSPMessageCryptoService) VerifyHeaderChain(chainID common.ChannelID, headers []*pcommon.BlockHeader, metadataSigs []*pcommon.BlockMetadata) (uint64, error) {
+	if len(headers) == 0 {
+		return 0, errors.New("no headers to verify")
+	}
+	if len(headers) != len(metadataSigs) {
+		return 0, fmt.Errorf("headers and metadataSigs must be the same length: got %d headers and %d metadataSigs", len(headers), len(metadataSigs))
+	}
+
+	cpm := s.channelPolicyManagerGetter.Manager(string(chainID))
+	if cpm == nil {
+		return 0, fmt.Errorf("Could not acquire policy manager for channel %s", string(chainID))
+	}
+
+	policy, ok := cpm.GetPolicy(policies.BlockValidation)
+	// ok is true if it was the policy requested, or false if it is the default policy
+	mcsLogger.Debugf("Got block validation policy for channel [%s] with flag [%t]", string(chainID), ok)
+
+	var highestVerified uint64
+	for i, header := range headers {
+		if header == nil {
+			return highestVerified, fmt.Errorf("header at index %d is nil", i)
+		}
+
+		if i > 0 {
+			if header.Number != headers[i-1].Number+1 {
+				return highestVerified, fmt.Errorf("header with seqNum [%d] does not follow predecessor with seqNum [%d]", header.Number, headers[i-1].Number)
+			}
+			if !bytes.Equal(header.PreviousHash, protoutil.BlockHeaderHash(headers[i-1])) {
+				return highestVerified, fmt.Errorf("header with seqNum [%d] does not chain to its predecessor: PreviousHash mismatch", header.Number)
+			}
+		}
+
+		signatureSet, err := signaturesForHeader(header, metadataSigs[i])
+		if err != nil {
+			return highestVerified, err
+		}
+
+		if err := policy.EvaluateSignedData(signatureSet); err != nil {
+			return highestVerified, fmt.Errorf("block validation policy not satisfied for header with seqNum [%d]: %s", header.Number, err)
+		}
+
+		highestVerified = header.Number
+	}
+
+	return highestVerified, nil
+}
+
+// signaturesForHeader extracts the SignedData that the BlockValidation
+// policy must evaluate for header, from its standalone BlockMetadata - the
+// same construction VerifyBlock applies to a full block's metadata, minus
+// the block body that VerifyHeaderChain never requires.
+func signaturesForHeader(header *pcommon.BlockHeader, metadata *pcommon.BlockMetadata) ([]*protoutil.SignedData, error) {
+	if metadata == nil || len(metadata.Metadata) == 0 {
+		return nil, fmt.Errorf("header with seqNum [%d] has no metadata", header.Number)
+	}
+
+	sigMetadata, err := protoutil.GetMetadataFromBytes(metadata.Metadata[pcommon.BlockMetadataIndex_SIGNATURES])
+	if err != nil {
+		return nil, fmt.Errorf("failed unmarshalling signatures metadata for header with seqNum [%d]: %s", header.Number, err)
+	}
+
+	signatureSet := make([]*protoutil.SignedData, 0, len(sigMetadata.Signatures))
+	for _, metadataSignature := range sigMetadata.Signatures {
+		shdr, err := protoutil.UnmarshalSignatureHeader(metadataSignature.SignatureHeader)
+		if err != nil {
+			return nil, fmt.Errorf("failed unmarshalling signature header for header with seqNum [%d]: %s", header.Number, err)
+		}
+		signatureSet = append(signatureSet, &protoutil.SignedData{
+			Identity:  shdr.Creator,
+			Data:      util.ConcatenateBytes(sigMetadata.Value, metadataSignature.SignatureHeader, protoutil.BlockHeaderBytes(header)),
+			Signature: metadataSignature.Signature,
+		})
+	}
+	return signatureSet, nil
+}
+
+// VerifyBlockBody re-checks that header.DataHash matches Hash(data), the
+// half of VerifyBlock's work that needs the block body rather than just its
+// header. Call it once a body that was streamed in separately from
+// VerifyHeaderChain arrives, to confirm it matches the already-verified
+// header before handing it to the committer.
+func (s *MSPMessageCryptoService) VerifyBlockBody(header *pcommon.BlockHeader, data *pcommon.BlockData) error {
+	if header == nil {
+		return errors.New("header must be different from nil")
+	}
+	if data == nil {
+		return fmt.Errorf("Invalid Block with id [%d]. Data must be different from nil.", header.Number)
+	}
+
+	if !bytes.Equal(protoutil.BlockDataHash(data), header.DataHash) {
+		return fmt.Errorf("Header.DataHash is different from Hash(block.Data) for block with id [%d]", header.Number)
+	}
+	return nil
+}
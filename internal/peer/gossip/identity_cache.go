@@ -0,0 +1,234 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/VoneChain-CS/fabric-gm/bccsp"
+	"github.com/VoneChain-CS/fabric-gm/common/metrics"
+	"github.com/VoneChain-CS/fabric-gm/gossip/common"
+	"github.com/VoneChain-CS/fabric-gm/msp"
+)
+
+// identityCacheSize and identityCacheTTL bound how long getValidatedIdentity
+// trusts a previously validated identity before walking the MSP managers
+// again. TTL is intentionally short: it only needs to survive the burst of
+// gossip messages a single peer sends in one exchange, not outlive a CRL
+// refresh.
+const (
+	identityCacheSize = 10000
+	identityCacheTTL  = 1 * time.Minute
+
+	// identityCacheExpiryMargin re-validates identities that are due to
+	// expire soon rather than serving them from the cache, so an about-to-
+	// expire certificate isn't trusted past its actual expiry just because
+	// it was cached slightly before.
+	identityCacheExpiryMargin = 1 * time.Minute
+)
+
+// mspChangeNotifier is implemented by MSPMessageCryptoService and consulted
+// by msp/mgmt whenever an MSP manager is updated - by a config block or by a
+// CRL refresh - so that any cache keyed by identities of that MSP can evict
+// its now-stale entries instead of waiting out their TTL.
+type mspChangeNotifier interface {
+	// OnMSPUpdate is invoked with the MSP ID whose MSP manager changed.
+	OnMSPUpdate(mspID string)
+}
+
+// OnMSPUpdate evicts every cached validated identity belonging to mspID. It
+// implements mspChangeNotifier so msp/mgmt can wire config-block and
+// CRL-refresh updates straight into the identity cache.
+func (s *MSPMessageCryptoService) OnMSPUpdate(mspID string) {
+	s.identityCache.evictMSP(mspID)
+}
+
+// identityCacheMetrics reports hit/miss/eviction counts through the ledger's
+// common/metrics.Provider, so an operator can size identityCacheSize and
+// identityCacheTTL from observed hit rate rather than guesswork.
+type identityCacheMetrics struct {
+	hits      metrics.Counter
+	misses    metrics.Counter
+	evictions metrics.Counter
+}
+
+func newIdentityCacheMetrics(provider metrics.Provider) *identityCacheMetrics {
+	return &identityCacheMetrics{
+		hits: provider.NewCounter(metrics.CounterOpts{
+			Namespace: "gossip",
+			Subsystem: "identity_cache",
+			Name:      "hits",
+			Help:      "Number of getValidatedIdentity calls served from the validated-identity cache.",
+		}),
+		misses: provider.NewCounter(metrics.CounterOpts{
+			Namespace: "gossip",
+			Subsystem: "identity_cache",
+			Name:      "misses",
+			Help:      "Number of getValidatedIdentity calls that had to walk the MSP managers.",
+		}),
+		evictions: provider.NewCounter(metrics.CounterOpts{
+			Namespace: "gossip",
+			Subsystem: "identity_cache",
+			Name:      "evictions",
+			Help:      "Number of cached validated identities evicted by an MSP update or by LRU pressure.",
+		}),
+	}
+}
+
+type identityCacheEntry struct {
+	key       string
+	mspID     string
+	identity  msp.Identity
+	channelID common.ChannelID
+	// expiresAt bounds how long this entry lives in the cache, independent of
+	// the identity's own expiry - it is always now+identityCacheTTL as of the
+	// put call, never capped by identityExpiresAt. Conflating the two used to
+	// mean a freshly-put entry already sat inside identityCacheExpiryMargin
+	// of "expiry", so get evicted it on the very next lookup.
+	expiresAt time.Time
+	// identityExpiresAt is the identity's own real expiry (identity.ExpiresAt()),
+	// or the zero Time if the identity doesn't report one (e.g. idemix). get
+	// checks identityCacheExpiryMargin against this field, not expiresAt, so
+	// the freshness gate reflects the certificate's real expiry rather than
+	// the cache's bookkeeping deadline.
+	identityExpiresAt time.Time
+}
+
+// identityCache is an LRU, TTL-bounded cache of validated identities, keyed
+// by the SM3 digest of the serialized identity. It is safe for concurrent
+// use, since getValidatedIdentity is called from every inbound gossip
+// message handler.
+type identityCache struct {
+	mu       sync.Mutex
+	ll       *list.List
+	elements map[string]*list.Element
+	byMSP    map[string]map[string]struct{}
+	metrics  *identityCacheMetrics
+}
+
+func newIdentityCache(metrics *identityCacheMetrics) *identityCache {
+	return &identityCache{
+		ll:       list.New(),
+		elements: make(map[string]*list.Element),
+		byMSP:    make(map[string]map[string]struct{}),
+		metrics:  metrics,
+	}
+}
+
+// key derives the cache key for peerIdentity: the SM3 digest of its
+// serialized bytes. It returns "" if hashing fails, signaling callers to
+// skip the cache for this call rather than fail the request over it.
+func (c *identityCache) key(peerIdentity []byte, hasher Hasher) string {
+	digest, err := hasher.Hash(peerIdentity, &bccsp.SM3Opts{})
+	if err != nil {
+		return ""
+	}
+	return string(digest)
+}
+
+func (c *identityCache) get(key string) (*identityCacheEntry, bool) {
+	c.mu.Lock()
+	elem, ok := c.elements[key]
+	if !ok {
+		c.mu.Unlock()
+		c.metrics.misses.Add(1)
+		return nil, false
+	}
+
+	entry := elem.Value.(*identityCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		// Outlived its cache lifetime (identityCacheTTL since it was put).
+		c.removeLocked(elem)
+		c.mu.Unlock()
+		c.metrics.misses.Add(1)
+		return nil, false
+	}
+	if !entry.identityExpiresAt.IsZero() && time.Until(entry.identityExpiresAt) <= identityCacheExpiryMargin {
+		// Close enough to the identity's real expiry (or already expired)
+		// that it must be re-validated rather than served stale.
+		c.removeLocked(elem)
+		c.mu.Unlock()
+		c.metrics.misses.Add(1)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	c.mu.Unlock()
+	c.metrics.hits.Add(1)
+	return entry, true
+}
+
+func (c *identityCache) put(key, mspID string, identity msp.Identity, channelID common.ChannelID) {
+	if key == "" {
+		return
+	}
+
+	// expiresAt is purely the cache's own lifetime - always now+TTL - so it
+	// never lands inside identityCacheExpiryMargin on a fresh put regardless
+	// of how soon the identity itself expires. Freshness against the
+	// identity's real expiry is get's job, checked against identityExpiresAt.
+	expiresAt := time.Now().Add(identityCacheTTL)
+	identityExpiresAt := identity.ExpiresAt()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.elements[key]; ok {
+		c.removeLocked(elem)
+	}
+
+	entry := &identityCacheEntry{
+		key:               key,
+		mspID:             mspID,
+		identity:          identity,
+		channelID:         channelID,
+		expiresAt:         expiresAt,
+		identityExpiresAt: identityExpiresAt,
+	}
+	elem := c.ll.PushFront(entry)
+	c.elements[key] = elem
+	if c.byMSP[mspID] == nil {
+		c.byMSP[mspID] = make(map[string]struct{})
+	}
+	c.byMSP[mspID][key] = struct{}{}
+
+	if c.ll.Len() > identityCacheSize {
+		c.removeLocked(c.ll.Back())
+		c.metrics.evictions.Add(1)
+	}
+}
+
+// evictMSP drops every cached entry belonging to mspID, e.g. because its MSP
+// manager was just updated by a config block or CRL refresh.
+func (c *identityCache) evictMSP(mspID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := c.byMSP[mspID]
+	for key := range keys {
+		if elem, ok := c.elements[key]; ok {
+			c.removeLocked(elem)
+			c.metrics.evictions.Add(1)
+		}
+	}
+}
+
+// removeLocked detaches elem from both the LRU list and the byMSP index.
+// Callers must hold c.mu.
+func (c *identityCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*identityCacheEntry)
+	c.ll.Remove(elem)
+	delete(c.elements, entry.key)
+	if keys := c.byMSP[entry.mspID]; keys != nil {
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.byMSP, entry.mspID)
+		}
+	}
+}
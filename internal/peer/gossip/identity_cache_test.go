@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	mspproto "github.com/VoneChain-CS/fabric-gm-protos-go/msp"
+	"github.com/VoneChain-CS/fabric-gm/gossip/common"
+	"github.com/VoneChain-CS/fabric-gm/msp"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdentity is a minimal msp.Identity stub for identityCache tests; only ExpiresAt is
+// exercised by the cache, the rest exist solely to satisfy the interface.
+type fakeIdentity struct {
+	expiresAt time.Time
+}
+
+func (f *fakeIdentity) ExpiresAt() time.Time                              { return f.expiresAt }
+func (f *fakeIdentity) GetIdentifier() *msp.IdentityIdentifier            { return &msp.IdentityIdentifier{} }
+func (f *fakeIdentity) GetMSPIdentifier() string                         { return "" }
+func (f *fakeIdentity) Validate() error                                  { return nil }
+func (f *fakeIdentity) GetOrganizationalUnits() []*msp.OUIdentifier      { return nil }
+func (f *fakeIdentity) Anonymous() bool                                 { return false }
+func (f *fakeIdentity) Verify(msg []byte, sig []byte) error              { return nil }
+func (f *fakeIdentity) Serialize() ([]byte, error)                       { return nil, nil }
+func (f *fakeIdentity) SatisfiesPrincipal(_ *mspproto.MSPPrincipal) error { return nil }
+
+// TestIdentityCacheGetAfterPut is the regression test for the inert-cache bug: a freshly put
+// entry must be servable on an immediately-following get, not evicted as "about to expire" just
+// because its cache lifetime and its freshness margin happened to both be identityCacheTTL.
+func TestIdentityCacheGetAfterPut(t *testing.T) {
+	c := newIdentityCache(newIdentityCacheMetrics(&disabledMetricsProvider{}))
+
+	identity := &fakeIdentity{expiresAt: time.Now().Add(24 * time.Hour)}
+	c.put("key-1", "msp-1", identity, common.ChannelID("channel-1"))
+
+	entry, ok := c.get("key-1")
+	require.True(t, ok, "a freshly put entry must be servable on the very next get")
+	require.Equal(t, identity, entry.identity)
+}
+
+// TestIdentityCacheGetRejectsNearExpiryIdentity confirms the freshness gate still works: an
+// identity whose own expiry is within identityCacheExpiryMargin must not be served from the cache,
+// even though the cache entry itself is nowhere near its own identityCacheTTL lifetime.
+func TestIdentityCacheGetRejectsNearExpiryIdentity(t *testing.T) {
+	c := newIdentityCache(newIdentityCacheMetrics(&disabledMetricsProvider{}))
+
+	identity := &fakeIdentity{expiresAt: time.Now().Add(identityCacheExpiryMargin / 2)}
+	c.put("key-1", "msp-1", identity, common.ChannelID("channel-1"))
+
+	_, ok := c.get("key-1")
+	require.False(t, ok, "an identity expiring within the margin must not be served from the cache")
+}
+
+// TestIdentityCacheGetServesIdentityWithNoExpiry confirms identities that report no expiry
+// (identity.ExpiresAt() returns the zero Time, e.g. idemix) are still cacheable and are evicted
+// only by identityCacheTTL, not by the freshness margin.
+func TestIdentityCacheGetServesIdentityWithNoExpiry(t *testing.T) {
+	c := newIdentityCache(newIdentityCacheMetrics(&disabledMetricsProvider{}))
+
+	identity := &fakeIdentity{}
+	c.put("key-1", "msp-1", identity, common.ChannelID("channel-1"))
+
+	_, ok := c.get("key-1")
+	require.True(t, ok)
+}
@@ -14,6 +14,7 @@ import (
 	pcommon "github.com/VoneChain-CS/fabric-gm-protos-go/common"
 	"github.com/VoneChain-CS/fabric-gm/bccsp"
 	"github.com/VoneChain-CS/fabric-gm/common/flogging"
+	"github.com/VoneChain-CS/fabric-gm/common/metrics"
 	"github.com/VoneChain-CS/fabric-gm/common/policies"
 	"github.com/VoneChain-CS/fabric-gm/common/util"
 	"github.com/VoneChain-CS/fabric-gm/gossip/api"
@@ -46,6 +47,8 @@ type MSPMessageCryptoService struct {
 	localSigner                identity.SignerSerializer
 	deserializer               mgmt.DeserializersManager
 	hasher                     Hasher
+	pkiIDResolver              PKIIDResolver
+	identityCache              *identityCache
 }
 
 // NewMCS creates a new instance of MSPMessageCryptoService
@@ -54,20 +57,42 @@ type MSPMessageCryptoService struct {
 // 1. a policies.ChannelPolicyManagerGetter that gives access to the policy manager of a given channel via the Manager method.
 // 2. an instance of identity.SignerSerializer
 // 3. an identity deserializer manager
+// 4. a Hasher used to compute digests
+// 5. a PKIIDResolver that picks the HashOpts to use for a given identity's
+//    channel, so that PKI-IDs agree with peers on mixed-crypto networks.
+//    A nil resolver defaults to always hashing with SM3.
+// 6. a metrics.Provider used to report validated-identity cache hit/miss/
+//    eviction counts. The returned service also implements
+//    mspChangeNotifier; msp/mgmt should register it so MSP updates and CRL
+//    refreshes evict the corresponding cached identities.
 func NewMCS(
 	channelPolicyManagerGetter policies.ChannelPolicyManagerGetter,
 	localSigner identity.SignerSerializer,
 	deserializer mgmt.DeserializersManager,
 	hasher Hasher,
+	pkiIDResolver PKIIDResolver,
+	metricsProvider metrics.Provider,
 ) *MSPMessageCryptoService {
+	if pkiIDResolver == nil {
+		pkiIDResolver = NewPKIIDResolver(nil, nil)
+	}
 	return &MSPMessageCryptoService{
 		channelPolicyManagerGetter: channelPolicyManagerGetter,
 		localSigner:                localSigner,
 		deserializer:               deserializer,
 		hasher:                     hasher,
+		pkiIDResolver:              pkiIDResolver,
+		identityCache:              newIdentityCache(newIdentityCacheMetrics(metricsProvider)),
 	}
 }
 
+// SetPKIIDResolver swaps the PKIIDResolver consulted by GetPKIidOfCert.
+// It exists so tests can install a fixed HashFamily mapping without going
+// through channel configuration.
+func (s *MSPMessageCryptoService) SetPKIIDResolver(pkiIDResolver PKIIDResolver) {
+	s.pkiIDResolver = pkiIDResolver
+}
+
 // ValidateIdentity validates the identity of a remote peer.
 // If the identity is invalid, revoked, expired it returns an error.
 // Else, returns nil
@@ -82,8 +107,10 @@ func (s *MSPMessageCryptoService) ValidateIdentity(peerIdentity api.PeerIdentity
 
 // GetPKIidOfCert returns the PKI-ID of a peer's identity
 // If any error occurs, the method return nil
-// The PKid of a peer is computed as the SHA2-256 of peerIdentity which
-// is supposed to be the serialized version of MSP identity.
+// The PKid of a peer is computed as the hash of peerIdentity, using the
+// hash family negotiated by the identity's channel (falling back to the
+// local MSP's default, normally SM3), which is supposed to be the
+// serialized version of MSP identity.
 // This method does not validate peerIdentity.
 // This validation is supposed to be done appropriately during the execution flow.
 func (s *MSPMessageCryptoService) GetPKIidOfCert(peerIdentity api.PeerIdentityType) common.PKIidType {
@@ -108,8 +135,14 @@ func (s *MSPMessageCryptoService) GetPKIidOfCert(peerIdentity api.PeerIdentityTy
 	mspIDRaw := []byte(sid.Mspid)
 	raw := append(mspIDRaw, sid.IdBytes...)
 
+	// Resolve which hash family to use for this identity's channel, so
+	// that this PKI-ID matches the one computed by peers on the other
+	// side of a mixed-crypto network.
+	channelID := s.channelOfIdentity(peerIdentity)
+	opts := s.pkiIDResolver.Resolve(channelID, peerIdentity)
+
 	// Hash
-	digest, err := s.hasher.Hash(raw, &bccsp.SM3Opts{})
+	digest, err := s.hasher.Hash(raw, opts)
 	if err != nil {
 		mcsLogger.Errorf("Failed computing digest of serialized identity %s: [%s]", peerIdentity, err)
 		return nil
@@ -118,6 +151,25 @@ func (s *MSPMessageCryptoService) GetPKIidOfCert(peerIdentity api.PeerIdentityTy
 	return digest
 }
 
+// channelOfIdentity reports the channel whose MSP manager can deserialize
+// peerIdentity, without validating it, so that GetPKIidOfCert can resolve
+// the channel-scoped hash family. It returns the empty ChannelID when
+// peerIdentity belongs to the local MSP or to no channel known to this peer.
+func (s *MSPMessageCryptoService) channelOfIdentity(peerIdentity api.PeerIdentityType) common.ChannelID {
+	lDes := s.deserializer.GetLocalDeserializer()
+	if _, err := lDes.DeserializeIdentity([]byte(peerIdentity)); err == nil {
+		return ""
+	}
+
+	for chainID, mspManager := range s.deserializer.GetChannelDeserializers() {
+		if _, err := mspManager.DeserializeIdentity([]byte(peerIdentity)); err == nil {
+			return common.ChannelID(chainID)
+		}
+	}
+
+	return ""
+}
+
 // VerifyBlock returns nil if the block is properly signed, and the claimed seqNum is the
 // sequence number that the block's header contains.
 // else returns error
@@ -262,16 +314,40 @@ func (s *MSPMessageCryptoService) Expiration(peerIdentity api.PeerIdentityType)
 
 }
 
+// getValidatedIdentity fronts validateIdentity, which does the real work, with
+// the identity cache: inbound gossip messages and Verify calls hit this for
+// every message, and re-walking every channel MSP manager each time is
+// wasteful once an identity has already been validated.
 func (s *MSPMessageCryptoService) getValidatedIdentity(peerIdentity api.PeerIdentityType) (msp.Identity, common.ChannelID, error) {
 	// Validate arguments
 	if len(peerIdentity) == 0 {
 		return nil, nil, errors.New("Invalid Peer Identity. It must be different from nil.")
 	}
 
+	key := s.identityCache.key(peerIdentity, s.hasher)
+	if key != "" {
+		if entry, ok := s.identityCache.get(key); ok {
+			return entry.identity, entry.channelID, nil
+		}
+	}
+
+	identity, channelID, mspID, err := s.validateIdentity(peerIdentity)
+	if err != nil {
+		return identity, channelID, err
+	}
+
+	s.identityCache.put(key, mspID, identity, channelID)
+	return identity, channelID, nil
+}
+
+// validateIdentity deserializes peerIdentity against the local MSP and,
+// failing that, every channel MSP manager known to this peer, returning the
+// first one under which it validates along with its MSP ID.
+func (s *MSPMessageCryptoService) validateIdentity(peerIdentity api.PeerIdentityType) (msp.Identity, common.ChannelID, string, error) {
 	sId, err := s.deserializer.Deserialize(peerIdentity)
 	if err != nil {
 		mcsLogger.Error("failed deserializing identity", err)
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	// Notice that peerIdentity is assumed to be the serialization of an identity.
@@ -287,7 +363,7 @@ func (s *MSPMessageCryptoService) getValidatedIdentity(peerIdentity api.PeerIden
 		// No error means that the local MSP successfully deserialized the identity.
 		// We now check additional properties.
 		if err := lDes.IsWellFormed(sId); err != nil {
-			return nil, nil, errors.Wrap(err, "identity is not well formed")
+			return nil, nil, "", errors.Wrap(err, "identity is not well formed")
 		}
 		// TODO: The following check will be replaced by a check on the organizational units
 		// when we allow the gossip network to have organization unit (MSP subdivisions)
@@ -303,7 +379,7 @@ func (s *MSPMessageCryptoService) getValidatedIdentity(peerIdentity api.PeerIden
 			// Notice that at this stage we don't have to check the identity
 			// against any channel's policies.
 			// This will be done by the caller function, if needed.
-			return identity, nil, identity.Validate()
+			return identity, nil, identity.GetMSPIdentifier(), identity.Validate()
 		}
 	}
 
@@ -318,7 +394,7 @@ func (s *MSPMessageCryptoService) getValidatedIdentity(peerIdentity api.PeerIden
 
 		// We managed deserializing the identity with this MSP manager. Now we check if it's well formed.
 		if err := mspManager.IsWellFormed(sId); err != nil {
-			return nil, nil, errors.Wrap(err, "identity is not well formed")
+			return nil, nil, "", errors.Wrap(err, "identity is not well formed")
 		}
 
 		// Check identity validity
@@ -333,8 +409,8 @@ func (s *MSPMessageCryptoService) getValidatedIdentity(peerIdentity api.PeerIden
 
 		mcsLogger.Debugf("Validation succeeded %s on [%s]", peerIdentity, chainID)
 
-		return identity, common.ChannelID(chainID), nil
+		return identity, common.ChannelID(chainID), identity.GetMSPIdentifier(), nil
 	}
 
-	return nil, nil, fmt.Errorf("Peer Identity %s cannot be validated. No MSP found able to do that.", peerIdentity)
+	return nil, nil, "", fmt.Errorf("Peer Identity %s cannot be validated. No MSP found able to do that.", peerIdentity)
 }
@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	"github.com/VoneChain-CS/fabric-gm/bccsp"
+	"github.com/VoneChain-CS/fabric-gm/gossip/api"
+	"github.com/VoneChain-CS/fabric-gm/gossip/common"
+)
+
+// HashFamily identifies which hash algorithm family a channel has agreed to
+// use for computing PKI-IDs. Channels created by peers still running
+// upstream (non-GM) Fabric negotiate HashFamilySHA2, while channels native
+// to this fork use HashFamilySM3.
+type HashFamily string
+
+const (
+	// HashFamilySM3 derives PKI-IDs using SM3, the default for this fork.
+	HashFamilySM3 HashFamily = "SM3"
+	// HashFamilySHA2 derives PKI-IDs using SHA-256, for interop with
+	// channels whose members still run upstream Fabric.
+	HashFamilySHA2 HashFamily = "SHA2"
+)
+
+// ChannelHashFamilyGetter resolves the HashFamily that a channel's config
+// capabilities negotiated. ok is false when channelID is not a channel this
+// peer knows about, in which case callers should fall back to the local
+// MSP's default.
+type ChannelHashFamilyGetter func(channelID string) (family HashFamily, ok bool)
+
+// PKIIDResolver resolves the bccsp.HashOpts to use when computing the
+// PKI-ID of a peer identity, so that both ends of the gossip pipeline
+// derive the same PKI-ID even when the identity's channel has negotiated a
+// hash family different from this peer's local default. It is swappable on
+// MSPMessageCryptoService so tests can supply a fixed mapping.
+type PKIIDResolver interface {
+	// Resolve returns the HashOpts to use for peerIdentity on channelID.
+	// channelID is empty when peerIdentity could not be bound to any
+	// channel, e.g. because it belongs to this peer's local MSP.
+	Resolve(channelID common.ChannelID, peerIdentity api.PeerIdentityType) bccsp.HashOpts
+}
+
+// hashOptsForFamily returns the bccsp.HashOpts implementing family.
+func hashOptsForFamily(family HashFamily) bccsp.HashOpts {
+	switch family {
+	case HashFamilySHA2:
+		return &bccsp.SHA256Opts{}
+	default:
+		return &bccsp.SM3Opts{}
+	}
+}
+
+// channelHashFamilyResolver is the default PKIIDResolver. It consults a
+// ChannelHashFamilyGetter for the HashFamily negotiated by channelID,
+// falling back to the local MSP's default hash family when channelID is
+// empty or unknown to the getter.
+type channelHashFamilyResolver struct {
+	channelHashFamily ChannelHashFamilyGetter
+	localDefault      bccsp.HashOpts
+}
+
+// NewPKIIDResolver creates a PKIIDResolver that derives HashOpts from the
+// HashFamily negotiated per channel, as reported by channelHashFamily.
+// localDefault is the HashOpts used for identities that cannot be bound to
+// any channel; it is typically derived from bccsp.GetDefault().GetHash()
+// for the local MSP.
+func NewPKIIDResolver(channelHashFamily ChannelHashFamilyGetter, localDefault bccsp.HashOpts) PKIIDResolver {
+	if localDefault == nil {
+		localDefault = &bccsp.SM3Opts{}
+	}
+	return &channelHashFamilyResolver{
+		channelHashFamily: channelHashFamily,
+		localDefault:      localDefault,
+	}
+}
+
+func (r *channelHashFamilyResolver) Resolve(channelID common.ChannelID, _ api.PeerIdentityType) bccsp.HashOpts {
+	if len(channelID) == 0 || r.channelHashFamily == nil {
+		return r.localDefault
+	}
+
+	family, ok := r.channelHashFamily(string(channelID))
+	if !ok {
+		return r.localDefault
+	}
+
+	return hashOptsForFamily(family)
+}
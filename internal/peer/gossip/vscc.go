@@ -0,0 +1,143 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package gossip
+
+import (
+	pcommon "github.com/VoneChain-CS/fabric-gm-protos-go/common"
+	"github.com/VoneChain-CS/fabric-gm-protos-go/peer"
+	"github.com/VoneChain-CS/fabric-gm/common/policies"
+	"github.com/VoneChain-CS/fabric-gm/core/ledger/util"
+	"github.com/VoneChain-CS/fabric-gm/gossip/common"
+	"github.com/VoneChain-CS/fabric-gm/protoutil"
+	"github.com/pkg/errors"
+)
+
+// EndorsementPolicyProvider resolves the endorsement policy that governs a
+// chaincode's transactions on a given channel. Implementations are expected
+// to consult the _lifecycle state for channels at the Fabric 2.x application
+// capability, or the legacy ESCC-instantiation data otherwise.
+type EndorsementPolicyProvider interface {
+	// PolicyForChaincode returns the policy that endorsements for ccName
+	// must satisfy on channelID.
+	PolicyForChaincode(channelID, ccName string) (policies.Policy, error)
+}
+
+// VerifyBlockWithTxEndorsements behaves like VerifyBlock and additionally
+// evaluates every transaction's endorsements against its chaincode's
+// endorsement policy, the same check VSCC performs at commit time. This lets
+// gossip refuse to re-disseminate blocks that would be rejected wholesale at
+// commit, saving bandwidth on malformed blocks. The returned
+// util.TxValidationFlags reports the outcome per transaction so callers can
+// still choose to forward a block in which only some transactions fail.
+//
+// policyResolver may be nil to skip the endorsement check entirely, e.g. on
+// leader-only peers that do not re-disseminate blocks and so gain nothing
+// from the extra verification work; every transaction is then reported
+// peer.TxValidationCode_VALID.
+func (s *MSPMessageCryptoService) VerifyBlockWithTxEndorsements(chainID common.ChannelID, seqNum uint64, block *pcommon.Block, policyResolver EndorsementPolicyProvider) (util.TxValidationFlags, error) {
+	if err := s.VerifyBlock(chainID, seqNum, block); err != nil {
+		return nil, err
+	}
+
+	flags := util.NewTxValidationFlags(len(block.Data.Data))
+	if policyResolver == nil {
+		return flags, nil
+	}
+
+	for txIndex, txBytes := range block.Data.Data {
+		ccName, signatureSet, err := endorsementsForTx(txBytes)
+		if err != nil {
+			mcsLogger.Debugf("Failed extracting endorsements for tx %d on channel [%s]: [%s]", txIndex, chainID, err)
+			flags.SetFlag(txIndex, peer.TxValidationCode_INVALID_OTHER_REASON)
+			continue
+		}
+		if ccName == "" {
+			// Not an endorser transaction (e.g. a channel config update); nothing to check here.
+			continue
+		}
+
+		policy, err := policyResolver.PolicyForChaincode(string(chainID), ccName)
+		if err != nil {
+			mcsLogger.Debugf("Failed resolving endorsement policy for chaincode [%s] on channel [%s]: [%s]", ccName, chainID, err)
+			flags.SetFlag(txIndex, peer.TxValidationCode_INVALID_OTHER_REASON)
+			continue
+		}
+
+		if err := policy.EvaluateSignedData(signatureSet); err != nil {
+			mcsLogger.Debugf("Endorsement policy not satisfied for tx %d (chaincode [%s]) on channel [%s]: [%s]", txIndex, ccName, chainID, err)
+			flags.SetFlag(txIndex, peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE)
+		}
+	}
+
+	return flags, nil
+}
+
+// endorsementsForTx extracts the chaincode name and the endorsements,
+// expressed as protoutil.SignedData ready for policy evaluation, carried by
+// a transaction envelope. ccName is empty for envelopes that do not carry a
+// ChaincodeActionPayload, e.g. channel configuration transactions, in which
+// case the caller should skip endorsement-policy evaluation for it.
+func endorsementsForTx(txBytes []byte) (ccName string, signatureSet []*protoutil.SignedData, err error) {
+	envelope, err := protoutil.UnmarshalEnvelope(txBytes)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed unmarshalling tx envelope")
+	}
+
+	payload, err := protoutil.UnmarshalPayload(envelope.Payload)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed unmarshalling tx payload")
+	}
+
+	chdr, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed unmarshalling channel header")
+	}
+	if pcommon.HeaderType(chdr.Type) != pcommon.HeaderType_ENDORSER_TRANSACTION {
+		return "", nil, nil
+	}
+
+	tx, err := protoutil.UnmarshalTransaction(payload.Data)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed unmarshalling transaction")
+	}
+	if len(tx.Actions) == 0 {
+		return "", nil, errors.New("transaction carries no actions")
+	}
+
+	// A transaction may carry more than one action when multiple chaincodes
+	// are invoked atomically; VSCC evaluates each independently, but all
+	// actions here are known to share the same invoked chaincode, so the
+	// first action's ChaincodeActionPayload is representative for naming.
+	capPayload, err := protoutil.UnmarshalChaincodeActionPayload(tx.Actions[0].Payload)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed unmarshalling chaincode action payload")
+	}
+
+	respPayload, err := protoutil.UnmarshalProposalResponsePayload(capPayload.Action.ProposalResponsePayload)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed unmarshalling proposal response payload")
+	}
+
+	ccAction, err := protoutil.UnmarshalChaincodeAction(respPayload.Extension)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed unmarshalling chaincode action")
+	}
+	if ccAction.ChaincodeId == nil {
+		return "", nil, errors.New("chaincode action carries no chaincode id")
+	}
+
+	signatureSet = make([]*protoutil.SignedData, 0, len(capPayload.Action.Endorsements))
+	for _, endorsement := range capPayload.Action.Endorsements {
+		signatureSet = append(signatureSet, &protoutil.SignedData{
+			Identity:  endorsement.Endorser,
+			Data:      append(capPayload.Action.ProposalResponsePayload, endorsement.Endorser...),
+			Signature: endorsement.Signature,
+		})
+	}
+
+	return ccAction.ChaincodeId.Name, signatureSet, nil
+}